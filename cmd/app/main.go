@@ -43,7 +43,9 @@ func main() {
 		log.Fatalf("BD error: %v", err)
 	}
 
-	db.Migrate(database)
+	if err := db.Migrate(database); err != nil {
+		log.Fatalf("Migration error: %v", err)
+	}
 
 	// fiber server
 	server.Run(cfg, database)
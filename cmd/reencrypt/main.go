@@ -0,0 +1,237 @@
+// Command reencrypt moves everything sealed with the envelope keyring to
+// the primary encryption key: finding values, TOTP secrets and raw
+// reports in blob storage. Values sealed with an older key are rewrapped
+// and legacy plaintext is sealed. Run it after adding a new key to
+// ENCRYPTION_KEYS and making it ENCRYPTION_PRIMARY_KEY; the old key can
+// be dropped afterwards.
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/joho/godotenv"
+	"gorm.io/gorm"
+
+	"mws-ai/internal/config"
+	"mws-ai/internal/db"
+	"mws-ai/internal/repository"
+	"mws-ai/pkg/blob"
+	"mws-ai/pkg/envelope"
+	"mws-ai/pkg/logger"
+)
+
+// counts of one kind of sealed data
+type counts struct {
+	scanned, updated, failed int
+}
+
+type reencryptor struct {
+	keyring *envelope.Keyring
+	batch   int
+	dryRun  bool
+}
+
+func main() {
+	batch := flag.Int("batch", 500, "rows per batch")
+	dryRun := flag.Bool("dry-run", false, "count values to re-encrypt without writing")
+	flag.Parse()
+
+	_ = godotenv.Load()
+
+	cfg, _, err := config.Load()
+	if err != nil {
+		log.Fatalf("Config error: %v", err)
+	}
+
+	logger.Init()
+
+	keyring, err := cfg.Keyring()
+	if err != nil {
+		log.Fatalf("Keyring error: %v", err)
+	}
+	if keyring == nil {
+		log.Fatal("ENCRYPTION_KEYS is not set")
+	}
+
+	blobs, err := cfg.BlobStore()
+	if err != nil {
+		log.Fatalf("Storage error: %v", err)
+	}
+
+	database, err := db.Init(cfg)
+	if err != nil {
+		log.Fatalf("BD error: %v", err)
+	}
+	defer db.Close(database)
+
+	r := reencryptor{keyring: keyring, batch: *batch, dryRun: *dryRun}
+
+	kinds := []struct {
+		name string
+		run  func() counts
+	}{
+		{"finding_values", func() counts { return r.findings(database) }},
+		{"totp_secrets", func() counts { return r.totpSecrets(database) }},
+		{"raw_reports", func() counts { return r.reports(database, blob.NewSealedStore(blobs, keyring)) }},
+	}
+
+	failed := 0
+	for _, kind := range kinds {
+		c := kind.run()
+
+		logger.Log.Info().
+			Str("kind", kind.name).
+			Int("scanned", c.scanned).
+			Int("reencrypted", c.updated).
+			Int("failed", c.failed).
+			Bool("dry_run", *dryRun).
+			Str("primary_key", keyring.Primary()).
+			Msg("re-encryption finished")
+
+		failed += c.failed
+	}
+
+	if failed > 0 {
+		log.Fatalf("%d values could not be re-encrypted", failed)
+	}
+}
+
+// current reports whether v is already sealed with the primary key
+func (r reencryptor) current(v string) bool {
+	return envelope.IsSealed(v) && envelope.KeyID(v) == r.keyring.Primary()
+}
+
+func (r reencryptor) findings(database *gorm.DB) counts {
+	findings := repository.NewFindingRepository(database)
+
+	var c counts
+	var afterID uint
+
+	for {
+		page, err := findings.ListValuesAfter(afterID, r.batch)
+		if err != nil {
+			log.Fatalf("List error: %v", err)
+		}
+		if len(page) == 0 {
+			return c
+		}
+
+		for _, f := range page {
+			afterID = f.ID
+			c.scanned++
+
+			if r.current(f.Value) {
+				continue
+			}
+
+			value, err := r.keyring.Rewrap(f.Value)
+			if err != nil {
+				// например, ключ уже удалён из ENCRYPTION_KEYS
+				logger.Log.Error().
+					Uint("finding_id", f.ID).
+					Str("key_id", envelope.KeyID(f.Value)).
+					Err(err).
+					Msg("cannot re-encrypt value")
+				c.failed++
+				continue
+			}
+
+			if !r.dryRun {
+				if err := findings.SetValue(f.ID, value); err != nil {
+					log.Fatalf("Update error: %v", err)
+				}
+			}
+			c.updated++
+		}
+	}
+}
+
+func (r reencryptor) totpSecrets(database *gorm.DB) counts {
+	users := repository.NewUserRepository(database)
+
+	var c counts
+	var afterID uint
+
+	for {
+		page, err := users.ListTOTPSecretsAfter(afterID, r.batch)
+		if err != nil {
+			log.Fatalf("List error: %v", err)
+		}
+		if len(page) == 0 {
+			return c
+		}
+
+		for _, u := range page {
+			afterID = u.ID
+			c.scanned++
+
+			if u.TOTPSecret == nil || r.current(*u.TOTPSecret) {
+				continue
+			}
+
+			secret, err := r.keyring.Rewrap(*u.TOTPSecret)
+			if err != nil {
+				logger.Log.Error().
+					Uint("user_id", u.ID).
+					Str("key_id", envelope.KeyID(*u.TOTPSecret)).
+					Err(err).
+					Msg("cannot re-encrypt totp secret")
+				c.failed++
+				continue
+			}
+
+			if !r.dryRun {
+				if err := users.SetTOTPSecret(u.ID, secret); err != nil {
+					log.Fatalf("Update error: %v", err)
+				}
+			}
+			c.updated++
+		}
+	}
+}
+
+func (r reencryptor) reports(database *gorm.DB, blobs *blob.SealedStore) counts {
+	analyses := repository.NewAnalysisRepository(database)
+
+	var c counts
+	var afterID uint
+
+	for {
+		page, err := analyses.ListStoredReportsAfter(afterID, r.batch)
+		if err != nil {
+			log.Fatalf("List error: %v", err)
+		}
+		if len(page) == 0 {
+			return c
+		}
+
+		for _, a := range page {
+			afterID = a.ID
+			c.scanned++
+
+			var (
+				rewritten bool
+				err       error
+			)
+			if r.dryRun {
+				rewritten, err = blobs.NeedsRewrap(a.StorageKey)
+			} else {
+				rewritten, err = blobs.Rewrap(a.StorageKey)
+			}
+			if err != nil {
+				logger.Log.Error().
+					Uint("analysis_id", a.ID).
+					Str("storage_key", a.StorageKey).
+					Err(err).
+					Msg("cannot re-encrypt raw report")
+				c.failed++
+				continue
+			}
+
+			if rewritten {
+				c.updated++
+			}
+		}
+	}
+}
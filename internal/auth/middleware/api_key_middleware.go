@@ -40,7 +40,7 @@ func APIKeyMiddleware(apiKeys *services.APIKeyService) fiber.Handler {
 			Str("source", source).
 			Msg("API key provided")
 
-		userID, err := apiKeys.Validate(rawKey)
+		key, err := apiKeys.Validate(rawKey)
 		if err != nil {
 			log.Info().
 				Err(err).
@@ -51,11 +51,12 @@ func APIKeyMiddleware(apiKeys *services.APIKeyService) fiber.Handler {
 		}
 
 		log.Debug().
-			Uint("user_id", userID).
+			Uint("user_id", key.UserID).
 			Str("source", source).
 			Msg("API key validated successfully")
 
-		c.Locals("user_id", userID)
+		c.Locals("user_id", key.UserID)
+		c.Locals("api_key", key)
 		return c.Next()
 	}
 }
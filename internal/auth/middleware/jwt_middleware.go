@@ -3,13 +3,13 @@ package auth
 import (
 	"strings"
 
-	"mws-ai/pkg/jwt"
+	"mws-ai/internal/services"
 	"mws-ai/pkg/logger"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func JWTMiddleware(jwtManager *jwt.JWTManager) fiber.Handler {
+func JWTMiddleware(sessions *services.SessionService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 
 		authHeader := c.Get("Authorization")
@@ -36,7 +36,7 @@ func JWTMiddleware(jwtManager *jwt.JWTManager) fiber.Handler {
 
 		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
 
-		claims, err := jwtManager.Parse(tokenStr)
+		claims, userID, err := sessions.Authenticate(tokenStr)
 		if err != nil {
 			logger.Log.Info().
 				Str("component", "auth").
@@ -48,30 +48,6 @@ func JWTMiddleware(jwtManager *jwt.JWTManager) fiber.Handler {
 			return fiber.ErrUnauthorized
 		}
 
-		userIDRaw, ok := claims["user_id"]
-		if !ok {
-			logger.Log.Warn().
-				Str("component", "auth").
-				Str("middleware", "JWT").
-				Str("path", c.Path()).
-				Msg("user_id claim missing in JWT")
-
-			return fiber.ErrUnauthorized
-		}
-
-		userIDFloat, ok := userIDRaw.(float64)
-		if !ok {
-			logger.Log.Warn().
-				Str("component", "auth").
-				Str("middleware", "JWT").
-				Interface("user_id_claim", userIDRaw).
-				Msg("invalid user_id claim type")
-
-			return fiber.ErrUnauthorized
-		}
-
-		userID := uint(userIDFloat)
-
 		logger.Log.Debug().
 			Str("component", "auth").
 			Str("middleware", "JWT").
@@ -80,6 +56,7 @@ func JWTMiddleware(jwtManager *jwt.JWTManager) fiber.Handler {
 			Msg("JWT validated successfully")
 
 		c.Locals("user_id", userID)
+		c.Locals("jwt_claims", claims)
 
 		return c.Next()
 	}
@@ -0,0 +1,170 @@
+package rbac
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// Role of a user inside an organization
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleReviewer Role = "reviewer"
+	RoleAdmin    Role = "admin"
+)
+
+// Permission is a single action guarded by RBAC
+type Permission string
+
+const (
+	PermAnalysesRead  Permission = "analyses:read"
+	PermAnalysesWrite Permission = "analyses:write"
+	PermReviewWrite   Permission = "review:write"
+	PermOrgRead       Permission = "org:read"
+	PermOrgManage     Permission = "org:manage"
+	PermAPIKeysManage Permission = "api_keys:manage"
+
+	// PermSuppressionsManage edits the rules that hide findings as known-safe
+	PermSuppressionsManage Permission = "suppressions:manage"
+
+	// PermFindingsReveal shows full secret values; never granted to API keys
+	PermFindingsReveal Permission = "findings:reveal"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleViewer: {
+		PermAnalysesRead,
+		PermOrgRead,
+	},
+	RoleReviewer: {
+		PermAnalysesRead,
+		PermAnalysesWrite,
+		PermReviewWrite,
+		PermFindingsReveal,
+		PermSuppressionsManage,
+		PermOrgRead,
+	},
+	RoleAdmin: {
+		PermAnalysesRead,
+		PermAnalysesWrite,
+		PermReviewWrite,
+		PermFindingsReveal,
+		PermSuppressionsManage,
+		PermOrgRead,
+		PermOrgManage,
+		PermAPIKeysManage,
+	},
+}
+
+// KeyScopes are the permissions that may be granted to an API key
+var KeyScopes = []Permission{
+	PermAnalysesRead,
+	PermAnalysesWrite,
+	PermReviewWrite,
+}
+
+// ValidKeyScope reports whether p may be granted to an API key
+func ValidKeyScope(p Permission) bool {
+	for _, s := range KeyScopes {
+		if s == p {
+			return true
+		}
+	}
+	return false
+}
+
+var roleRank = map[Role]int{
+	RoleViewer:   1,
+	RoleReviewer: 2,
+	RoleAdmin:    3,
+}
+
+func (r Role) Valid() bool {
+	_, ok := roleRank[r]
+	return ok
+}
+
+// Can reports whether the role grants the permission
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Min returns the less privileged of two roles
+func Min(a, b Role) Role {
+	if roleRank[a] <= roleRank[b] {
+		return a
+	}
+	return b
+}
+
+// Max returns the more privileged of two roles
+func Max(a, b Role) Role {
+	if roleRank[a] >= roleRank[b] {
+		return a
+	}
+	return b
+}
+
+// Actor is the authenticated caller and the organization it acts in
+type Actor struct {
+	UserID   uint
+	OrgID    uint
+	Role     Role
+	APIKeyID uint // 0 for JWT sessions
+
+	// Scopes further restricts the role (API keys); nil means no restriction
+	Scopes []Permission
+	// Projects restricts access to named repositories; nil means all
+	Projects []string
+}
+
+func (a Actor) Can(p Permission) bool {
+	if !a.Role.Can(p) {
+		return false
+	}
+	// раскрытие значений — только для сессий пользователя, даже если
+	// ключ без scopes и его роль это разрешает
+	if p == PermFindingsReveal && a.APIKeyID != 0 {
+		return false
+	}
+	if a.Scopes == nil {
+		return true
+	}
+	for _, s := range a.Scopes {
+		if s == p {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessProject reports whether the actor may touch analyses of repo
+func (a Actor) CanAccessProject(repo string) bool {
+	if a.Projects == nil {
+		return true
+	}
+	for _, p := range a.Projects {
+		if p == repo {
+			return true
+		}
+	}
+	return false
+}
+
+const actorLocalKey = "actor"
+
+// SetActor stores the resolved actor in the request context
+func SetActor(c *fiber.Ctx, a Actor) {
+	c.Locals(actorLocalKey, a)
+}
+
+// ActorFromCtx returns the actor resolved by the org middleware
+func ActorFromCtx(c *fiber.Ctx) (Actor, bool) {
+	a, ok := c.Locals(actorLocalKey).(Actor)
+	return a, ok
+}
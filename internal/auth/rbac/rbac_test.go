@@ -0,0 +1,45 @@
+package rbac
+
+import "testing"
+
+func TestActorCan(t *testing.T) {
+	tests := []struct {
+		name  string
+		actor Actor
+		perm  Permission
+		want  bool
+	}{
+		{"session reviewer reveals", Actor{Role: RoleReviewer}, PermFindingsReveal, true},
+		{"session admin reveals", Actor{Role: RoleAdmin}, PermFindingsReveal, true},
+		{"session viewer can't reveal", Actor{Role: RoleViewer}, PermFindingsReveal, false},
+		{"unscoped admin key can't reveal", Actor{Role: RoleAdmin, APIKeyID: 1}, PermFindingsReveal, false},
+		{"unscoped reviewer key can't reveal", Actor{Role: RoleReviewer, APIKeyID: 1}, PermFindingsReveal, false},
+		{
+			"scoped key can't reveal either",
+			Actor{Role: RoleAdmin, APIKeyID: 1, Scopes: []Permission{PermFindingsReveal}},
+			PermFindingsReveal,
+			false,
+		},
+		{"unscoped key keeps role permissions", Actor{Role: RoleReviewer, APIKeyID: 1}, PermAnalysesWrite, true},
+		{
+			"scopes restrict the role",
+			Actor{Role: RoleAdmin, APIKeyID: 1, Scopes: []Permission{PermAnalysesRead}},
+			PermAnalysesWrite,
+			false,
+		},
+		{
+			"scopes don't exceed the role",
+			Actor{Role: RoleViewer, APIKeyID: 1, Scopes: []Permission{PermAnalysesWrite}},
+			PermAnalysesWrite,
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.actor.Can(tt.perm); got != tt.want {
+				t.Errorf("Can(%s) = %v, want %v", tt.perm, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,11 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+
+	"mws-ai/pkg/blob"
+	"mws-ai/pkg/envelope"
+	"mws-ai/pkg/ratelimit"
 )
 
 type Config struct {
@@ -15,6 +20,66 @@ type Config struct {
 	JWTAccessExpireMin    int
 	JWTRefreshExpireHours int
 
+	// JWT signing: HS256 (shared secret) or RS256 / EdDSA with rotated keys
+	JWTAlg              string
+	JWTKeyDir           string
+	JWTKeyRotationHours int
+
+	// OIDC single sign-on; disabled while OIDCIssuer is empty
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       []string
+	OIDCEmailClaim   string
+	OIDCGroupsClaim  string
+	// OIDCGroupRoles maps provider groups to roles in OIDCOrgID
+	// (OIDC_GROUP_ROLES="sec-admins=admin,appsec=reviewer")
+	OIDCGroupRoles map[string]string
+	OIDCOrgID      int
+
+	// Password policy
+	PasswordMinLength     int
+	PasswordRequireUpper  bool
+	PasswordRequireLower  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+
+	// Login lockout: after N failures within the window the account (or IP)
+	// is locked, doubling from base up to max with every further failure
+	LoginMaxFailures      int
+	LoginIPMaxFailures    int
+	LoginFailureWindowMin int
+	LoginLockoutBaseSec   int
+	LoginLockoutMaxMin    int
+
+	// Outgoing email: "log" and "file" are for development
+	MailDriver   string
+	MailFrom     string
+	MailDir      string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUser     string
+	SMTPPassword string
+
+	// Email verification and password reset
+	AppURL                    string
+	EmailVerificationRequired bool
+	EmailVerifyTTLHours       int
+	PasswordResetTTLMin       int
+
+	// MFAIssuer is the account label shown in authenticator apps
+	MFAIssuer string
+
+	// Rate limiting: token buckets "N/duration" per budget ("off" disables);
+	// store is "memory" (per instance) or "postgres" (shared)
+	RateLimitStore  string
+	RateLimitUpload string
+	RateLimitRead   string
+	RateLimitAuth   string
+	RateLimitIP     string // every authenticated route, before auth
+	RateLimitManage string // org, member, key, mfa and suppression management
+
 	DBHost     string
 	DBPort     string
 	DBUser     string
@@ -22,11 +87,47 @@ type Config struct {
 	DBName     string
 
 	UploadDir string
+	// UploadMaxMB caps the size of an uploaded report; archives are also
+	// capped by their unpacked size and member count
+	UploadMaxMB         int
+	UploadMaxUnpackedMB int
+	UploadMaxFiles      int
+
+	// Optional source archive sent with a report: SourceContextLines lines
+	// around each finding are attached to it
+	SourceContextLines  int
+	SourceMaxMB         int
+	SourceMaxUnpackedMB int
 
-	// External services
-	HeuristicURL string
-	MLURL        string
-	LLMURL       string
+	// Raw report storage: "local" (StorageDir) or "s3" (any S3-compatible
+	// service); reports older than ReportRetentionDays are purged, 0 keeps them
+	StorageDriver       string
+	StorageDir          string
+	S3Endpoint          string
+	S3Region            string
+	S3Bucket            string
+	S3AccessKey         string
+	S3SecretKey         string
+	S3PathStyle         bool
+	ReportRetentionDays int
+
+	// Encryption of secret values at rest: ENCRYPTION_KEYS="k2:base64,k1:base64"
+	// (32-byte AES keys); new values use ENCRYPTION_PRIMARY_KEY, the rest
+	// only decrypt until cmd/reencrypt has rewrapped them
+	EncryptionKeys       string
+	EncryptionPrimaryKey string
+
+	// External services; HEURISTIC_DRIVER=native runs the heuristic stage
+	// in-process instead of calling HeuristicURL
+	HeuristicDriver string
+	HeuristicURL    string
+	MLURL           string
+	LLMURL          string
+
+	// LLM_REDACTION=redacted sends the LLM only a description of each
+	// secret (length, charset, entropy, prefix, masked context); raw
+	// sends the value itself and is meant for self-hosted models
+	LLMRedaction string
 }
 
 func Load() (*Config, []string, error) {
@@ -41,17 +142,94 @@ func Load() (*Config, []string, error) {
 		JWTAccessExpireMin:    getEnvIntWithWarn("JWT_ACCESS_EXPIRE_MIN", 15, &warnings),
 		JWTRefreshExpireHours: getEnvIntWithWarn("JWT_REFRESH_EXPIRE_HOURS", 168, &warnings),
 
+		JWTAlg:              getEnvWithWarn("JWT_ALG", "HS256", &warnings),
+		JWTKeyDir:           getEnvWithWarn("JWT_KEY_DIR", "keys/jwt", &warnings),
+		JWTKeyRotationHours: getEnvIntWithWarn("JWT_KEY_ROTATION_HOURS", 720, &warnings),
+
+		OIDCIssuer:       os.Getenv("OIDC_ISSUER"),
+		OIDCClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		OIDCClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		OIDCScopes:       strings.Fields(getEnvDefault("OIDC_SCOPES", "openid email profile")),
+		OIDCEmailClaim:   getEnvDefault("OIDC_EMAIL_CLAIM", "email"),
+		OIDCGroupsClaim:  getEnvDefault("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCGroupRoles:   parsePairs(os.Getenv("OIDC_GROUP_ROLES")),
+		OIDCOrgID:        getEnvIntDefault("OIDC_ORG_ID", 0),
+
+		PasswordMinLength:     getEnvIntDefault("PASSWORD_MIN_LENGTH", 10),
+		PasswordRequireUpper:  getEnvBoolDefault("PASSWORD_REQUIRE_UPPER", true),
+		PasswordRequireLower:  getEnvBoolDefault("PASSWORD_REQUIRE_LOWER", true),
+		PasswordRequireDigit:  getEnvBoolDefault("PASSWORD_REQUIRE_DIGIT", true),
+		PasswordRequireSymbol: getEnvBoolDefault("PASSWORD_REQUIRE_SYMBOL", false),
+
+		LoginMaxFailures:      getEnvIntDefault("LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures:    getEnvIntDefault("LOGIN_IP_MAX_FAILURES", 50),
+		LoginFailureWindowMin: getEnvIntDefault("LOGIN_FAILURE_WINDOW_MIN", 15),
+		LoginLockoutBaseSec:   getEnvIntDefault("LOGIN_LOCKOUT_BASE_SEC", 30),
+		LoginLockoutMaxMin:    getEnvIntDefault("LOGIN_LOCKOUT_MAX_MIN", 60),
+
+		MailDriver:   getEnvWithWarn("MAIL_DRIVER", "log", &warnings),
+		MailFrom:     getEnvDefault("MAIL_FROM", "MWS AI <no-reply@localhost>"),
+		MailDir:      getEnvDefault("MAIL_DIR", "mail"),
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     getEnvIntDefault("SMTP_PORT", 587),
+		SMTPUser:     os.Getenv("SMTP_USER"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+
+		AppURL:                    getEnvWithWarn("APP_URL", "http://localhost:8080", &warnings),
+		EmailVerificationRequired: getEnvBoolDefault("EMAIL_VERIFICATION_REQUIRED", true),
+		EmailVerifyTTLHours:       getEnvIntDefault("EMAIL_VERIFY_TTL_HOURS", 48),
+		PasswordResetTTLMin:       getEnvIntDefault("PASSWORD_RESET_TTL_MIN", 30),
+
+		MFAIssuer: getEnvDefault("MFA_ISSUER", "MWS AI"),
+
+		RateLimitStore:  getEnvWithWarn("RATE_LIMIT_STORE", "memory", &warnings),
+		RateLimitUpload: getEnvDefault("RATE_LIMIT_UPLOAD", "10/1m"),
+		RateLimitRead:   getEnvDefault("RATE_LIMIT_READ", "300/1m"),
+		RateLimitAuth:   getEnvDefault("RATE_LIMIT_AUTH", "30/1m"),
+		RateLimitIP:     getEnvDefault("RATE_LIMIT_IP", "600/1m"),
+		RateLimitManage: getEnvDefault("RATE_LIMIT_MANAGE", "60/1m"),
+
 		DBHost:     getEnvWithWarn("DB_HOST", "localhost", &warnings),
 		DBPort:     getEnvWithWarn("DB_PORT", "5432", &warnings),
 		DBUser:     getEnvWithWarn("DB_USER", "postgres", &warnings),
 		DBPassword: getEnvWithWarn("DB_PASSWORD", "password", &warnings),
 		DBName:     getEnvWithWarn("DB_NAME", "mws_ai", &warnings),
 
-		UploadDir: getEnvWithWarn("UPLOAD_DIR", "uploads", &warnings),
+		UploadDir:           getEnvWithWarn("UPLOAD_DIR", "uploads", &warnings),
+		UploadMaxMB:         getEnvIntDefault("UPLOAD_MAX_MB", 20),
+		UploadMaxUnpackedMB: getEnvIntDefault("UPLOAD_MAX_UNPACKED_MB", 200),
+		UploadMaxFiles:      getEnvIntDefault("UPLOAD_MAX_FILES", 20),
+
+		SourceContextLines:  getEnvIntDefault("SOURCE_CONTEXT_LINES", 5),
+		SourceMaxMB:         getEnvIntDefault("SOURCE_MAX_MB", 50),
+		SourceMaxUnpackedMB: getEnvIntDefault("SOURCE_MAX_UNPACKED_MB", 500),
+
+		StorageDriver:       getEnvDefault("STORAGE_DRIVER", "local"),
+		StorageDir:          getEnvDefault("STORAGE_DIR", "storage"),
+		S3Endpoint:          os.Getenv("S3_ENDPOINT"),
+		S3Region:            getEnvDefault("S3_REGION", "us-east-1"),
+		S3Bucket:            os.Getenv("S3_BUCKET"),
+		S3AccessKey:         os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey:         os.Getenv("S3_SECRET_KEY"),
+		S3PathStyle:         getEnvBoolDefault("S3_PATH_STYLE", true),
+		ReportRetentionDays: getEnvIntDefault("REPORT_RETENTION_DAYS", 30),
+
+		EncryptionKeys:       os.Getenv("ENCRYPTION_KEYS"),
+		EncryptionPrimaryKey: os.Getenv("ENCRYPTION_PRIMARY_KEY"),
 
-		HeuristicURL: getEnvWithWarn("HEURISTIC_URL", "http://localhost:8081", &warnings),
-		MLURL:        getEnvWithWarn("ML_URL", "http://localhost:8082", &warnings),
-		LLMURL:       getEnvWithWarn("LLM_URL", "http://localhost:8083", &warnings),
+		HeuristicDriver: getEnvDefault("HEURISTIC_DRIVER", "http"),
+		HeuristicURL:    getEnvWithWarn("HEURISTIC_URL", "http://localhost:8081", &warnings),
+		MLURL:           getEnvWithWarn("ML_URL", "http://localhost:8082", &warnings),
+		LLMURL:          getEnvWithWarn("LLM_URL", "http://localhost:8083", &warnings),
+		LLMRedaction:    getEnvDefault("LLM_REDACTION", "redacted"),
+	}
+
+	if cfg.LLMRedaction == "raw" && cfg.AppEnv != "dev" {
+		warnings = append(warnings, "LLM_REDACTION=raw — secret values are sent to the LLM service")
+	}
+	if cfg.EncryptionKeys == "" && cfg.AppEnv == "dev" {
+		warnings = append(warnings, "ENCRYPTION_KEYS not set — secret values are stored in plaintext")
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -62,15 +240,112 @@ func Load() (*Config, []string, error) {
 }
 
 func (c *Config) Validate() error {
-	if c.JWTSecret == "" {
-		return fmt.Errorf("JWT_SECRET is required")
+	switch c.JWTAlg {
+	case "HS256":
+		if c.JWTSecret == "" {
+			return fmt.Errorf("JWT_SECRET is required for HS256")
+		}
+	case "RS256", "EdDSA":
+		if c.JWTKeyDir == "" {
+			return fmt.Errorf("JWT_KEY_DIR is required for %s", c.JWTAlg)
+		}
+	default:
+		return fmt.Errorf("JWT_ALG must be HS256, RS256 or EdDSA")
+	}
+	if c.OIDCEnabled() {
+		if c.OIDCClientID == "" || c.OIDCRedirectURL == "" {
+			return fmt.Errorf("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required with OIDC_ISSUER")
+		}
+		for group, role := range c.OIDCGroupRoles {
+			switch role {
+			case "viewer", "reviewer", "admin":
+			default:
+				return fmt.Errorf("OIDC_GROUP_ROLES: invalid role %q for group %q", role, group)
+			}
+		}
+		if len(c.OIDCGroupRoles) > 0 && c.OIDCOrgID <= 0 {
+			return fmt.Errorf("OIDC_ORG_ID is required with OIDC_GROUP_ROLES")
+		}
+	}
+	if c.PasswordMinLength < 8 {
+		return fmt.Errorf("PASSWORD_MIN_LENGTH must be at least 8")
+	}
+	if c.LoginMaxFailures < 1 || c.LoginIPMaxFailures < 1 ||
+		c.LoginFailureWindowMin < 1 || c.LoginLockoutBaseSec < 1 || c.LoginLockoutMaxMin < 1 {
+		return fmt.Errorf("LOGIN_* lockout settings must be positive")
+	}
+	switch c.MailDriver {
+	case "log", "file":
+	case "smtp":
+		if c.SMTPHost == "" {
+			return fmt.Errorf("SMTP_HOST is required for MAIL_DRIVER=smtp")
+		}
+	default:
+		return fmt.Errorf("MAIL_DRIVER must be log, file or smtp")
+	}
+	if c.EmailVerifyTTLHours < 1 || c.PasswordResetTTLMin < 1 {
+		return fmt.Errorf("EMAIL_VERIFY_TTL_HOURS and PASSWORD_RESET_TTL_MIN must be positive")
+	}
+	if c.RateLimitStore != "memory" && c.RateLimitStore != "postgres" {
+		return fmt.Errorf("RATE_LIMIT_STORE must be memory or postgres")
+	}
+	for name, v := range map[string]string{
+		"RATE_LIMIT_UPLOAD": c.RateLimitUpload,
+		"RATE_LIMIT_READ":   c.RateLimitRead,
+		"RATE_LIMIT_AUTH":   c.RateLimitAuth,
+		"RATE_LIMIT_IP":     c.RateLimitIP,
+		"RATE_LIMIT_MANAGE": c.RateLimitManage,
+	} {
+		if v == "off" {
+			continue
+		}
+		if _, err := ratelimit.ParseLimit(v); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if c.UploadMaxMB < 1 || c.UploadMaxUnpackedMB < 1 || c.UploadMaxFiles < 1 {
+		return fmt.Errorf("UPLOAD_MAX_MB, UPLOAD_MAX_UNPACKED_MB and UPLOAD_MAX_FILES must be positive")
+	}
+	if c.SourceContextLines < 0 || c.SourceMaxMB < 1 || c.SourceMaxUnpackedMB < 1 {
+		return fmt.Errorf("SOURCE_CONTEXT_LINES must not be negative, SOURCE_MAX_MB and SOURCE_MAX_UNPACKED_MB must be positive")
+	}
+	switch c.StorageDriver {
+	case "local":
+	case "s3":
+		if c.S3Endpoint == "" || c.S3Bucket == "" || c.S3AccessKey == "" || c.S3SecretKey == "" {
+			return fmt.Errorf("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY are required for STORAGE_DRIVER=s3")
+		}
+	default:
+		return fmt.Errorf("STORAGE_DRIVER must be local or s3")
+	}
+	if c.ReportRetentionDays < 0 {
+		return fmt.Errorf("REPORT_RETENTION_DAYS must not be negative")
+	}
+	if c.EncryptionKeys == "" {
+		if c.AppEnv != "dev" {
+			return fmt.Errorf("ENCRYPTION_KEYS is required outside of dev")
+		}
+	} else if _, err := c.Keyring(); err != nil {
+		return fmt.Errorf("ENCRYPTION_KEYS: %w", err)
 	}
 	if c.DBHost == "" || c.DBUser == "" || c.DBName == "" {
 		return fmt.Errorf("database configuration is incomplete")
 	}
-	if c.HeuristicURL == "" || c.MLURL == "" || c.LLMURL == "" {
+	switch c.HeuristicDriver {
+	case "native":
+	case "http":
+		if c.HeuristicURL == "" {
+			return fmt.Errorf("HEURISTIC_URL is required for HEURISTIC_DRIVER=http")
+		}
+	default:
+		return fmt.Errorf("HEURISTIC_DRIVER must be http or native")
+	}
+	if c.MLURL == "" || c.LLMURL == "" {
 		return fmt.Errorf("external service URLs are required")
 	}
+	if c.LLMRedaction != "redacted" && c.LLMRedaction != "raw" {
+		return fmt.Errorf("LLM_REDACTION must be redacted or raw")
+	}
 	return nil
 }
 
@@ -81,10 +356,51 @@ func (c *Config) DBConnString() string {
 	)
 }
 
+func (c *Config) OIDCEnabled() bool {
+	return c.OIDCIssuer != ""
+}
+
 func (c *Config) JWTSecretBytes() []byte {
 	return []byte(c.JWTSecret)
 }
 
+// Keyring builds the value encryption keyring; nil when encryption is
+// off (dev only)
+func (c *Config) Keyring() (*envelope.Keyring, error) {
+	if c.EncryptionKeys == "" {
+		return nil, nil
+	}
+
+	keys, err := envelope.ParseKeys(c.EncryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	primary := c.EncryptionPrimaryKey
+	if primary == "" && len(keys) == 1 {
+		for id := range keys {
+			primary = id
+		}
+	}
+
+	return envelope.NewKeyring(primary, keys)
+}
+
+// BlobStore opens the storage for raw reports
+func (c *Config) BlobStore() (blob.Store, error) {
+	if c.StorageDriver == "s3" {
+		return blob.NewS3Store(blob.S3Config{
+			Endpoint:  c.S3Endpoint,
+			Region:    c.S3Region,
+			Bucket:    c.S3Bucket,
+			AccessKey: c.S3AccessKey,
+			SecretKey: c.S3SecretKey,
+			PathStyle: c.S3PathStyle,
+		})
+	}
+	return blob.NewLocalStore(c.StorageDir)
+}
+
 func getEnvWithWarn(key, defaultValue string, warnings *[]string) string {
 	v := os.Getenv(key)
 	if v == "" {
@@ -107,3 +423,44 @@ func getEnvIntWithWarn(key string, defaultVal int, warnings *[]string) int {
 	}
 	return i
 }
+
+// optional settings: no warning when unset
+
+func getEnvDefault(key, defaultValue string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return defaultValue
+}
+
+func getEnvIntDefault(key string, defaultVal int) int {
+	i, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultVal
+	}
+	return i
+}
+
+func getEnvBoolDefault(key string, defaultVal bool) bool {
+	b, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultVal
+	}
+	return b
+}
+
+// parsePairs reads "k1=v1,k2=v2"
+func parsePairs(v string) map[string]string {
+	out := map[string]string{}
+	for _, pair := range strings.Split(v, ",") {
+		k, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		k, val = strings.TrimSpace(k), strings.TrimSpace(val)
+		if k != "" {
+			out[k] = val
+		}
+	}
+	return out
+}
@@ -28,12 +28,95 @@ func Init(cfg *config.Config) (*gorm.DB, error) {
 func Migrate(db *gorm.DB) error {
 	logger.Log.Info().Msg("Running DB migrations...")
 
-	return db.AutoMigrate(
+	// пользователи, зарегистрированные до появления подтверждения email,
+	// считаются подтверждёнными — иначе они не смогут войти
+	verifyExisting := db.Migrator().HasTable(&models.User{}) &&
+		!db.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt")
+
+	if err := db.AutoMigrate(
 		&models.User{},
+		&models.Organization{},
+		&models.Membership{},
 		&models.Analysis{},
 		&models.Finding{},
 		&models.ApiKey{},
-	)
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.OIDCLoginState{},
+		&models.LoginAttempt{},
+		&models.AuditLog{},
+		&models.RecoveryCode{},
+		&models.RateLimitBucket{},
+		&models.SuppressionRule{},
+	); err != nil {
+		return err
+	}
+
+	if verifyExisting {
+		if err := db.Model(&models.User{}).
+			Where("email_verified_at IS NULL").
+			Update("email_verified_at", gorm.Expr("created_at")).Error; err != nil {
+			return err
+		}
+		logger.Log.Info().Msg("existing users marked as email verified")
+	}
+
+	// file_path никогда не заполнялся; его место занял storage_key
+	if db.Migrator().HasColumn(&models.Analysis{}, "file_path") {
+		if err := db.Migrator().DropColumn(&models.Analysis{}, "file_path"); err != nil {
+			return err
+		}
+	}
+
+	return backfillOrganizations(db)
+}
+
+// backfillOrganizations gives every user without a membership a personal
+// organization and moves their analyses and API keys into it
+func backfillOrganizations(db *gorm.DB) error {
+	var users []models.User
+
+	if err := db.
+		Where("id NOT IN (?)", db.Model(&models.Membership{}).Select("user_id")).
+		Find(&users).
+		Error; err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			org := models.Organization{Name: u.Email}
+			if err := tx.Create(&org).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Create(&models.Membership{
+				OrganizationID: org.ID,
+				UserID:         u.ID,
+				Role:           "admin",
+			}).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Model(&models.Analysis{}).
+				Where("user_id = ? AND (organization_id = 0 OR organization_id IS NULL)", u.ID).
+				Update("organization_id", org.ID).Error; err != nil {
+				return err
+			}
+
+			return tx.Model(&models.ApiKey{}).
+				Where("user_id = ? AND (organization_id = 0 OR organization_id IS NULL)", u.ID).
+				Update("organization_id", org.ID).Error
+		})
+		if err != nil {
+			logger.Log.Error().Err(err).Uint("user_id", u.ID).Msg("organization backfill failed")
+			return err
+		}
+
+		logger.Log.Info().Uint("user_id", u.ID).Msg("personal organization backfilled")
+	}
+
+	return nil
 }
 
 func Close(db *gorm.DB) {
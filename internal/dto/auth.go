@@ -1,13 +1,61 @@
 package dto
 
 type AuthResponse struct {
-	AccessToken  string `json:"access_token"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+
+	// set instead of the tokens when the account has 2FA:
+	// finish the login with POST /auth/mfa/verify
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token"`
+	// TOTP code or a recovery code
+	Code string `json:"code" example:"123456"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" example:"123456"`
+}
+
+type MFAStatusResponse struct {
+	Enabled           bool  `json:"enabled"`
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+}
+
+type TOTPEnrollResponse struct {
+	Secret          string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	ProvisioningURI string `json:"provisioning_uri" example:"otpauth://totp/MWS%20AI:test@example.com?secret=JBSWY3DPEHPK3PXP&issuer=MWS+AI"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	Note          string   `json:"note"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+}
+
+type LogoutRequest struct {
+	// optional: also revoke the refresh token chain of this session
 	RefreshToken string `json:"refresh_token"`
 }
 
+type EmailRequest struct {
+	Email string `json:"email" example:"test@example.com"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password" example:"N3w-Passphrase-Here"`
+}
+
 type RegisterRequest struct {
 	Email    string `json:"email" example:"test@example.com"`
-	Password string `json:"password" example:"secret123"`
+	Password string `json:"password" example:"Corr3ct-Horse-Battery"`
 }
 
 type RegisterResponse struct {
@@ -24,6 +72,48 @@ type LoginResponse struct {
 	Token string `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
 }
 
+type CreateAPIKeyRequest struct {
+	Name     string   `json:"name" example:"gitlab-ci backend"`
+	Type     string   `json:"type" example:"integration" enums:"service,integration,admin"`
+	TTLDays  int      `json:"ttl_days" example:"90"`
+	Scopes   []string `json:"scopes" example:"analyses:write,analyses:read"`
+	Projects []string `json:"projects" example:"org/backend"`
+}
+
 type CreateAPIKeyResponse struct {
-	APIKey string `json:"api_key" example:"mws_sk_1234567890abcdef"`
+	ID       uint     `json:"id" example:"5"`
+	APIKey   string   `json:"api_key" example:"mws_sk_1234567890abcdef"`
+	Name     string   `json:"name" example:"gitlab-ci backend"`
+	Type     string   `json:"type" example:"integration"`
+	Scopes   []string `json:"scopes"`
+	Projects []string `json:"projects"`
+	Expires  string   `json:"expires" example:"90d"`
+	Note     string   `json:"note"`
+}
+
+type APIKeyItem struct {
+	ID           uint     `json:"id" example:"5"`
+	Name         string   `json:"name" example:"gitlab-ci backend"`
+	Prefix       string   `json:"prefix" example:"mws_sk_1a2b3c4d"`
+	Type         string   `json:"type" example:"integration"`
+	Scopes       []string `json:"scopes"`
+	Projects     []string `json:"projects"`
+	Active       bool     `json:"active"`
+	CreatedAt    string   `json:"created_at"`
+	LastUsedAt   *string  `json:"last_used_at"`
+	ExpiresAt    *string  `json:"expires_at"`
+	RevokedAt    *string  `json:"revoked_at"`
+	ReplacedByID *uint    `json:"replaced_by_id"`
+}
+
+type RotateAPIKeyRequest struct {
+	// how long the old key keeps working, default 1440 (24h)
+	GraceMinutes *int `json:"grace_minutes" example:"60"`
+}
+
+type RotateAPIKeyResponse struct {
+	ID               uint   `json:"id" example:"6"`
+	APIKey           string `json:"api_key" example:"mws_sk_1234567890abcdef"`
+	OldKeyValidUntil string `json:"old_key_valid_until"`
+	Note             string `json:"note"`
 }
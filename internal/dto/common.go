@@ -3,3 +3,11 @@ package dto
 type ErrorResponse struct {
 	Message string `json:"message"`
 }
+
+// ValidationErrorResponse points at the invalid part of an uploaded report
+type ValidationErrorResponse struct {
+	Message string `json:"message"`
+	// File is set when the report came in an archive
+	File string `json:"file,omitempty" example:"reports/gitleaks.sarif"`
+	Path string `json:"path" example:"$.runs[0].results[3].locations[0].physicalLocation.region.startLine"`
+}
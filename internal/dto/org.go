@@ -0,0 +1,31 @@
+package dto
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" example:"Platform Security"`
+}
+
+type OrganizationItem struct {
+	ID         uint   `json:"id" example:"3"`
+	Name       string `json:"name" example:"Platform Security"`
+	Role       string `json:"role" example:"admin"`
+	RequireMFA bool   `json:"require_mfa"`
+}
+
+type UpdateOrganizationSettingsRequest struct {
+	RequireMFA *bool `json:"require_mfa" example:"true"`
+}
+
+type AddMemberRequest struct {
+	Email string `json:"email" example:"dev@example.com"`
+	Role  string `json:"role" example:"reviewer" enums:"viewer,reviewer,admin"`
+}
+
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" example:"viewer" enums:"viewer,reviewer,admin"`
+}
+
+type MemberItem struct {
+	UserID uint   `json:"user_id" example:"7"`
+	Email  string `json:"email" example:"dev@example.com"`
+	Role   string `json:"role" example:"reviewer"`
+}
@@ -0,0 +1,24 @@
+package dto
+
+type SuppressionRequest struct {
+	PathGlob    string  `json:"path_glob" example:"**/testdata/**"`
+	RuleID      string  `json:"rule_id" example:"aws-access-token"`
+	ValueRegex  string  `json:"value_regex" example:"^AKIA[0-9A-Z]{12}EXAMPLE$"`
+	Fingerprint string  `json:"fingerprint" example:"1a5d44a2"`
+	Reason      string  `json:"reason" example:"Ключи из документации AWS"`
+	ExpiresAt   *string `json:"expires_at" example:"2026-12-31T00:00:00Z"`
+}
+
+type SuppressionItem struct {
+	ID          uint    `json:"id" example:"5"`
+	PathGlob    string  `json:"path_glob,omitempty" example:"**/testdata/**"`
+	RuleID      string  `json:"rule_id,omitempty" example:"aws-access-token"`
+	ValueRegex  string  `json:"value_regex,omitempty"`
+	Fingerprint string  `json:"fingerprint,omitempty" example:"1a5d44a2"`
+	Reason      string  `json:"reason" example:"Ключи из документации AWS"`
+	CreatedByID uint    `json:"created_by_id" example:"7"`
+	ExpiresAt   *string `json:"expires_at,omitempty"`
+	Expired     bool    `json:"expired"`
+	CreatedAt   string  `json:"created_at"`
+	UpdatedAt   string  `json:"updated_at"`
+}
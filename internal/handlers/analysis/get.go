@@ -1,8 +1,12 @@
 package analysis
 
 import (
+	"errors"
 	"strconv"
+	"time"
 
+	"mws-ai/internal/auth/rbac"
+	"mws-ai/internal/dto"
 	"mws-ai/internal/services"
 	"mws-ai/pkg/logger"
 
@@ -21,10 +25,11 @@ func NewAnalysisHandler(service *services.AnalysisService) *AnalysisHandler {
 
 // Get godoc
 // @Summary Получить анализ по ID
-// @Description Возвращает Analysis вместе со списком Findings
+// @Description Возвращает анализ вместе со списком findings; значения секретов отдаются только в value_masked
 // @Tags Analysis
 // @Produce json
 // @Security BearerAuth
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
 // @Param id path int true "ID анализа"
 // @Success 200 {object} dto.AnalysisResponse
 // @Failure 401 {object} dto.ErrorResponse "Неавторизован"
@@ -48,18 +53,133 @@ func (h *AnalysisHandler) Get() fiber.Handler {
 			return fiber.ErrBadRequest
 		}
 
-		analysis, findings, err := h.service.GetDetails(uint(id))
-		if err != nil || analysis == nil {
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		analysis, findings, err := h.service.GetDetails(actor, uint(id))
+		if errors.Is(err, services.ErrAnalysisNotFound) {
 			log.Info().
+				Uint("user_id", actor.UserID).
 				Int("analysis_id", id).
 				Msg("analysis not found")
 
 			return fiber.ErrNotFound
 		}
+		if err != nil {
+			log.Error().
+				Err(err).
+				Int("analysis_id", id).
+				Msg("failed to load analysis")
+
+			return fiber.ErrInternalServerError
+		}
+
+		items := make([]dto.FindingItem, 0, len(findings))
+		for _, f := range findings {
+			items = append(items, dto.FindingItem{
+				ID:                 f.ID,
+				FilePath:           f.FilePath,
+				Line:               f.Line,
+				LineEnd:            f.LineEnd,
+				ValueMasked:        f.ValueMasked,
+				RuleID:             f.RuleID,
+				Context:            f.Context,
+				ContextStartLine:   f.ContextStartLine,
+				Severity:           f.Severity,
+				ScannerConfidence:  f.ScannerConfidence,
+				HeuristicTriggered: f.HeuristicTriggered,
+				HeuristicReason:    f.HeuristicReason,
+				EntropyClass:       f.EntropyClass,
+				Entropy:            f.EntropyValue,
+				MlVerdict:          f.MlVerdict,
+				MlConfidence:       f.MlConfidence,
+				LlmVerdict:         f.LlmVerdict,
+				LlmConfidence:      f.LlmConfidence,
+				LlmExplanation:     f.LlmExplanation,
+				FinalVerdict:       f.FinalVerdict,
+				DecisionSource:     f.DecisionSource,
+				SuppressionRuleID:  f.SuppressionRuleID,
+				SuppressionComment: f.SuppressionComment,
+				FormatValidation:   f.FormatValidation,
+				HumanVerdict:       f.HumanVerdict,
+				HumanComment:       f.HumanComment,
+				Status:             f.Status,
+			})
+		}
+
+		resp := dto.AnalysisResponse{
+			ID:         analysis.ID,
+			UserID:     analysis.UserID,
+			FileName:   analysis.FileName,
+			Repository: analysis.Repository,
+			Commit:     analysis.Commit,
+			Branch:     analysis.Branch,
+			Status:     analysis.Status,
+			TPCount:    analysis.TPCount,
+			FPCount:    analysis.FPCount,
+			UploadedAt: analysis.UploadedAt.Format(time.RFC3339),
+			Findings:   items,
+		}
+		if analysis.ReportPurgedAt != nil {
+			resp.ReportPurgedAt = analysis.ReportPurgedAt.Format(time.RFC3339)
+		}
+
+		return c.JSON(resp)
+	}
+}
+
+// RevealValue godoc
+// @Summary Показать значение секрета
+// @Description Расшифровывает и возвращает значение одного finding. В остальных ответах отдаётся только value_masked
+// @Description (начало значения, длина и отпечаток sha256). Требует права findings:reveal; каждое раскрытие попадает в аудит.
+// @Tags Analysis
+// @Produce json
+// @Security BearerAuth
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Param id path int true "ID анализа"
+// @Param finding_id path int true "ID finding"
+// @Success 200 {object} dto.FindingValueResponse
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 403 {object} dto.ErrorResponse "Нет права findings:reveal"
+// @Failure 404 {object} dto.ErrorResponse "Анализ или finding не найден"
+// @Router /analyses/{id}/findings/{finding_id}/value [get]
+func (h *AnalysisHandler) RevealValue() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		log := logger.Log.With().
+			Str("handler", "analysis.reveal_value").
+			Str("path", c.Path()).
+			Logger()
+
+		analysisID, err1 := strconv.Atoi(c.Params("id"))
+		findingID, err2 := strconv.Atoi(c.Params("finding_id"))
+		if err1 != nil || err2 != nil || analysisID <= 0 || findingID <= 0 {
+			return fiber.ErrBadRequest
+		}
+
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		value, err := h.service.RevealValue(actor, uint(analysisID), uint(findingID), c.IP())
+		if errors.Is(err, services.ErrAnalysisNotFound) || errors.Is(err, services.ErrFindingNotFound) {
+			return fiber.ErrNotFound
+		}
+		if err != nil {
+			log.Error().
+				Err(err).
+				Int("finding_id", findingID).
+				Msg("failed to reveal finding value")
+
+			return fiber.ErrInternalServerError
+		}
 
-		return c.JSON(fiber.Map{
-			"analysis": analysis,
-			"findings": findings,
+		return c.JSON(dto.FindingValueResponse{
+			FindingID: uint(findingID),
+			Value:     value,
 		})
 	}
 }
@@ -0,0 +1,142 @@
+package analysis
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mws-ai/internal/auth/rbac"
+	"mws-ai/internal/dto"
+	"mws-ai/internal/models"
+	"mws-ai/internal/repository"
+	"mws-ai/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type fakeAnalysisRepo struct {
+	repository.AnalysisRepository
+	analyses map[uint]*models.Analysis
+}
+
+func (r *fakeAnalysisRepo) GetByID(id uint) (*models.Analysis, error) {
+	return r.analyses[id], nil
+}
+
+type fakeFindingRepo struct {
+	repository.FindingRepository
+	findings []models.Finding
+}
+
+func (r *fakeFindingRepo) ListByAnalysis(uint) ([]models.Finding, error) {
+	return r.findings, nil
+}
+
+func TestGetMapsForeignAnalysisTo404(t *testing.T) {
+	analyses := &fakeAnalysisRepo{analyses: map[uint]*models.Analysis{
+		1: {ID: 1, OrganizationID: 10},
+		2: {ID: 2, OrganizationID: 20},
+	}}
+	service := services.NewAnalysisService(
+		analyses, &fakeFindingRepo{}, nil, nil, nil, nil, nil, services.SourceContext{},
+	)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		rbac.SetActor(c, rbac.Actor{UserID: 100, OrgID: 10, Role: rbac.RoleViewer})
+		return c.Next()
+	})
+	app.Get("/analysis/:id", NewAnalysisHandler(service).Get())
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/analysis/1", fiber.StatusOK},
+		{"/analysis/2", fiber.StatusNotFound},
+		{"/analysis/404", fiber.StatusNotFound},
+		{"/analysis/abc", fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.path, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("GET %s = %d, want %d", tt.path, resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetReturnsDTO(t *testing.T) {
+	verdict := "tp"
+	analyses := &fakeAnalysisRepo{analyses: map[uint]*models.Analysis{
+		1: {
+			ID: 1, OrganizationID: 10, UserID: 100, StorageKey: "reports/1",
+			FileName: "gitleaks.sarif", Status: "done", TPCount: 1,
+			UploadedAt: time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC),
+		},
+	}}
+	findings := &fakeFindingRepo{findings: []models.Finding{{
+		ID: 7, AnalysisID: 1, FilePath: "config.yml", Line: 3, RuleID: "aws-access-key",
+		Value: "sealed:v1:secret", ValueMasked: "AKIA…[20 chars, sha256:3f2a9c1e]",
+		FinalVerdict: &verdict, DecisionSource: "ml", Status: "processed",
+	}}}
+	service := services.NewAnalysisService(
+		analyses, findings, nil, nil, nil, nil, nil, services.SourceContext{},
+	)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		rbac.SetActor(c, rbac.Actor{UserID: 100, OrgID: 10, Role: rbac.RoleViewer})
+		return c.Next()
+	})
+	app.Get("/analysis/:id", NewAnalysisHandler(service).Get())
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/analysis/1", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body dto.AnalysisResponse
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatal(err)
+	}
+
+	// только поля DTO, без внутренних полей моделей
+	var raw struct {
+		Analysis       any              `json:"analysis"`
+		OrganizationID any              `json:"organization_id"`
+		UpdatedAt      any              `json:"updated_at"`
+		Findings       []map[string]any `json:"findings"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw.Analysis != nil || raw.OrganizationID != nil || raw.UpdatedAt != nil {
+		t.Errorf("response exposes model fields: %s", data)
+	}
+	if _, ok := raw.Findings[0]["value"]; ok {
+		t.Errorf("finding exposes value: %s", data)
+	}
+
+	if body.UploadedAt != "2026-05-01T12:00:00Z" {
+		t.Errorf("uploaded_at = %q", body.UploadedAt)
+	}
+	f := body.Findings[0]
+	if f.ID != 7 || f.ValueMasked == "" || f.FinalVerdict == nil || *f.FinalVerdict != "tp" || f.DecisionSource != "ml" {
+		t.Errorf("finding = %+v", f)
+	}
+}
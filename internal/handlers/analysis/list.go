@@ -1,18 +1,46 @@
 package analysis
 
 import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"mws-ai/internal/auth/rbac"
+	"mws-ai/internal/dto"
+	"mws-ai/internal/repository"
 	"mws-ai/pkg/logger"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var allowedStatuses = map[string]bool{
+	"pending":    true,
+	"processing": true,
+	"done":       true,
+	"failed":     true,
+}
+
 // List godoc
-// @Summary Получить список анализов пользователя
-// @Description Возвращает список анализов, созданных текущим пользователем
+// @Summary Получить список анализов организации
+// @Description Возвращает страницу анализов текущей организации с фильтрами и сводкой TP/FP по всей выборке
 // @Tags Analysis
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {array} dto.AnalysisListItem
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Param page query int false "Номер страницы (с 1)" default(1)
+// @Param page_size query int false "Размер страницы (макс. 100)" default(20)
+// @Param status query string false "Статус анализа" Enums(pending, processing, done, failed)
+// @Param from query string false "Начало периода (RFC3339 или YYYY-MM-DD)"
+// @Param to query string false "Конец периода (RFC3339 или YYYY-MM-DD, день включительно)"
+// @Param q query string false "Поиск по имени файла или репозиторию"
+// @Success 200 {object} dto.AnalysisListResponse
+// @Failure 400 {object} dto.ErrorResponse "Некорректные параметры"
 // @Failure 401 {object} dto.ErrorResponse "Неавторизован"
 // @Router /analysis [get]
 func (h *AnalysisHandler) List() fiber.Handler {
@@ -25,27 +53,141 @@ func (h *AnalysisHandler) List() fiber.Handler {
 
 		log.Debug().Msg("list analyses request received")
 
-		userID := c.Locals("user_id").(uint)
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
 
 		log.Debug().
-			Uint("user_id", userID).
+			Uint("user_id", actor.UserID).
+			Uint("org_id", actor.OrgID).
 			Msg("user authorized")
 
-		analyses, err := h.service.ListByUser(userID)
+		filter, page, pageSize, err := parseListQuery(c)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Uint("user_id", actor.UserID).
+				Msg("invalid list query")
+
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		analyses, summary, err := h.service.List(actor, filter)
 		if err != nil {
 			log.Error().
 				Err(err).
-				Uint("user_id", userID).
+				Uint("org_id", actor.OrgID).
 				Msg("failed to list analyses")
 
 			return fiber.ErrInternalServerError
 		}
 
+		items := make([]dto.AnalysisListItem, 0, len(analyses))
+		for _, a := range analyses {
+			items = append(items, dto.AnalysisListItem{
+				ID:         a.ID,
+				FileName:   a.FileName,
+				Repository: a.Repository,
+				Commit:     a.Commit,
+				Branch:     a.Branch,
+				Status:     a.Status,
+				TPCount:    a.TPCount,
+				FPCount:    a.FPCount,
+				UploadedAt: a.UploadedAt.Format(time.RFC3339),
+			})
+		}
+
 		log.Info().
-			Uint("user_id", userID).
-			Int("count", len(analyses)).
+			Uint("user_id", actor.UserID).
+			Uint("org_id", actor.OrgID).
+			Int("count", len(items)).
+			Int64("total", summary.Total).
 			Msg("analyses list returned")
 
-		return c.JSON(analyses)
+		return c.JSON(dto.AnalysisListResponse{
+			Items:    items,
+			Page:     page,
+			PageSize: pageSize,
+			Summary: dto.AnalysisListSummary{
+				Total:   summary.Total,
+				TPCount: summary.TPCount,
+				FPCount: summary.FPCount,
+			},
+		})
+	}
+}
+
+func parseListQuery(c *fiber.Ctx) (repository.AnalysisFilter, int, int, error) {
+	var filter repository.AnalysisFilter
+
+	page, err := parsePositiveInt(c.Query("page"), 1)
+	if err != nil {
+		return filter, 0, 0, errors.New("page must be a positive integer")
+	}
+
+	pageSize, err := parsePositiveInt(c.Query("page_size"), defaultPageSize)
+	if err != nil {
+		return filter, 0, 0, errors.New("page_size must be a positive integer")
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	filter.Limit = pageSize
+	filter.Offset = (page - 1) * pageSize
+
+	if status := c.Query("status"); status != "" {
+		if !allowedStatuses[status] {
+			return filter, 0, 0, errors.New("unknown status: " + status)
+		}
+		filter.Status = status
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, _, err := parseDate(from)
+		if err != nil {
+			return filter, 0, 0, errors.New("invalid from date")
+		}
+		filter.From = &t
+	}
+
+	if to := c.Query("to"); to != "" {
+		t, dateOnly, err := parseDate(to)
+		if err != nil {
+			return filter, 0, 0, errors.New("invalid to date")
+		}
+		// "to=2024-05-01" включает весь день
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.To = &t
+	}
+
+	filter.Search = strings.TrimSpace(c.Query("q"))
+
+	return filter, page, pageSize, nil
+}
+
+func parsePositiveInt(raw string, def int) (int, error) {
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 1 {
+		return 0, strconv.ErrSyntax
+	}
+	return v, nil
+}
+
+// parseDate accepts RFC3339 timestamps and plain YYYY-MM-DD dates
+func parseDate(raw string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, false, err
 	}
+	return t, true, nil
 }
@@ -1,40 +1,83 @@
 package analysis
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
+	"mws-ai/internal/auth/rbac"
+	"mws-ai/internal/dto"
+	"mws-ai/internal/sarif"
 	"mws-ai/internal/services"
+	"mws-ai/internal/source"
 	"mws-ai/pkg/logger"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+const (
+	// maxFileNameLen keeps stored names well under filesystem limits
+	maxFileNameLen = 100
+	// sniffLen is how much of the file is read to check its type
+	sniffLen = 512
+	// maxRefLen bounds repository and branch names
+	maxRefLen = 255
+)
+
+// commitPattern accepts abbreviated and full SHA-1 / SHA-256 commit ids
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{7,64}$`)
+
 type UploadHandler struct {
 	service   *services.AnalysisService
 	uploadDir string
+	maxSize   int64
+	maxSource int64
+	limits    sarif.Limits
 }
 
-func NewUploadHandler(service *services.AnalysisService, uploadDir string) *UploadHandler {
+func NewUploadHandler(
+	service *services.AnalysisService,
+	uploadDir string,
+	maxSize int64,
+	maxSource int64,
+	limits sarif.Limits,
+) *UploadHandler {
 	return &UploadHandler{
 		service:   service,
 		uploadDir: uploadDir,
+		maxSize:   maxSize,
+		maxSource: maxSource,
+		limits:    limits,
 	}
 }
 
 // Upload godoc
 // @Summary Загрузить SARIF файл на анализ
-// @Description Принимает SARIF JSON, создаёт Analysis и запускает pipeline обработки
+// @Description Принимает SARIF JSON, проверяет его по схеме SARIF 2.1.0, создаёт Analysis и запускает pipeline обработки.
+// @Description Файл можно сжать gzip или zstd либо упаковать несколько SARIF в zip — все они попадут в один анализ.
+// @Description При ошибке валидации в ответе указан JSONPath проблемного поля (и файл внутри архива).
 // @Tags Analysis
 // @Accept multipart/form-data
 // @Produce json
 // @Security BearerAuth
-// @Param file formData file true "SARIF файл"
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Param file formData file true "SARIF файл (.sarif, .sarif.gz, .sarif.zst или .zip)"
+// @Param repository formData string false "Репозиторий, к которому относится отчёт"
+// @Param commit formData string false "SHA коммита"
+// @Param branch formData string false "Ветка"
+// @Param source formData file false "Архив исходников (.zip или .tar.gz) — строки вокруг каждой находки попадут в её контекст"
 // @Success 200 {object} dto.UploadAnalysisResponse
-// @Failure 400 {object} dto.ErrorResponse
+// @Failure 400 {object} dto.ValidationErrorResponse "Отчёт не прошёл валидацию"
 // @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse "Недостаточно прав"
+// @Failure 413 {object} dto.ErrorResponse "Файл или распакованный архив больше допустимого размера"
+// @Failure 415 {object} dto.ErrorResponse "Файл не является JSON или поддерживаемым архивом"
 // @Failure 500 {object} dto.ErrorResponse
 // @Router /analysis/upload [post]
 func (h *UploadHandler) Upload() fiber.Handler {
@@ -47,12 +90,12 @@ func (h *UploadHandler) Upload() fiber.Handler {
 
 		log.Debug().Msg("upload request received")
 
-		userID := c.Locals("user_id")
-		if userID == nil {
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
 			log.Warn().Msg("unauthorized upload attempt")
 			return fiber.ErrUnauthorized
 		}
-		uid := userID.(uint)
+		uid := actor.UserID
 
 		file, err := c.FormFile("file")
 		if err != nil {
@@ -60,13 +103,34 @@ func (h *UploadHandler) Upload() fiber.Handler {
 			return fiber.NewError(fiber.StatusBadRequest, "file is required")
 		}
 
+		fileName := sanitizeFileName(file.Filename)
+
 		log.Debug().
 			Uint("user_id", uid).
-			Str("filename", file.Filename).
+			Str("filename", fileName).
 			Int64("size", file.Size).
 			Msg("file received")
 
-		if err := os.MkdirAll(h.uploadDir, 0755); err != nil {
+		if file.Size > h.maxSize {
+			log.Info().Int64("size", file.Size).Msg("file too large")
+			return fiber.NewError(
+				fiber.StatusRequestEntityTooLarge,
+				fmt.Sprintf("file exceeds %d bytes", h.maxSize),
+			)
+		}
+
+		format, err := sniffFormat(file)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to read uploaded file")
+			return fiber.ErrInternalServerError
+		}
+		if format == sarif.FormatUnknown {
+			log.Info().Str("filename", fileName).Msg("unsupported file format")
+			return fiber.NewError(fiber.StatusUnsupportedMediaType, sarif.ErrUnsupportedFormat.Error())
+		}
+
+		filePath, err := h.prepareFilePath(uid, fileName)
+		if err != nil {
 			log.Error().
 				Err(err).
 				Str("upload_dir", h.uploadDir).
@@ -78,11 +142,6 @@ func (h *UploadHandler) Upload() fiber.Handler {
 			)
 		}
 
-		filePath := filepath.Join(
-			h.uploadDir,
-			fmt.Sprintf("%d_%d_%s", uid, time.Now().Unix(), file.Filename),
-		)
-
 		log.Debug().
 			Str("file_path", filePath).
 			Msg("generated file path")
@@ -104,21 +163,214 @@ func (h *UploadHandler) Upload() fiber.Handler {
 			Str("file_path", filePath).
 			Msg("file saved successfully")
 
-		analysis, err := h.service.Upload(uid, filePath)
+		meta, err := uploadMeta(
+			fileName,
+			c.FormValue("repository"),
+			c.FormValue("commit"),
+			c.FormValue("branch"),
+		)
 		if err != nil {
-			log.Error().
-				Err(err).
-				Msg("failed to create analysis")
+			_ = os.Remove(filePath)
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
 
-			return fiber.NewError(
-				fiber.StatusInternalServerError,
-				err.Error(),
-			)
+		meta.SourcePath, err = h.saveSource(c, filePath)
+		if err != nil {
+			_ = os.Remove(filePath)
+			return err
 		}
 
-		return c.JSON(fiber.Map{
-			"analysis_id": analysis.ID,
-			"status":      "uploaded",
+		return h.submit(c, actor, filePath, meta)
+	}
+}
+
+// saveSource stores the optional source archive next to the report and
+// returns its path, or "" when none was sent
+func (h *UploadHandler) saveSource(c *fiber.Ctx, reportPath string) (string, error) {
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["source"]) == 0 {
+		return "", nil
+	}
+	file := form.File["source"][0]
+
+	if file.Size > h.maxSource {
+		return "", fiber.NewError(
+			fiber.StatusRequestEntityTooLarge,
+			fmt.Sprintf("source archive exceeds %d bytes", h.maxSource),
+		)
+	}
+
+	head, err := readHead(file)
+	if err != nil {
+		return "", fiber.ErrInternalServerError
+	}
+	if !source.IsArchive(head) {
+		return "", fiber.NewError(fiber.StatusUnsupportedMediaType, source.ErrUnsupportedFormat.Error())
+	}
+
+	sourcePath := reportPath + ".src"
+	if err := c.SaveFile(file, sourcePath); err != nil {
+		logger.Log.Error().
+			Str("handler", "analysis.upload").
+			Err(err).
+			Msg("failed to save source archive")
+
+		return "", fiber.NewError(fiber.StatusInternalServerError, "cannot save file")
+	}
+
+	return sourcePath, nil
+}
+
+// prepareFilePath makes sure the upload directory exists and returns
+// a unique path for a new report
+func (h *UploadHandler) prepareFilePath(uid uint, fileName string) (string, error) {
+	if err := os.MkdirAll(h.uploadDir, 0755); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(
+		h.uploadDir,
+		fmt.Sprintf("%d_%d_%s", uid, time.Now().UnixNano(), fileName),
+	), nil
+}
+
+// submit unpacks a saved report and hands it to the service; shared by
+// the multipart and the raw body endpoints
+func (h *UploadHandler) submit(
+	c *fiber.Ctx,
+	actor rbac.Actor,
+	filePath string,
+	meta services.UploadMeta,
+) error {
+	// архив распаковывается в один SARIF рядом с исходником
+	reportPath, err := sarif.Unpack(filePath, h.limits)
+	if err != nil {
+		_ = os.Remove(filePath)
+		removeSource(meta)
+		return reportError(c, err)
+	}
+
+	// дальше файлы принадлежат сервису, он же их и удалит
+	analysis, err := h.service.Upload(actor, reportPath, meta)
+	if err != nil {
+		return reportError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"analysis_id": analysis.ID,
+		"status":      "uploaded",
+	})
+}
+
+// uploadMeta checks the optional git metadata of a report
+func uploadMeta(fileName, repository, commit, branch string) (services.UploadMeta, error) {
+	repository = strings.TrimSpace(repository)
+	commit = strings.ToLower(strings.TrimSpace(commit))
+	branch = strings.TrimPrefix(strings.TrimSpace(branch), "refs/heads/")
+
+	if commit != "" && !commitPattern.MatchString(commit) {
+		return services.UploadMeta{}, errors.New("commit must be a hex sha of 7 to 64 characters")
+	}
+	if len(repository) > maxRefLen || len(branch) > maxRefLen {
+		return services.UploadMeta{}, fmt.Errorf("repository and branch must not exceed %d characters", maxRefLen)
+	}
+
+	return services.UploadMeta{
+		FileName:   fileName,
+		Repository: repository,
+		Commit:     commit,
+		Branch:     branch,
+	}, nil
+}
+
+// sanitizeFileName keeps only the base name of the client-supplied
+// filename and replaces anything outside [A-Za-z0-9._-]
+func sanitizeFileName(name string) string {
+	// клиенты с Windows присылают полный путь через обратные слэши
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+
+	// без ведущих точек: ни скрытых файлов, ни ".."
+	safe = strings.TrimLeft(safe, ".")
+
+	// хвост важнее начала — там расширение
+	if len(safe) > maxFileNameLen {
+		safe = safe[len(safe)-maxFileNameLen:]
+	}
+	if safe == "" {
+		safe = "report.sarif"
+	}
+
+	return safe
+}
+
+// removeSource drops the source archive of a rejected upload
+func removeSource(meta services.UploadMeta) {
+	if meta.SourcePath != "" {
+		_ = os.Remove(meta.SourcePath)
+	}
+}
+
+// sniffFormat checks the head of the upload before it is written to disk
+func sniffFormat(file *multipart.FileHeader) (sarif.Format, error) {
+	head, err := readHead(file)
+	if err != nil {
+		return sarif.FormatUnknown, err
+	}
+
+	return sarif.DetectFormat(head), nil
+}
+
+func readHead(file *multipart.FileHeader) ([]byte, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return head[:n], nil
+}
+
+// reportError maps unpacking, validation and upload errors to responses
+func reportError(c *fiber.Ctx, err error) error {
+	var invalid *sarif.ValidationError
+
+	switch {
+	case errors.As(err, &invalid):
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ValidationErrorResponse{
+			Message: invalid.Message,
+			File:    invalid.File,
+			Path:    invalid.Path,
 		})
+	case errors.Is(err, sarif.ErrEmptyArchive):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, sarif.ErrArchiveTooLarge),
+		errors.Is(err, sarif.ErrTooManyFiles):
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, sarif.ErrUnsupportedFormat):
+		return fiber.NewError(fiber.StatusUnsupportedMediaType, err.Error())
+	case errors.Is(err, services.ErrProjectNotAllowed):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
 	}
+
+	logger.Log.Error().
+		Str("handler", "analysis.upload").
+		Err(err).
+		Msg("failed to create analysis")
+
+	return fiber.ErrInternalServerError
 }
@@ -0,0 +1,127 @@
+package analysis
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"strings"
+
+	"mws-ai/internal/auth/rbac"
+	"mws-ai/internal/sarif"
+	"mws-ai/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// UploadRaw godoc
+// @Summary Загрузить SARIF телом запроса
+// @Description Для CI, где multipart неудобен: тело запроса — сам SARIF JSON (можно сжать, указав Content-Encoding: gzip).
+// @Description Метаданные передаются заголовками. Проверки и обработка те же, что у /analyses/upload.
+// @Tags Analysis
+// @Accept json
+// @Accept application/sarif+json
+// @Produce json
+// @Security BearerAuth
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Param Content-Encoding header string false "gzip или identity"
+// @Param X-Repository header string false "Репозиторий, к которому относится отчёт"
+// @Param X-Commit header string false "SHA коммита"
+// @Param X-Branch header string false "Ветка"
+// @Param X-File-Name header string false "Имя отчёта (по умолчанию report.sarif)"
+// @Param report body object true "SARIF 2.1.0"
+// @Success 200 {object} dto.UploadAnalysisResponse
+// @Failure 400 {object} dto.ValidationErrorResponse "Отчёт не прошёл валидацию"
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse "Недостаточно прав"
+// @Failure 413 {object} dto.ErrorResponse "Тело или распакованный отчёт больше допустимого размера"
+// @Failure 415 {object} dto.ErrorResponse "Неподдерживаемый Content-Type или Content-Encoding"
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /analyses [post]
+func (h *UploadHandler) UploadRaw() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		log := logger.Log.With().
+			Str("handler", "analysis.upload_raw").
+			Str("path", c.Path()).
+			Logger()
+
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		mediaType, _, err := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+		if err != nil || (mediaType != "application/sarif+json" && mediaType != fiber.MIMEApplicationJSON) {
+			return fiber.NewError(
+				fiber.StatusUnsupportedMediaType,
+				"content type must be application/sarif+json or application/json",
+			)
+		}
+
+		// c.Body() распаковал бы gzip сам и без лимитов, поэтому берём сырое
+		// тело: sarif.Unpack распакует его с защитой от бомб
+		body := c.Request().Body()
+
+		if int64(len(body)) > h.maxSize {
+			return fiber.NewError(
+				fiber.StatusRequestEntityTooLarge,
+				fmt.Sprintf("body exceeds %d bytes", h.maxSize),
+			)
+		}
+
+		expected := sarif.FormatJSON
+		switch strings.ToLower(strings.TrimSpace(c.Get(fiber.HeaderContentEncoding))) {
+		case "", "identity":
+		case "gzip":
+			expected = sarif.FormatGzip
+		default:
+			return fiber.NewError(fiber.StatusUnsupportedMediaType, "content encoding must be gzip or identity")
+		}
+
+		if sarif.DetectFormat(body) != expected {
+			log.Info().
+				Str("content_encoding", c.Get(fiber.HeaderContentEncoding)).
+				Msg("body does not match the declared encoding")
+
+			return fiber.NewError(fiber.StatusBadRequest, "body does not match content type and encoding")
+		}
+
+		fileName := "report.sarif"
+		if name := c.Get("X-File-Name"); name != "" {
+			fileName = sanitizeFileName(name)
+		}
+
+		meta, err := uploadMeta(
+			fileName,
+			c.Get("X-Repository"),
+			c.Get("X-Commit"),
+			c.Get("X-Branch"),
+		)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		filePath, err := h.prepareFilePath(actor.UserID, fileName)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to create upload directory")
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to prepare upload directory")
+		}
+
+		if err := os.WriteFile(filePath, body, 0644); err != nil {
+			log.Error().
+				Err(err).
+				Str("file_path", filePath).
+				Msg("failed to save request body")
+
+			return fiber.NewError(fiber.StatusInternalServerError, "cannot save file")
+		}
+
+		log.Info().
+			Uint("user_id", actor.UserID).
+			Str("file_path", filePath).
+			Int("size", len(body)).
+			Msg("report body saved")
+
+		return h.submit(c, actor, filePath, meta)
+	}
+}
@@ -1,13 +1,25 @@
 package auth
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"mws-ai/internal/auth/rbac"
+	"mws-ai/internal/dto"
+	"mws-ai/internal/models"
 	"mws-ai/internal/services"
 	"mws-ai/pkg/logger"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+const (
+	defaultAPIKeyTTLDays = 7
+	maxAPIKeyTTLDays     = 365
+)
+
 type APIKeyHandler struct {
 	apiKeys *services.APIKeyService
 }
@@ -18,12 +30,18 @@ func NewAPIKeyHandler(apiKeys *services.APIKeyService) *APIKeyHandler {
 
 // CreateAPIKey godoc
 // @Summary Создание API-ключа
-// @Description Генерирует новый API-ключ для текущего пользователя
+// @Description Генерирует новый API-ключ в текущей организации (только admin).
+// @Description Ключ можно ограничить scopes (analyses:read, analyses:write, review:write) и списком репозиториев.
 // @Tags Auth
 // @Security BearerAuth
+// @Accept json
 // @Produce json
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Param payload body dto.CreateAPIKeyRequest false "Тип, scopes и проекты ключа"
 // @Success 201 {object} dto.CreateAPIKeyResponse
+// @Failure 400 {object} dto.ErrorResponse
 // @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 403 {object} dto.ErrorResponse "Недостаточно прав"
 // @Router /auth/api-key [post]
 func (h *APIKeyHandler) CreateAPIKey() fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -33,35 +51,51 @@ func (h *APIKeyHandler) CreateAPIKey() fiber.Handler {
 			Str("handler", "CreateAPIKey").
 			Logger()
 
-		userIDRaw := c.Locals("user_id")
-		userID, ok := userIDRaw.(uint)
+		actor, ok := rbac.ActorFromCtx(c)
 		if !ok {
-			log.Warn().
-				Interface("user_id_raw", userIDRaw).
-				Msg("user_id missing or invalid in context")
-
+			log.Warn().Msg("actor missing in context")
 			return fiber.ErrUnauthorized
 		}
 
-		//ADMIN ONLY
-		if userID != 1 {
-			log.Warn().
-				Uint("user_id", userID).
-				Msg("non-admin attempted to create API key")
-
-			return fiber.ErrForbidden
+		var req dto.CreateAPIKeyRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				log.Warn().Err(err).Msg("failed to parse api key request body")
+				return fiber.ErrBadRequest
+			}
+		}
+		if req.Type == "" {
+			req.Type = "service"
+		}
+		if req.TTLDays == 0 {
+			req.TTLDays = defaultAPIKeyTTLDays
+		}
+		if req.TTLDays < 0 || req.TTLDays > maxAPIKeyTTLDays {
+			return fiber.NewError(fiber.StatusBadRequest, "ttl_days must be between 1 and 365")
 		}
 
 		log.Info().
-			Uint("user_id", userID).
+			Uint("user_id", actor.UserID).
+			Uint("org_id", actor.OrgID).
+			Str("type", req.Type).
 			Msg("admin requested API key generation")
 
+		scopes := make([]rbac.Permission, 0, len(req.Scopes))
+		for _, sc := range req.Scopes {
+			scopes = append(scopes, rbac.Permission(sc))
+		}
+
 		// TTL
-		rawKey, err := h.apiKeys.Generate(
-			userID,
-			"service",
-			7*24*time.Hour,
-		)
+		rawKey, key, err := h.apiKeys.Generate(actor, services.APIKeyOptions{
+			Name:     req.Name,
+			Type:     req.Type,
+			TTL:      time.Duration(req.TTLDays) * 24 * time.Hour,
+			Scopes:   scopes,
+			Projects: req.Projects,
+		})
+		if errors.Is(err, services.ErrInvalidKeyType) || errors.Is(err, services.ErrInvalidScope) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
 		if err != nil {
 			log.Error().
 				Err(err).
@@ -76,11 +110,185 @@ func (h *APIKeyHandler) CreateAPIKey() fiber.Handler {
 		log.Info().
 			Msg("API key generated successfully")
 
-		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-			"api_key": rawKey,
-			"type":    "service",
-			"expires": "7d",
-			"note":    "Store this key securely. It will not be shown again.",
+		return c.Status(fiber.StatusCreated).JSON(dto.CreateAPIKeyResponse{
+			ID:       key.ID,
+			APIKey:   rawKey,
+			Name:     key.Name,
+			Type:     key.Type,
+			Scopes:   key.Scopes,
+			Projects: key.Projects,
+			Expires:  fmt.Sprintf("%dd", req.TTLDays),
+			Note:     "Store this key securely. It will not be shown again.",
+		})
+	}
+}
+
+// ListAPIKeys godoc
+// @Summary Список API-ключей организации
+// @Description Возвращает ключи текущей организации без секретов (только admin)
+// @Tags Auth
+// @Security BearerAuth
+// @Produce json
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Success 200 {array} dto.APIKeyItem
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 403 {object} dto.ErrorResponse "Недостаточно прав"
+// @Router /auth/api-keys [get]
+func (h *APIKeyHandler) ListAPIKeys() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		keys, err := h.apiKeys.List(actor)
+		if err != nil {
+			logger.Log.Error().
+				Str("component", "auth").
+				Str("handler", "ListAPIKeys").
+				Uint("org_id", actor.OrgID).
+				Err(err).
+				Msg("failed to list API keys")
+
+			return fiber.ErrInternalServerError
+		}
+
+		items := make([]dto.APIKeyItem, 0, len(keys))
+		for i := range keys {
+			items = append(items, toAPIKeyItem(&keys[i]))
+		}
+
+		return c.JSON(items)
+	}
+}
+
+// RevokeAPIKey godoc
+// @Summary Отозвать API-ключ
+// @Description Ключ перестаёт работать немедленно (только admin)
+// @Tags Auth
+// @Security BearerAuth
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Param id path int true "ID ключа"
+// @Success 204
+// @Failure 403 {object} dto.ErrorResponse "Недостаточно прав"
+// @Failure 404 {object} dto.ErrorResponse "Ключ не найден"
+// @Failure 409 {object} dto.ErrorResponse "Ключ уже отозван"
+// @Router /auth/api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+		if err != nil {
+			return fiber.ErrBadRequest
+		}
+
+		if err := h.apiKeys.Revoke(actor, uint(id)); err != nil {
+			return apiKeyError(err, actor, "RevokeAPIKey")
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// RotateAPIKey godoc
+// @Summary Ротация API-ключа
+// @Description Выпускает новый ключ с теми же настройками. Старый продолжает работать в течение grace-периода (по умолчанию 24 часа).
+// @Tags Auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Param id path int true "ID ключа"
+// @Param payload body dto.RotateAPIKeyRequest false "Grace-период"
+// @Success 201 {object} dto.RotateAPIKeyResponse
+// @Failure 403 {object} dto.ErrorResponse "Недостаточно прав"
+// @Failure 404 {object} dto.ErrorResponse "Ключ не найден"
+// @Failure 409 {object} dto.ErrorResponse "Ключ отозван или уже ротирован"
+// @Router /auth/api-keys/{id}/rotate [post]
+func (h *APIKeyHandler) RotateAPIKey() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+		if err != nil {
+			return fiber.ErrBadRequest
+		}
+
+		var req dto.RotateAPIKeyRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return fiber.ErrBadRequest
+			}
+		}
+
+		grace := services.DefaultRotationGrace
+		if req.GraceMinutes != nil {
+			grace = time.Duration(*req.GraceMinutes) * time.Minute
+		}
+
+		rawKey, key, oldValidUntil, err := h.apiKeys.Rotate(actor, uint(id), grace)
+		if err != nil {
+			return apiKeyError(err, actor, "RotateAPIKey")
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(dto.RotateAPIKeyResponse{
+			ID:               key.ID,
+			APIKey:           rawKey,
+			OldKeyValidUntil: oldValidUntil.Format(time.RFC3339),
+			Note:             "Store this key securely. It will not be shown again.",
 		})
 	}
 }
+
+func apiKeyError(err error, actor rbac.Actor, handler string) error {
+	switch {
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrAPIKeyRevoked), errors.Is(err, services.ErrAPIKeyRotated):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+
+	logger.Log.Error().
+		Str("component", "auth").
+		Str("handler", handler).
+		Uint("org_id", actor.OrgID).
+		Err(err).
+		Msg("API key operation failed")
+
+	return fiber.ErrInternalServerError
+}
+
+func toAPIKeyItem(k *models.ApiKey) dto.APIKeyItem {
+	return dto.APIKeyItem{
+		ID:           k.ID,
+		Name:         k.Name,
+		Prefix:       k.Prefix,
+		Type:         k.Type,
+		Scopes:       k.Scopes,
+		Projects:     k.Projects,
+		Active:       k.Active,
+		CreatedAt:    k.CreatedAt.Format(time.RFC3339),
+		LastUsedAt:   formatTime(k.LastUsedAt),
+		ExpiresAt:    formatTime(k.ExpiresAt),
+		RevokedAt:    formatTime(k.RevokedAt),
+		ReplacedByID: k.ReplacedByID,
+	}
+}
+
+func formatTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(time.RFC3339)
+	return &s
+}
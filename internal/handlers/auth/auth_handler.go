@@ -1,6 +1,13 @@
 package auth
 
 import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+
 	"mws-ai/internal/dto"
 	"mws-ai/internal/services"
 	"mws-ai/pkg/logger"
@@ -10,15 +17,19 @@ import (
 
 type AuthHandler struct {
 	authService *services.AuthService
+	sessions    *services.SessionService
 }
 
-func NewAuthHandler(service *services.AuthService) *AuthHandler {
-	return &AuthHandler{authService: service}
+func NewAuthHandler(service *services.AuthService, sessions *services.SessionService) *AuthHandler {
+	return &AuthHandler{
+		authService: service,
+		sessions:    sessions,
+	}
 }
 
 // Register godoc
 // @Summary Регистрация пользователя
-// @Description Создаёт нового пользователя по email и паролю
+// @Description Создаёт нового пользователя по email и паролю. Пароль проверяется по политике сложности (PASSWORD_*).
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -76,8 +87,11 @@ func (h *AuthHandler) Register() fiber.Handler {
 // @Accept json
 // @Produce json
 // @Param payload body dto.LoginRequest true "Email и пароль"
-// @Success 200 {object} dto.LoginResponse
+// @Description При включённой 2FA вместо токенов возвращает mfa_required и mfa_token для /auth/mfa/verify
+// @Success 200 {object} dto.AuthResponse
 // @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse "Email не подтверждён"
+// @Failure 429 {object} dto.ErrorResponse "Слишком много неудачных попыток, см. Retry-After"
 // @Router /auth/login [post]
 func (h *AuthHandler) Login() fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -100,7 +114,7 @@ func (h *AuthHandler) Login() fiber.Handler {
 			Str("email", req.Email).
 			Msg("login attempt")
 
-		resp, err := h.authService.Login(req)
+		resp, err := h.authService.Login(req, c.IP())
 		if err != nil {
 			logger.Log.Info().
 				Str("component", "auth").
@@ -109,7 +123,17 @@ func (h *AuthHandler) Login() fiber.Handler {
 				Err(err).
 				Msg("login failed")
 
-			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+			var locked *services.LockoutError
+			switch {
+			case errors.As(err, &locked):
+				return lockedError(c, locked)
+			case errors.Is(err, services.ErrInvalidCredentials):
+				return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+			case errors.Is(err, services.ErrEmailNotVerified):
+				return fiber.NewError(fiber.StatusForbidden, err.Error())
+			}
+
+			return fiber.ErrInternalServerError
 		}
 
 		logger.Log.Info().
@@ -121,3 +145,127 @@ func (h *AuthHandler) Login() fiber.Handler {
 		return c.JSON(resp)
 	}
 }
+
+// Refresh godoc
+// @Summary Обновление токенов
+// @Description Обменивает refresh-токен на новую пару токенов. Refresh-токен одноразовый: повторное использование отзывает всю цепочку сессии.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param payload body dto.RefreshRequest true "Refresh-токен"
+// @Success 200 {object} dto.AuthResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /auth/refresh [post]
+func (h *AuthHandler) Refresh() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		var req dto.RefreshRequest
+
+		if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+			logger.Log.Warn().
+				Str("component", "auth").
+				Str("handler", "Refresh").
+				Err(err).
+				Msg("failed to parse refresh request body")
+
+			return fiber.ErrBadRequest
+		}
+
+		resp, err := h.authService.Refresh(req.RefreshToken)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidRefreshToken) ||
+				errors.Is(err, services.ErrRefreshTokenReused) {
+
+				return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+			}
+
+			logger.Log.Error().
+				Str("component", "auth").
+				Str("handler", "Refresh").
+				Err(err).
+				Msg("refresh failed")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(resp)
+	}
+}
+
+// Logout godoc
+// @Summary Выход из текущей сессии
+// @Description Отзывает текущий access-токен (и цепочку refresh-токенов, если он передан)
+// @Tags Auth
+// @Accept json
+// @Security BearerAuth
+// @Param payload body dto.LogoutRequest false "Refresh-токен сессии"
+// @Success 204
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		claims, ok := c.Locals("jwt_claims").(gojwt.MapClaims)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		var req dto.LogoutRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return fiber.ErrBadRequest
+			}
+		}
+
+		if err := h.sessions.Logout(claims, req.RefreshToken); err != nil {
+			logger.Log.Error().
+				Str("component", "auth").
+				Str("handler", "Logout").
+				Err(err).
+				Msg("logout failed")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// LogoutAll godoc
+// @Summary Выход из всех сессий
+// @Description Инвалидирует все выданные пользователю access- и refresh-токены
+// @Tags Auth
+// @Security BearerAuth
+// @Success 204
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /auth/logout-all [post]
+func (h *AuthHandler) LogoutAll() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		userID, ok := c.Locals("user_id").(uint)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		if err := h.sessions.LogoutAll(userID); err != nil {
+			logger.Log.Error().
+				Str("component", "auth").
+				Str("handler", "LogoutAll").
+				Uint("user_id", userID).
+				Err(err).
+				Msg("logout all failed")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// lockedError answers 429 with Retry-After for a login lockout
+func lockedError(c *fiber.Ctx, locked *services.LockoutError) error {
+	retry := int(math.Ceil(time.Until(locked.Until).Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retry))
+	return fiber.NewError(fiber.StatusTooManyRequests, locked.Error())
+}
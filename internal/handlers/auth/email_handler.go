@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"errors"
+
+	"mws-ai/internal/dto"
+	"mws-ai/internal/services"
+	"mws-ai/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// VerifyEmail godoc
+// @Summary Подтверждение email
+// @Description Принимает токен из письма и отмечает email пользователя как подтверждённый
+// @Tags Auth
+// @Produce json
+// @Param token query string true "Токен из письма"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Router /auth/verify-email [get]
+func (h *AuthHandler) VerifyEmail() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		token := c.Query("token")
+		if token == "" {
+			return fiber.ErrBadRequest
+		}
+
+		if err := h.authService.VerifyEmail(token); err != nil {
+			if errors.Is(err, services.ErrInvalidEmailToken) {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+
+			logger.Log.Error().
+				Str("component", "auth").
+				Str("handler", "VerifyEmail").
+				Err(err).
+				Msg("email verification failed")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(fiber.Map{"status": "verified"})
+	}
+}
+
+// ResendVerification godoc
+// @Summary Повторная отправка письма подтверждения
+// @Description Всегда отвечает 202, чтобы по ответу нельзя было узнать, зарегистрирован ли email
+// @Tags Auth
+// @Accept json
+// @Param payload body dto.EmailRequest true "Email"
+// @Success 202
+// @Failure 400 {object} dto.ErrorResponse
+// @Router /auth/verify-email/resend [post]
+func (h *AuthHandler) ResendVerification() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		var req dto.EmailRequest
+		if err := c.BodyParser(&req); err != nil || req.Email == "" {
+			return fiber.ErrBadRequest
+		}
+
+		if err := h.authService.ResendVerification(req.Email); err != nil {
+			logger.Log.Error().
+				Str("component", "auth").
+				Str("handler", "ResendVerification").
+				Err(err).
+				Msg("resend verification failed")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.SendStatus(fiber.StatusAccepted)
+	}
+}
+
+// RequestPasswordReset godoc
+// @Summary Запрос сброса пароля
+// @Description Отправляет ссылку для сброса пароля. Всегда отвечает 202, чтобы по ответу нельзя было узнать, зарегистрирован ли email
+// @Tags Auth
+// @Accept json
+// @Param payload body dto.EmailRequest true "Email"
+// @Success 202
+// @Failure 400 {object} dto.ErrorResponse
+// @Router /auth/password-reset [post]
+func (h *AuthHandler) RequestPasswordReset() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		var req dto.EmailRequest
+		if err := c.BodyParser(&req); err != nil || req.Email == "" {
+			return fiber.ErrBadRequest
+		}
+
+		if err := h.authService.RequestPasswordReset(req.Email); err != nil {
+			logger.Log.Error().
+				Str("component", "auth").
+				Str("handler", "RequestPasswordReset").
+				Err(err).
+				Msg("password reset request failed")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.SendStatus(fiber.StatusAccepted)
+	}
+}
+
+// ResetPassword godoc
+// @Summary Установка нового пароля
+// @Description Меняет пароль по токену из письма. Все сессии пользователя завершаются, токен одноразовый.
+// @Tags Auth
+// @Accept json
+// @Param payload body dto.ResetPasswordRequest true "Токен и новый пароль"
+// @Success 204
+// @Failure 400 {object} dto.ErrorResponse
+// @Router /auth/password-reset/confirm [post]
+func (h *AuthHandler) ResetPassword() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		var req dto.ResetPasswordRequest
+		if err := c.BodyParser(&req); err != nil || req.Token == "" {
+			return fiber.ErrBadRequest
+		}
+
+		if err := h.authService.ResetPassword(req.Token, req.Password, c.IP()); err != nil {
+			if errors.Is(err, services.ErrInvalidEmailToken) ||
+				errors.Is(err, services.ErrWeakPassword) {
+
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+
+			logger.Log.Error().
+				Str("component", "auth").
+				Str("handler", "ResetPassword").
+				Err(err).
+				Msg("password reset failed")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
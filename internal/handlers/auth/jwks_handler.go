@@ -0,0 +1,16 @@
+package auth
+
+import (
+	"mws-ai/pkg/jwt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// JWKSHandler serves /.well-known/jwks.json: the public keys other services
+// can verify our tokens with. The set is empty with HS256.
+func JWKSHandler(manager *jwt.JWTManager) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+		return c.JSON(manager.Keys().JWKS())
+	}
+}
@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"errors"
+
+	"mws-ai/internal/dto"
+	"mws-ai/internal/services"
+	"mws-ai/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type MFAHandler struct {
+	mfa *services.MFAService
+}
+
+func NewMFAHandler(mfa *services.MFAService) *MFAHandler {
+	return &MFAHandler{mfa: mfa}
+}
+
+// Verify godoc
+// @Summary Второй шаг входа (2FA)
+// @Description Принимает mfa_token из ответа /auth/login и TOTP-код (или код восстановления), выдаёт пару токенов
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param payload body dto.MFAVerifyRequest true "Токен и код"
+// @Success 200 {object} dto.AuthResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 429 {object} dto.ErrorResponse "Слишком много неудачных попыток, см. Retry-After"
+// @Router /auth/mfa/verify [post]
+func (h *MFAHandler) Verify() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		var req dto.MFAVerifyRequest
+		if err := c.BodyParser(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+			return fiber.ErrBadRequest
+		}
+
+		resp, err := h.mfa.Verify(req.MFAToken, req.Code, c.IP())
+		if err != nil {
+			return mfaError(c, err, "Verify")
+		}
+
+		return c.JSON(resp)
+	}
+}
+
+// Status godoc
+// @Summary Состояние 2FA
+// @Tags MFA
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.MFAStatusResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /auth/mfa [get]
+func (h *MFAHandler) Status() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		userID, ok := c.Locals("user_id").(uint)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		resp, err := h.mfa.Status(userID)
+		if err != nil {
+			return mfaError(c, err, "Status")
+		}
+
+		return c.JSON(resp)
+	}
+}
+
+// Enroll godoc
+// @Summary Подключение TOTP
+// @Description Создаёт секрет и otpauth:// URI для QR-кода. 2FA включится после подтверждения кодом.
+// @Tags MFA
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.TOTPEnrollResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse "2FA уже включена"
+// @Router /auth/mfa/totp [post]
+func (h *MFAHandler) Enroll() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		userID, ok := c.Locals("user_id").(uint)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		resp, err := h.mfa.Enroll(userID)
+		if err != nil {
+			return mfaError(c, err, "Enroll")
+		}
+
+		return c.JSON(resp)
+	}
+}
+
+// ConfirmEnrollment godoc
+// @Summary Подтверждение TOTP
+// @Description Включает 2FA по коду из приложения и возвращает коды восстановления (показываются один раз)
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param payload body dto.MFACodeRequest true "TOTP-код"
+// @Success 200 {object} dto.RecoveryCodesResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 429 {object} dto.ErrorResponse "Слишком много неудачных попыток, см. Retry-After"
+// @Router /auth/mfa/totp/confirm [post]
+func (h *MFAHandler) ConfirmEnrollment() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		userID, ok := c.Locals("user_id").(uint)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		var req dto.MFACodeRequest
+		if err := c.BodyParser(&req); err != nil || req.Code == "" {
+			return fiber.ErrBadRequest
+		}
+
+		codes, err := h.mfa.ConfirmEnrollment(userID, req.Code, c.IP())
+		if err != nil {
+			return mfaError(c, err, "ConfirmEnrollment")
+		}
+
+		return c.JSON(recoveryCodesResponse(codes))
+	}
+}
+
+// Disable godoc
+// @Summary Отключение 2FA
+// @Description Требует текущий TOTP-код или код восстановления
+// @Tags MFA
+// @Accept json
+// @Security BearerAuth
+// @Param payload body dto.MFACodeRequest true "TOTP-код или код восстановления"
+// @Success 204
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 429 {object} dto.ErrorResponse "Слишком много неудачных попыток, см. Retry-After"
+// @Router /auth/mfa/totp/disable [post]
+func (h *MFAHandler) Disable() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		userID, ok := c.Locals("user_id").(uint)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		var req dto.MFACodeRequest
+		if err := c.BodyParser(&req); err != nil || req.Code == "" {
+			return fiber.ErrBadRequest
+		}
+
+		if err := h.mfa.Disable(userID, req.Code, c.IP()); err != nil {
+			return mfaError(c, err, "Disable")
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Новые коды восстановления
+// @Description Заменяет все коды восстановления. Требует текущий TOTP-код.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param payload body dto.MFACodeRequest true "TOTP-код"
+// @Success 200 {object} dto.RecoveryCodesResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 429 {object} dto.ErrorResponse "Слишком много неудачных попыток, см. Retry-After"
+// @Router /auth/mfa/recovery-codes [post]
+func (h *MFAHandler) RegenerateRecoveryCodes() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		userID, ok := c.Locals("user_id").(uint)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		var req dto.MFACodeRequest
+		if err := c.BodyParser(&req); err != nil || req.Code == "" {
+			return fiber.ErrBadRequest
+		}
+
+		codes, err := h.mfa.RegenerateRecoveryCodes(userID, req.Code, c.IP())
+		if err != nil {
+			return mfaError(c, err, "RegenerateRecoveryCodes")
+		}
+
+		return c.JSON(recoveryCodesResponse(codes))
+	}
+}
+
+func recoveryCodesResponse(codes []string) dto.RecoveryCodesResponse {
+	return dto.RecoveryCodesResponse{
+		RecoveryCodes: codes,
+		Note:          "Store these codes safely. Each works once and they will not be shown again.",
+	}
+}
+
+func mfaError(c *fiber.Ctx, err error, handler string) error {
+	var locked *services.LockoutError
+
+	switch {
+	case errors.As(err, &locked):
+		return lockedError(c, locked)
+	case errors.Is(err, services.ErrInvalidMFAToken):
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	case errors.Is(err, services.ErrInvalidMFACode),
+		errors.Is(err, services.ErrMFANotEnrolled):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrMFAAlreadyEnabled):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrUserNotFound):
+		return fiber.ErrUnauthorized
+	}
+
+	logger.Log.Error().
+		Str("component", "auth").
+		Str("handler", handler).
+		Err(err).
+		Msg("mfa operation failed")
+
+	return fiber.ErrInternalServerError
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"mws-ai/internal/services"
+	"mws-ai/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// oidcStateCookie binds the login state to the browser that started it,
+// so a callback URL cannot be replayed in someone else's session
+const oidcStateCookie = "mws_oidc_state"
+
+type OIDCHandler struct {
+	oidc         *services.OIDCService
+	secureCookie bool
+}
+
+func NewOIDCHandler(oidc *services.OIDCService, secureCookie bool) *OIDCHandler {
+	return &OIDCHandler{
+		oidc:         oidc,
+		secureCookie: secureCookie,
+	}
+}
+
+// Login godoc
+// @Summary Вход через SSO (OIDC)
+// @Description Перенаправляет на страницу входа провайдера (authorization code + PKCE)
+// @Tags Auth
+// @Success 302
+// @Failure 502 {object} dto.ErrorResponse
+// @Router /auth/oidc/login [get]
+func (h *OIDCHandler) Login() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		url, state, err := h.oidc.Begin()
+		if err != nil {
+			logger.Log.Error().
+				Str("component", "auth").
+				Str("handler", "OIDCLogin").
+				Err(err).
+				Msg("failed to start oidc login")
+
+			return fiber.NewError(fiber.StatusBadGateway, "identity provider unavailable")
+		}
+
+		h.setStateCookie(c, state, int(services.OIDCLoginTTL/time.Second))
+
+		return c.Redirect(url, fiber.StatusFound)
+	}
+}
+
+// Callback godoc
+// @Summary Завершение входа через SSO (OIDC)
+// @Description Принимает код авторизации от провайдера, создаёт пользователя при первом входе и выдаёт пару токенов
+// @Tags Auth
+// @Produce json
+// @Param code query string true "Код авторизации"
+// @Param state query string true "State из запроса авторизации"
+// @Success 200 {object} dto.AuthResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Router /auth/oidc/callback [get]
+func (h *OIDCHandler) Callback() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		log := logger.Log.With().
+			Str("component", "auth").
+			Str("handler", "OIDCCallback").
+			Logger()
+
+		// провайдер сообщает об отказе пользователя через параметр error
+		if e := c.Query("error"); e != "" {
+			log.Info().Str("error", e).Msg("provider returned an error")
+			return fiber.NewError(fiber.StatusUnauthorized, "login rejected by identity provider")
+		}
+
+		state := c.Query("state")
+		code := c.Query("code")
+		if state == "" || code == "" {
+			return fiber.ErrBadRequest
+		}
+
+		cookie := c.Cookies(oidcStateCookie)
+		h.setStateCookie(c, "", -1)
+
+		if subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+			log.Warn().Msg("state does not match the login cookie")
+			return fiber.NewError(fiber.StatusBadRequest, services.ErrOIDCInvalidState.Error())
+		}
+
+		resp, err := h.oidc.Callback(state, code)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrOIDCInvalidState):
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			case errors.Is(err, services.ErrOIDCExchange),
+				errors.Is(err, services.ErrOIDCMissingClaim):
+				return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+			case errors.Is(err, services.ErrOIDCEmailConflict):
+				return fiber.NewError(fiber.StatusConflict, err.Error())
+			}
+
+			log.Error().Err(err).Msg("oidc login failed")
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(resp)
+	}
+}
+
+// setStateCookie writes the state cookie; maxAge < 0 deletes it
+func (h *OIDCHandler) setStateCookie(c *fiber.Ctx, value string, maxAge int) {
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/api/auth/oidc",
+		MaxAge:   maxAge,
+		Secure:   h.secureCookie,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
@@ -0,0 +1,339 @@
+package org
+
+import (
+	"errors"
+	"strconv"
+
+	"mws-ai/internal/auth/rbac"
+	"mws-ai/internal/dto"
+	"mws-ai/internal/services"
+	"mws-ai/pkg/jwt"
+	"mws-ai/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+type OrgHandler struct {
+	orgs *services.OrganizationService
+}
+
+func NewOrgHandler(orgs *services.OrganizationService) *OrgHandler {
+	return &OrgHandler{orgs: orgs}
+}
+
+// List godoc
+// @Summary Организации текущего пользователя
+// @Tags Organizations
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} dto.OrganizationItem
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Router /orgs [get]
+func (h *OrgHandler) List() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		log := logger.Log.With().
+			Str("handler", "org.list").
+			Str("path", c.Path()).
+			Logger()
+
+		userID, ok := c.Locals("user_id").(uint)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		orgs, err := h.orgs.ListForUser(userID)
+		if err != nil {
+			log.Error().Err(err).Uint("user_id", userID).Msg("failed to list organizations")
+			return fiber.ErrInternalServerError
+		}
+
+		items := make([]dto.OrganizationItem, 0, len(orgs))
+		for _, o := range orgs {
+			items = append(items, dto.OrganizationItem{
+				ID:         o.ID,
+				Name:       o.Name,
+				Role:       o.Role,
+				RequireMFA: o.RequireMFA,
+			})
+		}
+
+		return c.JSON(items)
+	}
+}
+
+// Create godoc
+// @Summary Создать организацию
+// @Description Создаёт организацию, текущий пользователь становится её admin
+// @Tags Organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param payload body dto.CreateOrganizationRequest true "Название"
+// @Success 201 {object} dto.OrganizationItem
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Router /orgs [post]
+func (h *OrgHandler) Create() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		log := logger.Log.With().
+			Str("handler", "org.create").
+			Str("path", c.Path()).
+			Logger()
+
+		userID, ok := c.Locals("user_id").(uint)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		var req dto.CreateOrganizationRequest
+		if err := c.BodyParser(&req); err != nil {
+			log.Warn().Err(err).Msg("failed to parse create organization body")
+			return fiber.ErrBadRequest
+		}
+
+		org, err := h.orgs.Create(userID, req.Name)
+		if err != nil {
+			if errors.Is(err, services.ErrEmptyOrgName) {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+			log.Error().Err(err).Uint("user_id", userID).Msg("failed to create organization")
+			return fiber.ErrInternalServerError
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(dto.OrganizationItem{
+			ID:   org.ID,
+			Name: org.Name,
+			Role: string(rbac.RoleAdmin),
+		})
+	}
+}
+
+// UpdateSettings godoc
+// @Summary Настройки организации
+// @Description Включает или отключает обязательную 2FA. Включить можно только из сессии, прошедшей 2FA.
+// @Tags Organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Param payload body dto.UpdateOrganizationSettingsRequest true "Настройки"
+// @Success 200 {object} dto.OrganizationItem
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 403 {object} dto.ErrorResponse "Недостаточно прав или сессия без 2FA"
+// @Router /orgs/settings [patch]
+func (h *OrgHandler) UpdateSettings() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		var req dto.UpdateOrganizationSettingsRequest
+		if err := c.BodyParser(&req); err != nil || req.RequireMFA == nil {
+			return fiber.ErrBadRequest
+		}
+
+		claims, _ := c.Locals("jwt_claims").(gojwt.MapClaims)
+
+		org, err := h.orgs.SetRequireMFA(actor, *req.RequireMFA, jwt.MFA(claims))
+		if err != nil {
+			if errors.Is(err, services.ErrMFARequired) {
+				return fiber.NewError(fiber.StatusForbidden, "enable two-factor authentication for your own account first")
+			}
+
+			logger.Log.Error().
+				Str("handler", "org.settings.update").
+				Uint("org_id", actor.OrgID).
+				Err(err).
+				Msg("failed to update organization settings")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(dto.OrganizationItem{
+			ID:         org.ID,
+			Name:       org.Name,
+			Role:       string(actor.Role),
+			RequireMFA: org.RequireMFA,
+		})
+	}
+}
+
+// ListMembers godoc
+// @Summary Участники организации
+// @Tags Organizations
+// @Produce json
+// @Security BearerAuth
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Success 200 {array} dto.MemberItem
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 403 {object} dto.ErrorResponse "Недостаточно прав"
+// @Router /orgs/members [get]
+func (h *OrgHandler) ListMembers() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		members, err := h.orgs.ListMembers(actor)
+		if err != nil {
+			logger.Log.Error().
+				Str("handler", "org.members.list").
+				Uint("org_id", actor.OrgID).
+				Err(err).
+				Msg("failed to list members")
+
+			return fiber.ErrInternalServerError
+		}
+
+		items := make([]dto.MemberItem, 0, len(members))
+		for _, m := range members {
+			items = append(items, dto.MemberItem{
+				UserID: m.UserID,
+				Email:  m.Email,
+				Role:   m.Role,
+			})
+		}
+
+		return c.JSON(items)
+	}
+}
+
+// AddMember godoc
+// @Summary Добавить участника
+// @Description Добавляет зарегистрированного пользователя в текущую организацию (только admin)
+// @Tags Organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Param payload body dto.AddMemberRequest true "Email и роль"
+// @Success 201 {object} dto.MemberItem
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse "Недостаточно прав"
+// @Failure 404 {object} dto.ErrorResponse "Пользователь не найден"
+// @Failure 409 {object} dto.ErrorResponse "Уже участник"
+// @Router /orgs/members [post]
+func (h *OrgHandler) AddMember() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		var req dto.AddMemberRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.ErrBadRequest
+		}
+
+		m, err := h.orgs.AddMember(actor, req.Email, rbac.Role(req.Role))
+		if err != nil {
+			return memberError(err, actor, "org.members.add")
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(dto.MemberItem{
+			UserID: m.UserID,
+			Email:  req.Email,
+			Role:   m.Role,
+		})
+	}
+}
+
+// UpdateMemberRole godoc
+// @Summary Изменить роль участника
+// @Tags Organizations
+// @Accept json
+// @Security BearerAuth
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Param user_id path int true "ID пользователя"
+// @Param payload body dto.UpdateMemberRoleRequest true "Новая роль"
+// @Success 204
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse "Недостаточно прав"
+// @Failure 404 {object} dto.ErrorResponse "Не участник"
+// @Failure 409 {object} dto.ErrorResponse "Последний admin"
+// @Router /orgs/members/{user_id} [patch]
+func (h *OrgHandler) UpdateMemberRole() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		userID, err := strconv.ParseUint(c.Params("user_id"), 10, 64)
+		if err != nil {
+			return fiber.ErrBadRequest
+		}
+
+		var req dto.UpdateMemberRoleRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.ErrBadRequest
+		}
+
+		if err := h.orgs.UpdateMemberRole(actor, uint(userID), rbac.Role(req.Role)); err != nil {
+			return memberError(err, actor, "org.members.update")
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// RemoveMember godoc
+// @Summary Удалить участника
+// @Tags Organizations
+// @Security BearerAuth
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Param user_id path int true "ID пользователя"
+// @Success 204
+// @Failure 403 {object} dto.ErrorResponse "Недостаточно прав"
+// @Failure 404 {object} dto.ErrorResponse "Не участник"
+// @Failure 409 {object} dto.ErrorResponse "Последний admin"
+// @Router /orgs/members/{user_id} [delete]
+func (h *OrgHandler) RemoveMember() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		userID, err := strconv.ParseUint(c.Params("user_id"), 10, 64)
+		if err != nil {
+			return fiber.ErrBadRequest
+		}
+
+		if err := h.orgs.RemoveMember(actor, uint(userID)); err != nil {
+			return memberError(err, actor, "org.members.remove")
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func memberError(err error, actor rbac.Actor, handler string) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidRole):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrNotMember):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrMemberExists), errors.Is(err, services.ErrLastAdmin):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+
+	logger.Log.Error().
+		Str("handler", handler).
+		Uint("org_id", actor.OrgID).
+		Err(err).
+		Msg("membership operation failed")
+
+	return fiber.ErrInternalServerError
+}
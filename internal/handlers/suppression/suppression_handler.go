@@ -0,0 +1,238 @@
+package suppression
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"mws-ai/internal/auth/rbac"
+	"mws-ai/internal/dto"
+	"mws-ai/internal/models"
+	"mws-ai/internal/services"
+	"mws-ai/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SuppressionHandler struct {
+	suppressions *services.SuppressionService
+}
+
+func NewSuppressionHandler(suppressions *services.SuppressionService) *SuppressionHandler {
+	return &SuppressionHandler{suppressions: suppressions}
+}
+
+// List godoc
+// @Summary Правила подавления
+// @Description Возвращает все правила подавления организации, включая истёкшие
+// @Tags Suppressions
+// @Produce json
+// @Security BearerAuth
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Success 200 {array} dto.SuppressionItem
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 403 {object} dto.ErrorResponse "Недостаточно прав"
+// @Router /suppressions [get]
+func (h *SuppressionHandler) List() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		rules, err := h.suppressions.List(actor)
+		if err != nil {
+			logger.Log.Error().
+				Str("handler", "suppression.list").
+				Uint("org_id", actor.OrgID).
+				Err(err).
+				Msg("failed to list suppression rules")
+
+			return fiber.ErrInternalServerError
+		}
+
+		now := time.Now()
+		items := make([]dto.SuppressionItem, 0, len(rules))
+		for i := range rules {
+			items = append(items, toSuppressionItem(&rules[i], now))
+		}
+
+		return c.JSON(items)
+	}
+}
+
+// Create godoc
+// @Summary Создать правило подавления
+// @Description Находки, подходящие под правило, получают вердикт FP с decision_source=suppression ещё до эвристик.
+// @Description Должно совпасть каждое заполненное условие: path_glob (поддерживает ** и *), rule_id, value_regex (RE2), fingerprint (префикс sha256 значения из value_masked).
+// @Tags Suppressions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Param payload body dto.SuppressionRequest true "Условия, причина и срок действия"
+// @Success 201 {object} dto.SuppressionItem
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 403 {object} dto.ErrorResponse "Недостаточно прав"
+// @Router /suppressions [post]
+func (h *SuppressionHandler) Create() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		in, err := parseInput(c)
+		if err != nil {
+			return err
+		}
+
+		rule, err := h.suppressions.Create(actor, in, c.IP())
+		if err != nil {
+			return suppressionError(err, actor, "suppression.create")
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(toSuppressionItem(rule, time.Now()))
+	}
+}
+
+// Update godoc
+// @Summary Изменить правило подавления
+// @Description Полностью заменяет условия, причину и срок действия правила
+// @Tags Suppressions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Param id path int true "ID правила"
+// @Param payload body dto.SuppressionRequest true "Условия, причина и срок действия"
+// @Success 200 {object} dto.SuppressionItem
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse "Недостаточно прав"
+// @Failure 404 {object} dto.ErrorResponse "Правило не найдено"
+// @Router /suppressions/{id} [put]
+func (h *SuppressionHandler) Update() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+		if err != nil {
+			return fiber.ErrBadRequest
+		}
+
+		in, err := parseInput(c)
+		if err != nil {
+			return err
+		}
+
+		rule, err := h.suppressions.Update(actor, uint(id), in, c.IP())
+		if err != nil {
+			return suppressionError(err, actor, "suppression.update")
+		}
+
+		return c.JSON(toSuppressionItem(rule, time.Now()))
+	}
+}
+
+// Delete godoc
+// @Summary Удалить правило подавления
+// @Description Уже обработанные находки сохраняют свой вердикт
+// @Tags Suppressions
+// @Security BearerAuth
+// @Param X-Organization-ID header int false "ID организации (по умолчанию — первая)"
+// @Param id path int true "ID правила"
+// @Success 204
+// @Failure 403 {object} dto.ErrorResponse "Недостаточно прав"
+// @Failure 404 {object} dto.ErrorResponse "Правило не найдено"
+// @Router /suppressions/{id} [delete]
+func (h *SuppressionHandler) Delete() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+		if err != nil {
+			return fiber.ErrBadRequest
+		}
+
+		if err := h.suppressions.Delete(actor, uint(id), c.IP()); err != nil {
+			return suppressionError(err, actor, "suppression.delete")
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func parseInput(c *fiber.Ctx) (services.SuppressionInput, error) {
+	var req dto.SuppressionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return services.SuppressionInput{}, fiber.ErrBadRequest
+	}
+
+	in := services.SuppressionInput{
+		PathGlob:    req.PathGlob,
+		RuleID:      req.RuleID,
+		ValueRegex:  req.ValueRegex,
+		Fingerprint: req.Fingerprint,
+		Reason:      req.Reason,
+	}
+
+	if req.ExpiresAt != nil && *req.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+		if err != nil {
+			return in, fiber.NewError(fiber.StatusBadRequest, "expires_at must be RFC 3339")
+		}
+		in.ExpiresAt = &t
+	}
+
+	return in, nil
+}
+
+func suppressionError(err error, actor rbac.Actor, handler string) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidSuppression):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrSuppressionNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+
+	logger.Log.Error().
+		Str("handler", handler).
+		Uint("org_id", actor.OrgID).
+		Err(err).
+		Msg("suppression rule operation failed")
+
+	return fiber.ErrInternalServerError
+}
+
+func toSuppressionItem(r *models.SuppressionRule, now time.Time) dto.SuppressionItem {
+	item := dto.SuppressionItem{
+		ID:          r.ID,
+		PathGlob:    r.PathGlob,
+		RuleID:      r.RuleID,
+		ValueRegex:  r.ValueRegex,
+		Fingerprint: r.Fingerprint,
+		Reason:      r.Reason,
+		CreatedByID: r.CreatedByID,
+		CreatedAt:   r.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   r.UpdatedAt.Format(time.RFC3339),
+	}
+
+	if r.ExpiresAt != nil {
+		s := r.ExpiresAt.Format(time.RFC3339)
+		item.ExpiresAt = &s
+		item.Expired = !r.ExpiresAt.After(now)
+	}
+
+	return item
+}
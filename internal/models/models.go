@@ -9,21 +9,67 @@ type User struct {
 	Email        string `gorm:"uniqueIndex;not null" json:"email"`
 	PasswordHash string `gorm:"not null" json:"-"`
 
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+
+	// TOTP second factor: the secret is set on enrollment and the factor is
+	// active once TOTPEnabledAt is set. TOTPLastStep blocks code replay.
+	TOTPSecret    *string    `json:"-"`
+	TOTPEnabledAt *time.Time `json:"totp_enabled_at"`
+	TOTPLastStep  int64      `gorm:"not null;default:0" json:"-"`
+
+	// TokenVersion is embedded in issued JWTs; bumping it logs out every session
+	TokenVersion int `gorm:"not null;default:0" json:"-"`
+
+	// OIDC identity (issuer + sub) for users signed in via SSO.
+	// SSO-only users have an empty PasswordHash and cannot use password login.
+	OIDCIssuer  *string `gorm:"uniqueIndex:idx_user_oidc_identity" json:"-"`
+	OIDCSubject *string `gorm:"uniqueIndex:idx_user_oidc_identity" json:"-"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+type Organization struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Name string `gorm:"not null" json:"name"`
+
+	// RequireMFA denies password sessions without a second factor
+	RequireMFA bool `gorm:"not null;default:false" json:"require_mfa"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+type Membership struct {
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	OrganizationID uint   `gorm:"uniqueIndex:idx_membership_org_user;not null" json:"organization_id"`
+	UserID         uint   `gorm:"uniqueIndex:idx_membership_org_user;index;not null" json:"user_id"`
+	Role           string `gorm:"type:varchar(16);not null" json:"role"` // viewer / reviewer / admin
+
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 type Analysis struct {
-	ID     uint `gorm:"primaryKey" json:"id"`
-	UserID uint `gorm:"index" json:"user_id"`
+	ID             uint `gorm:"primaryKey" json:"id"`
+	OrganizationID uint `gorm:"index" json:"organization_id"`
+	UserID         uint `gorm:"index" json:"user_id"` // uploader
+
+	FileName   string `gorm:"index" json:"file_name"`
+	Repository string `gorm:"index" json:"repository"`
+	Commit     string `json:"commit"`
+	Branch     string `gorm:"index" json:"branch"`
+	Status     string `gorm:"index" json:"status"` // pending / processing / done / failed
 
-	FilePath string `json:"file_path"`
-	Status   string `json:"status"` // pending / processing / done / failed
+	// StorageKey locates the raw report in blob storage (replaces the
+	// never-populated file_path); cleared once retention purges it
+	StorageKey     string     `gorm:"index" json:"-"`
+	ReportPurgedAt *time.Time `json:"report_purged_at,omitempty"`
 
 	TPCount int `json:"tp_count"`
 	FPCount int `json:"fp_count"`
 
-	UploadedAt time.Time `gorm:"autoCreateTime" json:"uploaded_at"`
+	UploadedAt time.Time `gorm:"autoCreateTime;index" json:"uploaded_at"`
 	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 
 	Findings []Finding `gorm:"constraint:OnDelete:CASCADE;" json:"findings"`
@@ -37,8 +83,18 @@ type Finding struct {
 	Line     int    `gorm:"not null" json:"line"`
 	LineEnd  *int   `json:"line_end,omitempty"`
 
-	Value  string `gorm:"not null" json:"value"`
-	RuleID string `gorm:"not null" json:"rule_id"`
+	// Value is sealed with envelope encryption in the database and never
+	// serialized; only AnalysisService.RevealValue decrypts it.
+	// ValueMasked is the safe preview shown instead (mask.Mask).
+	Value       string `gorm:"not null" json:"-"`
+	ValueMasked string `json:"value_masked"`
+	RuleID      string `gorm:"not null" json:"rule_id"`
+
+	// Context is the source around the finding, from SARIF contextRegion
+	// or an uploaded source archive. The secret in it is masked before it
+	// is stored or handed to the pipeline (mask.RedactContext).
+	Context          string `gorm:"type:text" json:"context,omitempty"`
+	ContextStartLine int    `json:"context_start_line,omitempty"`
 
 	Severity          string  `json:"severity"`
 	ScannerConfidence float64 `json:"scanner_confidence"`
@@ -61,6 +117,12 @@ type Finding struct {
 	// Final
 	FinalVerdict   *string `json:"final_verdict"`
 	DecisionSource string  `gorm:"type:varchar(25)" json:"decision_source"`
+	// set when DecisionSource is "suppression"
+	SuppressionRuleID *uint `json:"suppression_rule_id,omitempty"`
+	// the ignore comment found in the source when DecisionSource is "inline_ignore"
+	SuppressionComment *string `json:"suppression_comment,omitempty"`
+	// format and reason when DecisionSource is "format_validator"
+	FormatValidation *string `json:"format_validation,omitempty"`
 
 	// Human review
 	HumanVerdict *string `json:"human_verdict"`
@@ -73,14 +135,126 @@ type Finding struct {
 }
 
 type ApiKey struct {
-	ID     uint   `gorm:"primaryKey"`
-	UserID uint   `gorm:"index"`
-	Hash   string `gorm:"uniqueIndex"`
+	ID             uint   `gorm:"primaryKey"`
+	UserID         uint   `gorm:"index"`
+	OrganizationID uint   `gorm:"index"`
+	Hash           string `gorm:"uniqueIndex"`
+	Prefix         string `gorm:"type:varchar(32)"` // first chars of the raw key, safe to show
+	Name           string
 
 	Type   string `gorm:"index"` // "service", "admin", "integration"
 	Active bool   `gorm:"index"`
 
+	// Scopes limits the key to a subset of its owner's permissions
+	// (e.g. "analyses:write"); empty means all the key type allows.
+	Scopes []string `gorm:"serializer:json"`
+	// Projects limits the key to named repositories; empty means any.
+	Projects []string `gorm:"serializer:json"`
+
 	CreatedAt  time.Time
 	LastUsedAt *time.Time
 	ExpiresAt  *time.Time
+	RevokedAt  *time.Time
+
+	// set on the old key when it was rotated
+	ReplacedByID *uint
+}
+
+// RefreshToken tracks issued refresh tokens. Tokens from one login share
+// a FamilyID; presenting an already used token revokes the whole family.
+type RefreshToken struct {
+	ID       uint   `gorm:"primaryKey"`
+	JTI      string `gorm:"uniqueIndex;not null"`
+	FamilyID string `gorm:"index;not null"`
+	UserID   uint   `gorm:"index;not null"`
+
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// RevokedToken is a deny-list entry for an access token (by jti), kept
+// until the token would have expired anyway
+type RevokedToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	JTI       string    `gorm:"uniqueIndex;not null"`
+	UserID    uint      `gorm:"index"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// OIDCLoginState holds the PKCE verifier and nonce of an SSO login
+// between the redirect to the provider and the callback
+type OIDCLoginState struct {
+	ID        uint      `gorm:"primaryKey"`
+	State     string    `gorm:"uniqueIndex;not null"`
+	Nonce     string    `gorm:"not null"`
+	Verifier  string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// LoginAttempt counts recent failed logins for a key ("account:<email>"
+// or "ip:<addr>") and holds the lockout that followed
+type LoginAttempt struct {
+	ID            uint   `gorm:"primaryKey"`
+	Key           string `gorm:"uniqueIndex;not null"`
+	Failures      int    `gorm:"not null;default:0"`
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// AuditLog is an append-only record of a security-relevant event
+type AuditLog struct {
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	Event          string `gorm:"type:varchar(64);index;not null" json:"event"`
+	UserID         *uint  `gorm:"index" json:"user_id,omitempty"`
+	OrganizationID *uint  `gorm:"index" json:"organization_id,omitempty"`
+	IP             string `gorm:"type:varchar(64)" json:"ip,omitempty"`
+	Subject        string `json:"subject,omitempty"` // what the event is about, e.g. an email or a key
+
+	Details map[string]interface{} `gorm:"serializer:json" json:"details,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// SuppressionRule marks an organization's findings as known-safe before
+// the pipeline runs. Every non-empty matcher has to match; expired rules
+// are ignored.
+type SuppressionRule struct {
+	ID             uint `gorm:"primaryKey" json:"id"`
+	OrganizationID uint `gorm:"index;not null" json:"organization_id"`
+	CreatedByID    uint `json:"created_by_id"`
+
+	PathGlob   string `json:"path_glob,omitempty"` // "**/testdata/**"
+	RuleID     string `json:"rule_id,omitempty"`
+	ValueRegex string `json:"value_regex,omitempty"`
+	// Fingerprint is a prefix (8+ hex chars) of the value's SHA-256, as
+	// shown in value_masked
+	Fingerprint string `gorm:"type:varchar(64)" json:"fingerprint,omitempty"`
+
+	Reason    string     `gorm:"not null" json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RecoveryCode is a single-use 2FA backup code; only its hash is stored
+type RecoveryCode struct {
+	ID       uint   `gorm:"primaryKey"`
+	UserID   uint   `gorm:"index;not null"`
+	CodeHash string `gorm:"uniqueIndex;not null"`
+	UsedAt   *time.Time
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// RateLimitBucket is the shared token-bucket state of one client and budget
+type RateLimitBucket struct {
+	Key        string    `gorm:"primaryKey"`
+	Tokens     float64   `gorm:"not null"`
+	RefilledAt time.Time `gorm:"index;not null"`
 }
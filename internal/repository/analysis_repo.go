@@ -2,6 +2,8 @@ package repository
 
 import (
 	"errors"
+	"strings"
+	"time"
 
 	"mws-ai/internal/models"
 	"mws-ai/pkg/logger"
@@ -9,13 +11,46 @@ import (
 	"gorm.io/gorm"
 )
 
+// AnalysisFilter describes which analyses List and Summarize should return.
+// Zero values mean "no restriction"; Limit <= 0 disables pagination.
+type AnalysisFilter struct {
+	OrganizationID uint
+	UserID         uint // uploader
+
+	Repositories []string // restrict to these repositories
+
+	Status string
+	From   *time.Time
+	To     *time.Time
+	Search string // substring of file name or repository, case-insensitive
+
+	Limit  int
+	Offset int
+}
+
+// AnalysisSummary aggregates counters over the whole filtered set
+// (ignores Limit/Offset).
+type AnalysisSummary struct {
+	Total   int64 `json:"total"`
+	TPCount int64 `json:"tp_count"`
+	FPCount int64 `json:"fp_count"`
+}
+
 type AnalysisRepository interface {
 	Create(analysis *models.Analysis) error
 	GetByID(id uint) (*models.Analysis, error)
-	ListByUser(userID uint) ([]models.Analysis, error)
+	List(filter AnalysisFilter) ([]models.Analysis, error)
+	Summarize(filter AnalysisFilter) (*AnalysisSummary, error)
 	UpdateStatus(id uint, status string) error
 	UpdateCounts(id uint, tp int, fp int) error
-	
+	SetStorageKey(id uint, key string) error
+	// ListExpiredReports returns analyses whose raw report was uploaded
+	// before the cutoff and is still in storage, by id after afterID
+	ListExpiredReports(before time.Time, afterID uint, limit int) ([]models.Analysis, error)
+	MarkReportPurged(id uint, at time.Time) error
+	// ListStoredReportsAfter pages through analyses whose raw report is
+	// in storage (id and storage key only)
+	ListStoredReportsAfter(afterID uint, limit int) ([]models.Analysis, error)
 }
 
 type analysisRepository struct {
@@ -60,21 +95,24 @@ func (r *analysisRepository) GetByID(id uint) (*models.Analysis, error) {
 	return &analysis, nil
 }
 
-func (r *analysisRepository) ListByUser(userID uint) ([]models.Analysis, error) {
+func (r *analysisRepository) List(filter AnalysisFilter) ([]models.Analysis, error) {
 	var analyses []models.Analysis
 
-	if err := r.db.
-		Where("user_id = ?", userID).
+	q := r.applyFilter(r.db.Model(&models.Analysis{}), filter).
 		Order("uploaded_at DESC").
-		Find(&analyses).
-		Error; err != nil {
+		Order("id DESC")
 
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit).Offset(filter.Offset)
+	}
+
+	if err := q.Find(&analyses).Error; err != nil {
 		logger.Log.Error().
 			Str("repo", "analysis").
-			Str("method", "ListByUser").
-			Uint("user_id", userID).
+			Str("method", "List").
+			Uint("org_id", filter.OrganizationID).
 			Err(err).
-			Msg("failed to list analyses by user")
+			Msg("failed to list analyses")
 
 		return nil, err
 	}
@@ -82,6 +120,62 @@ func (r *analysisRepository) ListByUser(userID uint) ([]models.Analysis, error)
 	return analyses, nil
 }
 
+func (r *analysisRepository) Summarize(filter AnalysisFilter) (*AnalysisSummary, error) {
+	var summary AnalysisSummary
+
+	if err := r.applyFilter(r.db.Model(&models.Analysis{}), filter).
+		Select(
+			"COUNT(*) AS total, " +
+				"COALESCE(SUM(tp_count), 0) AS tp_count, " +
+				"COALESCE(SUM(fp_count), 0) AS fp_count",
+		).
+		Scan(&summary).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "analysis").
+			Str("method", "Summarize").
+			Uint("org_id", filter.OrganizationID).
+			Err(err).
+			Msg("failed to summarize analyses")
+
+		return nil, err
+	}
+
+	return &summary, nil
+}
+
+func (r *analysisRepository) applyFilter(q *gorm.DB, filter AnalysisFilter) *gorm.DB {
+	if filter.OrganizationID != 0 {
+		q = q.Where("organization_id = ?", filter.OrganizationID)
+	}
+	if filter.UserID != 0 {
+		q = q.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Repositories != nil {
+		q = q.Where("repository IN ?", filter.Repositories)
+	}
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	if filter.From != nil {
+		q = q.Where("uploaded_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		q = q.Where("uploaded_at < ?", *filter.To)
+	}
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		q = q.Where("(file_name ILIKE ? OR repository ILIKE ?)", pattern, pattern)
+	}
+	return q
+}
+
+// escapeLike экранирует спецсимволы LIKE, чтобы поиск был по подстроке как есть
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 func (r *analysisRepository) UpdateStatus(id uint, status string) error {
 	res := r.db.
 		Model(&models.Analysis{}).
@@ -142,3 +236,97 @@ func (r *analysisRepository) UpdateCounts(
 
 	return nil
 }
+
+func (r *analysisRepository) SetStorageKey(id uint, key string) error {
+	err := r.db.
+		Model(&models.Analysis{}).
+		Where("id = ?", id).
+		Update("storage_key", key).
+		Error
+
+	if err != nil {
+		logger.Log.Error().
+			Str("repo", "analysis").
+			Str("method", "SetStorageKey").
+			Uint("analysis_id", id).
+			Err(err).
+			Msg("failed to set storage key")
+		return err
+	}
+
+	return nil
+}
+
+func (r *analysisRepository) ListExpiredReports(
+	before time.Time,
+	afterID uint,
+	limit int,
+) ([]models.Analysis, error) {
+
+	var analyses []models.Analysis
+
+	err := r.db.
+		Where("storage_key <> '' AND uploaded_at < ? AND id > ?", before, afterID).
+		Order("id").
+		Limit(limit).
+		Find(&analyses).
+		Error
+
+	if err != nil {
+		logger.Log.Error().
+			Str("repo", "analysis").
+			Str("method", "ListExpiredReports").
+			Err(err).
+			Msg("failed to list expired reports")
+		return nil, err
+	}
+
+	return analyses, nil
+}
+
+func (r *analysisRepository) ListStoredReportsAfter(afterID uint, limit int) ([]models.Analysis, error) {
+	var analyses []models.Analysis
+
+	err := r.db.
+		Select("id", "storage_key").
+		Where("storage_key <> '' AND id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&analyses).
+		Error
+
+	if err != nil {
+		logger.Log.Error().
+			Str("repo", "analysis").
+			Str("method", "ListStoredReportsAfter").
+			Uint("after_id", afterID).
+			Err(err).
+			Msg("failed to list stored reports")
+		return nil, err
+	}
+
+	return analyses, nil
+}
+
+func (r *analysisRepository) MarkReportPurged(id uint, at time.Time) error {
+	err := r.db.
+		Model(&models.Analysis{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"storage_key":      "",
+			"report_purged_at": at,
+		}).
+		Error
+
+	if err != nil {
+		logger.Log.Error().
+			Str("repo", "analysis").
+			Str("method", "MarkReportPurged").
+			Uint("analysis_id", id).
+			Err(err).
+			Msg("failed to mark report purged")
+		return err
+	}
+
+	return nil
+}
@@ -12,8 +12,12 @@ import (
 
 type APIKeyRepository interface {
 	Create(key *models.ApiKey) error
+	FindByID(id uint) (*models.ApiKey, error)
 	FindActiveByHash(hash string) (*models.ApiKey, error)
+	ListByOrganization(orgID uint) ([]models.ApiKey, error)
 	UpdateLastUsed(id uint, t *time.Time) error
+	Revoke(id uint, at time.Time) error
+	MarkRotated(id uint, replacedBy uint, expiresAt time.Time) error
 }
 
 type apiKeyRepository struct {
@@ -45,6 +49,50 @@ func (r *apiKeyRepository) Create(key *models.ApiKey) error {
 	return nil
 }
 
+func (r *apiKeyRepository) FindByID(id uint) (*models.ApiKey, error) {
+	var key models.ApiKey
+
+	err := r.db.First(&key, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		logger.Log.Error().
+			Str("repo", "api_key").
+			Str("method", "FindByID").
+			Uint("api_key_id", id).
+			Err(err).
+			Msg("failed to find API key by id")
+
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (r *apiKeyRepository) ListByOrganization(orgID uint) ([]models.ApiKey, error) {
+	var keys []models.ApiKey
+
+	if err := r.db.
+		Where("organization_id = ?", orgID).
+		Order("created_at DESC").
+		Find(&keys).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "api_key").
+			Str("method", "ListByOrganization").
+			Uint("org_id", orgID).
+			Err(err).
+			Msg("failed to list API keys")
+
+		return nil, err
+	}
+
+	return keys, nil
+}
+
 func (r *apiKeyRepository) FindActiveByHash(hash string) (*models.ApiKey, error) {
 	var key models.ApiKey
 
@@ -104,3 +152,49 @@ func (r *apiKeyRepository) UpdateLastUsed(id uint, t *time.Time) error {
 
 	return nil
 }
+
+func (r *apiKeyRepository) Revoke(id uint, at time.Time) error {
+	res := r.db.Model(&models.ApiKey{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"active":     false,
+			"revoked_at": at,
+		})
+
+	if res.Error != nil {
+		logger.Log.Error().
+			Str("repo", "api_key").
+			Str("method", "Revoke").
+			Uint("api_key_id", id).
+			Err(res.Error).
+			Msg("failed to revoke API key")
+
+		return res.Error
+	}
+
+	return nil
+}
+
+// MarkRotated links the old key to its replacement and shortens its
+// lifetime to the end of the grace period
+func (r *apiKeyRepository) MarkRotated(id uint, replacedBy uint, expiresAt time.Time) error {
+	res := r.db.Model(&models.ApiKey{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"replaced_by_id": replacedBy,
+			"expires_at":     expiresAt,
+		})
+
+	if res.Error != nil {
+		logger.Log.Error().
+			Str("repo", "api_key").
+			Str("method", "MarkRotated").
+			Uint("api_key_id", id).
+			Err(res.Error).
+			Msg("failed to mark API key as rotated")
+
+		return res.Error
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"mws-ai/internal/models"
+	"mws-ai/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+type AuditRepository interface {
+	Create(entry *models.AuditLog) error
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) Create(entry *models.AuditLog) error {
+	if err := r.db.Create(entry).Error; err != nil {
+		logger.Log.Error().
+			Str("repo", "audit").
+			Str("method", "Create").
+			Str("event", entry.Event).
+			Err(err).
+			Msg("failed to write audit log")
+
+		return err
+	}
+
+	return nil
+}
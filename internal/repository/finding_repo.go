@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"errors"
+
 	"mws-ai/internal/models"
 	"mws-ai/pkg/logger"
 
@@ -11,6 +13,10 @@ type FindingRepository interface {
 	BulkInsert(findings []models.Finding) error
 	UpdateFields(id uint, fields map[string]interface{}) error
 	ListByAnalysis(analysisID uint) ([]models.Finding, error)
+	GetByID(id uint) (*models.Finding, error)
+	// ListValuesAfter pages through finding values by id (id and value only)
+	ListValuesAfter(afterID uint, limit int) ([]models.Finding, error)
+	SetValue(id uint, value string) error
 }
 
 type findingRepository struct {
@@ -81,3 +87,69 @@ func (r *findingRepository) ListByAnalysis(analysisID uint) ([]models.Finding, e
 
 	return findings, nil
 }
+
+func (r *findingRepository) GetByID(id uint) (*models.Finding, error) {
+	var finding models.Finding
+
+	err := r.db.First(&finding, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		logger.Log.Error().
+			Str("repo", "finding").
+			Str("method", "GetByID").
+			Uint("finding_id", id).
+			Err(err).
+			Msg("failed to get finding")
+
+		return nil, err
+	}
+
+	return &finding, nil
+}
+
+func (r *findingRepository) ListValuesAfter(afterID uint, limit int) ([]models.Finding, error) {
+	var findings []models.Finding
+
+	if err := r.db.
+		Select("id", "value").
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&findings).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "finding").
+			Str("method", "ListValuesAfter").
+			Uint("after_id", afterID).
+			Err(err).
+			Msg("failed to list finding values")
+
+		return nil, err
+	}
+
+	return findings, nil
+}
+
+func (r *findingRepository) SetValue(id uint, value string) error {
+	if err := r.db.
+		Model(&models.Finding{}).
+		Where("id = ?", id).
+		Update("value", value).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "finding").
+			Str("method", "SetValue").
+			Uint("finding_id", id).
+			Err(err).
+			Msg("failed to update finding value")
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"time"
+
+	"mws-ai/internal/models"
+	"mws-ai/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+type LoginAttemptRepository interface {
+	FindByKeys(keys []string) ([]models.LoginAttempt, error)
+	// RecordFailure atomically counts a failure and returns the new state
+	RecordFailure(key string, now time.Time, window time.Duration) (*models.LoginAttempt, error)
+	// Lock extends the lockout of key to until; it never shortens it
+	Lock(key string, until time.Time) error
+	Delete(key string) error
+}
+
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	return &loginAttemptRepository{db: db}
+}
+
+func (r *loginAttemptRepository) FindByKeys(keys []string) ([]models.LoginAttempt, error) {
+	var attempts []models.LoginAttempt
+
+	if err := r.db.
+		Where("key IN ?", keys).
+		Find(&attempts).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "login_attempt").
+			Str("method", "FindByKeys").
+			Err(err).
+			Msg("failed to find login attempts")
+
+		return nil, err
+	}
+
+	return attempts, nil
+}
+
+// RecordFailure increments the counter in a single upsert, so parallel
+// wrong passwords can't overwrite each other's counts. Failures older
+// than window start over unless the key is still locked.
+func (r *loginAttemptRepository) RecordFailure(
+	key string,
+	now time.Time,
+	window time.Duration,
+) (*models.LoginAttempt, error) {
+
+	var attempt models.LoginAttempt
+
+	err := r.db.Raw(`
+		INSERT INTO login_attempts (key, failures, last_failure_at)
+		VALUES (@key, 1, @now)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN `+staleAttempt+` THEN 1 ELSE login_attempts.failures + 1 END,
+			locked_until = CASE WHEN `+staleAttempt+` THEN NULL ELSE login_attempts.locked_until END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING id, key, failures, last_failure_at, locked_until`,
+		map[string]interface{}{
+			"key":         key,
+			"now":         now,
+			"windowStart": now.Add(-window),
+		}).
+		Scan(&attempt).
+		Error
+
+	if err != nil {
+		logger.Log.Error().
+			Str("repo", "login_attempt").
+			Str("method", "RecordFailure").
+			Str("key", key).
+			Err(err).
+			Msg("failed to record login failure")
+
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+// staleAttempt: the last failure is outside the window and no lockout is active
+const staleAttempt = `(login_attempts.last_failure_at < @windowStart
+	AND (login_attempts.locked_until IS NULL OR login_attempts.locked_until < @now))`
+
+func (r *loginAttemptRepository) Lock(key string, until time.Time) error {
+	if err := r.db.
+		Model(&models.LoginAttempt{}).
+		Where("key = ?", key).
+		Update("locked_until", gorm.Expr("GREATEST(COALESCE(locked_until, ?), ?)", until, until)).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "login_attempt").
+			Str("method", "Lock").
+			Str("key", key).
+			Err(err).
+			Msg("failed to lock login attempt key")
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *loginAttemptRepository) Delete(key string) error {
+	if err := r.db.
+		Where("key = ?", key).
+		Delete(&models.LoginAttempt{}).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "login_attempt").
+			Str("method", "Delete").
+			Str("key", key).
+			Err(err).
+			Msg("failed to delete login attempt")
+
+		return err
+	}
+
+	return nil
+}
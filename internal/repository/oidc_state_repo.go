@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"time"
+
+	"mws-ai/internal/models"
+	"mws-ai/pkg/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OIDCStateRepository interface {
+	Create(state *models.OIDCLoginState) error
+	Consume(state string) (*models.OIDCLoginState, error)
+	DeleteExpired(now time.Time) error
+}
+
+type oidcStateRepository struct {
+	db *gorm.DB
+}
+
+func NewOIDCStateRepository(db *gorm.DB) OIDCStateRepository {
+	return &oidcStateRepository{db: db}
+}
+
+func (r *oidcStateRepository) Create(state *models.OIDCLoginState) error {
+	if err := r.db.Create(state).Error; err != nil {
+		logger.Log.Error().
+			Str("repo", "oidc_state").
+			Str("method", "Create").
+			Err(err).
+			Msg("failed to store oidc login state")
+
+		return err
+	}
+
+	return nil
+}
+
+// Consume deletes the state and returns it, so every state is usable once.
+// Returns nil, nil when the state is unknown or was already used.
+func (r *oidcStateRepository) Consume(state string) (*models.OIDCLoginState, error) {
+	var rows []models.OIDCLoginState
+
+	if err := r.db.
+		Clauses(clause.Returning{}).
+		Where("state = ?", state).
+		Delete(&rows).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "oidc_state").
+			Str("method", "Consume").
+			Err(err).
+			Msg("failed to consume oidc login state")
+
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	return &rows[0], nil
+}
+
+func (r *oidcStateRepository) DeleteExpired(now time.Time) error {
+	res := r.db.
+		Where("expires_at < ?", now).
+		Delete(&models.OIDCLoginState{})
+
+	if res.Error != nil {
+		logger.Log.Error().
+			Str("repo", "oidc_state").
+			Str("method", "DeleteExpired").
+			Err(res.Error).
+			Msg("failed to purge expired oidc login states")
+
+		return res.Error
+	}
+
+	return nil
+}
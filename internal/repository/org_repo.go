@@ -0,0 +1,361 @@
+package repository
+
+import (
+	"errors"
+
+	"mws-ai/internal/models"
+	"mws-ai/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// MemberWithEmail is a membership joined with the member's email
+type MemberWithEmail struct {
+	models.Membership
+	Email string
+}
+
+// OrgWithRole is an organization together with the caller's role in it
+type OrgWithRole struct {
+	models.Organization
+	Role string
+}
+
+type OrganizationRepository interface {
+	Create(org *models.Organization, owner *models.Membership) error
+	// CreateWithOwner creates a new user together with their organization
+	CreateWithOwner(user *models.User, org *models.Organization, owner *models.Membership) error
+	FindByID(id uint) (*models.Organization, error)
+	UpdateRequireMFA(id uint, require bool) error
+	ListByUser(userID uint) ([]OrgWithRole, error)
+
+	FindMembership(orgID, userID uint) (*models.Membership, error)
+	FindDefaultMembership(userID uint) (*models.Membership, error)
+	ListMembers(orgID uint) ([]MemberWithEmail, error)
+	AddMember(m *models.Membership) error
+	UpdateMemberRole(orgID, userID uint, role string) error
+	RemoveMember(orgID, userID uint) error
+	CountAdmins(orgID uint) (int64, error)
+}
+
+type organizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) OrganizationRepository {
+	return &organizationRepository{db: db}
+}
+
+// Create stores the organization and its first member in one transaction
+func (r *organizationRepository) Create(
+	org *models.Organization,
+	owner *models.Membership,
+) error {
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+
+		owner.OrganizationID = org.ID
+		return tx.Create(owner).Error
+	})
+
+	if err != nil {
+		logger.Log.Error().
+			Str("repo", "organization").
+			Str("method", "Create").
+			Str("name", org.Name).
+			Err(err).
+			Msg("failed to create organization")
+
+		return err
+	}
+
+	logger.Log.Debug().
+		Str("repo", "organization").
+		Str("method", "Create").
+		Uint("org_id", org.ID).
+		Uint("owner_id", owner.UserID).
+		Msg("organization created")
+
+	return nil
+}
+
+// CreateWithOwner stores the user, the organization and the admin
+// membership in one transaction: a user without an organization can't
+// use any org-scoped route and can't register again either
+func (r *organizationRepository) CreateWithOwner(
+	user *models.User,
+	org *models.Organization,
+	owner *models.Membership,
+) error {
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+
+		owner.UserID = user.ID
+		owner.OrganizationID = org.ID
+		return tx.Create(owner).Error
+	})
+
+	if err != nil {
+		logger.Log.Error().
+			Str("repo", "organization").
+			Str("method", "CreateWithOwner").
+			Str("email", user.Email).
+			Err(err).
+			Msg("failed to create user with organization")
+
+		return err
+	}
+
+	logger.Log.Debug().
+		Str("repo", "organization").
+		Str("method", "CreateWithOwner").
+		Uint("org_id", org.ID).
+		Uint("owner_id", user.ID).
+		Msg("user and organization created")
+
+	return nil
+}
+
+func (r *organizationRepository) FindByID(id uint) (*models.Organization, error) {
+	var org models.Organization
+
+	err := r.db.First(&org, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		logger.Log.Error().
+			Str("repo", "organization").
+			Str("method", "FindByID").
+			Uint("org_id", id).
+			Err(err).
+			Msg("failed to find organization by id")
+
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+func (r *organizationRepository) UpdateRequireMFA(id uint, require bool) error {
+	if err := r.db.
+		Model(&models.Organization{}).
+		Where("id = ?", id).
+		Update("require_mfa", require).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "organization").
+			Str("method", "UpdateRequireMFA").
+			Uint("org_id", id).
+			Err(err).
+			Msg("failed to update mfa requirement")
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *organizationRepository) ListByUser(userID uint) ([]OrgWithRole, error) {
+	var orgs []OrgWithRole
+
+	if err := r.db.
+		Table("organization").
+		Select("organization.*, membership.role AS role").
+		Joins("JOIN membership ON membership.organization_id = organization.id").
+		Where("membership.user_id = ?", userID).
+		Order("organization.id").
+		Scan(&orgs).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "organization").
+			Str("method", "ListByUser").
+			Uint("user_id", userID).
+			Err(err).
+			Msg("failed to list organizations by user")
+
+		return nil, err
+	}
+
+	return orgs, nil
+}
+
+func (r *organizationRepository) FindMembership(orgID, userID uint) (*models.Membership, error) {
+	var m models.Membership
+
+	err := r.db.
+		Where("organization_id = ? AND user_id = ?", orgID, userID).
+		First(&m).
+		Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		logger.Log.Error().
+			Str("repo", "organization").
+			Str("method", "FindMembership").
+			Uint("org_id", orgID).
+			Uint("user_id", userID).
+			Err(err).
+			Msg("failed to find membership")
+
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// FindDefaultMembership returns the user's oldest membership
+func (r *organizationRepository) FindDefaultMembership(userID uint) (*models.Membership, error) {
+	var m models.Membership
+
+	err := r.db.
+		Where("user_id = ?", userID).
+		Order("id").
+		First(&m).
+		Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		logger.Log.Error().
+			Str("repo", "organization").
+			Str("method", "FindDefaultMembership").
+			Uint("user_id", userID).
+			Err(err).
+			Msg("failed to find default membership")
+
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func (r *organizationRepository) ListMembers(orgID uint) ([]MemberWithEmail, error) {
+	var members []MemberWithEmail
+
+	if err := r.db.
+		Table("membership").
+		Select(`membership.*, "user".email AS email`).
+		Joins(`JOIN "user" ON "user".id = membership.user_id`).
+		Where("membership.organization_id = ?", orgID).
+		Order("membership.id").
+		Scan(&members).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "organization").
+			Str("method", "ListMembers").
+			Uint("org_id", orgID).
+			Err(err).
+			Msg("failed to list members")
+
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (r *organizationRepository) AddMember(m *models.Membership) error {
+	if err := r.db.Create(m).Error; err != nil {
+		logger.Log.Error().
+			Str("repo", "organization").
+			Str("method", "AddMember").
+			Uint("org_id", m.OrganizationID).
+			Uint("user_id", m.UserID).
+			Err(err).
+			Msg("failed to add member")
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *organizationRepository) UpdateMemberRole(orgID, userID uint, role string) error {
+	res := r.db.
+		Model(&models.Membership{}).
+		Where("organization_id = ? AND user_id = ?", orgID, userID).
+		Update("role", role)
+
+	if res.Error != nil {
+		logger.Log.Error().
+			Str("repo", "organization").
+			Str("method", "UpdateMemberRole").
+			Uint("org_id", orgID).
+			Uint("user_id", userID).
+			Err(res.Error).
+			Msg("failed to update member role")
+
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		logger.Log.Debug().
+			Str("repo", "organization").
+			Str("method", "UpdateMemberRole").
+			Uint("org_id", orgID).
+			Uint("user_id", userID).
+			Msg("no membership found to update role")
+	}
+
+	return nil
+}
+
+func (r *organizationRepository) RemoveMember(orgID, userID uint) error {
+	res := r.db.
+		Where("organization_id = ? AND user_id = ?", orgID, userID).
+		Delete(&models.Membership{})
+
+	if res.Error != nil {
+		logger.Log.Error().
+			Str("repo", "organization").
+			Str("method", "RemoveMember").
+			Uint("org_id", orgID).
+			Uint("user_id", userID).
+			Err(res.Error).
+			Msg("failed to remove member")
+
+		return res.Error
+	}
+
+	return nil
+}
+
+func (r *organizationRepository) CountAdmins(orgID uint) (int64, error) {
+	var count int64
+
+	if err := r.db.
+		Model(&models.Membership{}).
+		Where("organization_id = ? AND role = ?", orgID, "admin").
+		Count(&count).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "organization").
+			Str("method", "CountAdmins").
+			Uint("org_id", orgID).
+			Err(err).
+			Msg("failed to count admins")
+
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"mws-ai/internal/models"
+	"mws-ai/pkg/logger"
+	"mws-ai/pkg/ratelimit"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	rateLimitPurgeInterval = 10 * time.Minute
+	// buckets idle this long are full for any sane limit
+	rateLimitIdleTTL = 24 * time.Hour
+)
+
+type rateLimitStore struct {
+	db *gorm.DB
+
+	mu        sync.Mutex
+	lastPurge time.Time
+}
+
+// NewRateLimitStore keeps buckets in Postgres so every instance shares
+// the same budget. Each take locks the bucket row for its transaction.
+func NewRateLimitStore(db *gorm.DB) ratelimit.Store {
+	return &rateLimitStore{db: db}
+}
+
+func (r *rateLimitStore) Take(key string, l ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	var res ratelimit.Result
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		full := ratelimit.NewBucket(l, now)
+
+		if err := tx.
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.RateLimitBucket{
+				Key:        key,
+				Tokens:     full.Tokens,
+				RefilledAt: full.RefilledAt,
+			}).Error; err != nil {
+			return err
+		}
+
+		var row models.RateLimitBucket
+		if err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key = ?", key).
+			First(&row).
+			Error; err != nil {
+			return err
+		}
+
+		b := ratelimit.Bucket{Tokens: row.Tokens, RefilledAt: row.RefilledAt}
+		res = b.Take(l, now)
+
+		return tx.
+			Model(&models.RateLimitBucket{}).
+			Where("key = ?", key).
+			Updates(map[string]interface{}{
+				"tokens":      b.Tokens,
+				"refilled_at": b.RefilledAt,
+			}).Error
+	})
+
+	if err != nil {
+		logger.Log.Error().
+			Str("repo", "rate_limit").
+			Str("method", "Take").
+			Str("key", key).
+			Err(err).
+			Msg("failed to take rate limit token")
+
+		return ratelimit.Result{}, err
+	}
+
+	r.purge(now)
+
+	return res, nil
+}
+
+// purge drops long idle buckets now and then
+func (r *rateLimitStore) purge(now time.Time) {
+	r.mu.Lock()
+	if now.Sub(r.lastPurge) < rateLimitPurgeInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.lastPurge = now
+	r.mu.Unlock()
+
+	if err := r.db.
+		Where("refilled_at < ?", now.Add(-rateLimitIdleTTL)).
+		Delete(&models.RateLimitBucket{}).
+		Error; err != nil {
+
+		logger.Log.Warn().
+			Str("repo", "rate_limit").
+			Str("method", "purge").
+			Err(err).
+			Msg("failed to purge idle rate limit buckets")
+	}
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"time"
+
+	"mws-ai/internal/models"
+	"mws-ai/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+type RecoveryCodeRepository interface {
+	ReplaceForUser(userID uint, hashes []string) error
+	Consume(userID uint, hash string, at time.Time) (bool, error)
+	CountUnused(userID uint) (int64, error)
+	DeleteForUser(userID uint) error
+}
+
+type recoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepository{db: db}
+}
+
+// ReplaceForUser drops the user's old codes and stores the new set
+func (r *recoveryCodeRepository) ReplaceForUser(userID uint, hashes []string) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]models.RecoveryCode, 0, len(hashes))
+		for _, h := range hashes {
+			codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: h})
+		}
+
+		return tx.Create(&codes).Error
+	})
+
+	if err != nil {
+		logger.Log.Error().
+			Str("repo", "recovery_code").
+			Str("method", "ReplaceForUser").
+			Uint("user_id", userID).
+			Err(err).
+			Msg("failed to replace recovery codes")
+
+		return err
+	}
+
+	return nil
+}
+
+// Consume marks an unused code as used; false if there was none
+func (r *recoveryCodeRepository) Consume(userID uint, hash string, at time.Time) (bool, error) {
+	res := r.db.
+		Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", at)
+
+	if res.Error != nil {
+		logger.Log.Error().
+			Str("repo", "recovery_code").
+			Str("method", "Consume").
+			Uint("user_id", userID).
+			Err(res.Error).
+			Msg("failed to consume recovery code")
+
+		return false, res.Error
+	}
+
+	return res.RowsAffected == 1, nil
+}
+
+func (r *recoveryCodeRepository) CountUnused(userID uint) (int64, error) {
+	var count int64
+
+	if err := r.db.
+		Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "recovery_code").
+			Str("method", "CountUnused").
+			Uint("user_id", userID).
+			Err(err).
+			Msg("failed to count recovery codes")
+
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *recoveryCodeRepository) DeleteForUser(userID uint) error {
+	if err := r.db.
+		Where("user_id = ?", userID).
+		Delete(&models.RecoveryCode{}).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "recovery_code").
+			Str("method", "DeleteForUser").
+			Uint("user_id", userID).
+			Err(err).
+			Msg("failed to delete recovery codes")
+
+		return err
+	}
+
+	return nil
+}
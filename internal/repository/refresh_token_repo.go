@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"mws-ai/internal/models"
+	"mws-ai/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository interface {
+	Create(token *models.RefreshToken) error
+	FindByJTI(jti string) (*models.RefreshToken, error)
+	// MarkUsed flags the token as used; false if it was already used
+	MarkUsed(id uint, at time.Time) (bool, error)
+	RevokeFamily(familyID string, at time.Time) error
+	RevokeAllForUser(userID uint, at time.Time) error
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) Create(token *models.RefreshToken) error {
+	if err := r.db.Create(token).Error; err != nil {
+		logger.Log.Error().
+			Str("repo", "refresh_token").
+			Str("method", "Create").
+			Uint("user_id", token.UserID).
+			Err(err).
+			Msg("failed to create refresh token")
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *refreshTokenRepository) FindByJTI(jti string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+
+	err := r.db.
+		Where("jti = ?", jti).
+		First(&token).
+		Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		logger.Log.Error().
+			Str("repo", "refresh_token").
+			Str("method", "FindByJTI").
+			Err(err).
+			Msg("failed to find refresh token")
+
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *refreshTokenRepository) MarkUsed(id uint, at time.Time) (bool, error) {
+	// условие used_at IS NULL защищает от гонки двух параллельных refresh
+	res := r.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", at)
+
+	if res.Error != nil {
+		logger.Log.Error().
+			Str("repo", "refresh_token").
+			Str("method", "MarkUsed").
+			Uint("refresh_token_id", id).
+			Err(res.Error).
+			Msg("failed to mark refresh token as used")
+
+		return false, res.Error
+	}
+
+	return res.RowsAffected == 1, nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(familyID string, at time.Time) error {
+	res := r.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", at)
+
+	if res.Error != nil {
+		logger.Log.Error().
+			Str("repo", "refresh_token").
+			Str("method", "RevokeFamily").
+			Str("family_id", familyID).
+			Err(res.Error).
+			Msg("failed to revoke refresh token family")
+
+		return res.Error
+	}
+
+	logger.Log.Debug().
+		Str("repo", "refresh_token").
+		Str("method", "RevokeFamily").
+		Str("family_id", familyID).
+		Int64("revoked", res.RowsAffected).
+		Msg("refresh token family revoked")
+
+	return nil
+}
+
+func (r *refreshTokenRepository) RevokeAllForUser(userID uint, at time.Time) error {
+	res := r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", at)
+
+	if res.Error != nil {
+		logger.Log.Error().
+			Str("repo", "refresh_token").
+			Str("method", "RevokeAllForUser").
+			Uint("user_id", userID).
+			Err(res.Error).
+			Msg("failed to revoke user refresh tokens")
+
+		return res.Error
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"time"
+
+	"mws-ai/internal/models"
+	"mws-ai/pkg/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RevokedTokenRepository interface {
+	Create(token *models.RevokedToken) error
+	IsRevoked(jti string) (bool, error)
+	DeleteExpired(now time.Time) error
+}
+
+type revokedTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRevokedTokenRepository(db *gorm.DB) RevokedTokenRepository {
+	return &revokedTokenRepository{db: db}
+}
+
+func (r *revokedTokenRepository) Create(token *models.RevokedToken) error {
+	// повторный logout тем же токеном — не ошибка
+	if err := r.db.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(token).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "revoked_token").
+			Str("method", "Create").
+			Uint("user_id", token.UserID).
+			Err(err).
+			Msg("failed to store revoked token")
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *revokedTokenRepository) IsRevoked(jti string) (bool, error) {
+	var count int64
+
+	if err := r.db.
+		Model(&models.RevokedToken{}).
+		Where("jti = ?", jti).
+		Count(&count).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "revoked_token").
+			Str("method", "IsRevoked").
+			Err(err).
+			Msg("failed to check revoked token")
+
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (r *revokedTokenRepository) DeleteExpired(now time.Time) error {
+	res := r.db.
+		Where("expires_at < ?", now).
+		Delete(&models.RevokedToken{})
+
+	if res.Error != nil {
+		logger.Log.Error().
+			Str("repo", "revoked_token").
+			Str("method", "DeleteExpired").
+			Err(res.Error).
+			Msg("failed to purge expired revoked tokens")
+
+		return res.Error
+	}
+
+	return nil
+}
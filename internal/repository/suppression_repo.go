@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"mws-ai/internal/models"
+	"mws-ai/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+type SuppressionRepository interface {
+	Create(rule *models.SuppressionRule) error
+	FindByID(id uint) (*models.SuppressionRule, error)
+	ListByOrganization(orgID uint) ([]models.SuppressionRule, error)
+	// ListActive returns the rules that have not expired at now
+	ListActive(orgID uint, now time.Time) ([]models.SuppressionRule, error)
+	Update(rule *models.SuppressionRule) error
+	Delete(id uint) error
+}
+
+type suppressionRepository struct {
+	db *gorm.DB
+}
+
+func NewSuppressionRepository(db *gorm.DB) SuppressionRepository {
+	return &suppressionRepository{db: db}
+}
+
+func (r *suppressionRepository) Create(rule *models.SuppressionRule) error {
+	if err := r.db.Create(rule).Error; err != nil {
+		logger.Log.Error().
+			Str("repo", "suppression").
+			Str("method", "Create").
+			Uint("org_id", rule.OrganizationID).
+			Err(err).
+			Msg("failed to create suppression rule")
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *suppressionRepository) FindByID(id uint) (*models.SuppressionRule, error) {
+	var rule models.SuppressionRule
+
+	err := r.db.First(&rule, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		logger.Log.Error().
+			Str("repo", "suppression").
+			Str("method", "FindByID").
+			Uint("rule_id", id).
+			Err(err).
+			Msg("failed to find suppression rule")
+
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+func (r *suppressionRepository) ListByOrganization(orgID uint) ([]models.SuppressionRule, error) {
+	var rules []models.SuppressionRule
+
+	if err := r.db.
+		Where("organization_id = ?", orgID).
+		Order("created_at DESC").
+		Find(&rules).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "suppression").
+			Str("method", "ListByOrganization").
+			Uint("org_id", orgID).
+			Err(err).
+			Msg("failed to list suppression rules")
+
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (r *suppressionRepository) ListActive(orgID uint, now time.Time) ([]models.SuppressionRule, error) {
+	var rules []models.SuppressionRule
+
+	if err := r.db.
+		Where("organization_id = ?", orgID).
+		Where(
+			r.db.
+				Where("expires_at IS NULL").
+				Or("expires_at > ?", now),
+		).
+		Order("id").
+		Find(&rules).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "suppression").
+			Str("method", "ListActive").
+			Uint("org_id", orgID).
+			Err(err).
+			Msg("failed to list active suppression rules")
+
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (r *suppressionRepository) Update(rule *models.SuppressionRule) error {
+	if err := r.db.Save(rule).Error; err != nil {
+		logger.Log.Error().
+			Str("repo", "suppression").
+			Str("method", "Update").
+			Uint("rule_id", rule.ID).
+			Err(err).
+			Msg("failed to update suppression rule")
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *suppressionRepository) Delete(id uint) error {
+	if err := r.db.Delete(&models.SuppressionRule{}, id).Error; err != nil {
+		logger.Log.Error().
+			Str("repo", "suppression").
+			Str("method", "Delete").
+			Uint("rule_id", id).
+			Err(err).
+			Msg("failed to delete suppression rule")
+
+		return err
+	}
+
+	return nil
+}
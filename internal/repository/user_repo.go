@@ -13,7 +13,14 @@ type UserRepository interface {
 	Create(user *models.User) error
 	FindByEmail(email string) (*models.User, error)
 	FindByID(id uint) (*models.User, error)
+	FindByOIDCIdentity(issuer, subject string) (*models.User, error)
 	Update(user *models.User) error
+	IncrementTokenVersion(id uint) error
+	AdvanceTOTPStep(id uint, step int64) (bool, error)
+	// ListTOTPSecretsAfter pages through users with a TOTP secret (id and
+	// secret only)
+	ListTOTPSecretsAfter(afterID uint, limit int) ([]models.User, error)
+	SetTOTPSecret(id uint, secret string) error
 }
 
 type userRepository struct {
@@ -94,6 +101,32 @@ func (r *userRepository) FindByID(id uint) (*models.User, error) {
 	return &user, nil
 }
 
+func (r *userRepository) FindByOIDCIdentity(issuer, subject string) (*models.User, error) {
+	var user models.User
+
+	err := r.db.
+		Where("oidc_issuer = ? AND oidc_subject = ?", issuer, subject).
+		First(&user).
+		Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		logger.Log.Error().
+			Str("repo", "user").
+			Str("method", "FindByOIDCIdentity").
+			Str("issuer", issuer).
+			Err(err).
+			Msg("failed to find user by oidc identity")
+
+		return nil, err
+	}
+
+	return &user, nil
+}
+
 func (r *userRepository) Update(user *models.User) error {
 	res := r.db.Save(user)
 
@@ -118,3 +151,87 @@ func (r *userRepository) Update(user *models.User) error {
 
 	return nil
 }
+
+func (r *userRepository) IncrementTokenVersion(id uint) error {
+	res := r.db.Model(&models.User{}).
+		Where("id = ?", id).
+		Update("token_version", gorm.Expr("token_version + 1"))
+
+	if res.Error != nil {
+		logger.Log.Error().
+			Str("repo", "user").
+			Str("method", "IncrementTokenVersion").
+			Uint("user_id", id).
+			Err(res.Error).
+			Msg("failed to bump token version")
+
+		return res.Error
+	}
+
+	return nil
+}
+
+// AdvanceTOTPStep records the last accepted TOTP time step. It only moves
+// forward, so a code (or an older one) can't be used twice.
+func (r *userRepository) AdvanceTOTPStep(id uint, step int64) (bool, error) {
+	res := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+
+	if res.Error != nil {
+		logger.Log.Error().
+			Str("repo", "user").
+			Str("method", "AdvanceTOTPStep").
+			Uint("user_id", id).
+			Err(res.Error).
+			Msg("failed to update totp step")
+
+		return false, res.Error
+	}
+
+	return res.RowsAffected == 1, nil
+}
+
+func (r *userRepository) ListTOTPSecretsAfter(afterID uint, limit int) ([]models.User, error) {
+	var users []models.User
+
+	if err := r.db.
+		Select("id", "totp_secret").
+		Where("totp_secret IS NOT NULL AND id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&users).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "user").
+			Str("method", "ListTOTPSecretsAfter").
+			Uint("after_id", afterID).
+			Err(err).
+			Msg("failed to list totp secrets")
+
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *userRepository) SetTOTPSecret(id uint, secret string) error {
+	if err := r.db.
+		Model(&models.User{}).
+		Where("id = ?", id).
+		Update("totp_secret", secret).
+		Error; err != nil {
+
+		logger.Log.Error().
+			Str("repo", "user").
+			Str("method", "SetTOTPSecret").
+			Uint("user_id", id).
+			Err(err).
+			Msg("failed to update totp secret")
+
+		return err
+	}
+
+	return nil
+}
@@ -2,7 +2,6 @@ package middleware
 
 import (
 	"mws-ai/internal/services"
-	"mws-ai/pkg/jwt"
 	"mws-ai/pkg/logger"
 	"strings"
 
@@ -10,7 +9,7 @@ import (
 )
 
 func AuthMiddleware(
-	jwtManager *jwt.JWTManager,
+	sessions *services.SessionService,
 	apiKeys *services.APIKeyService,
 ) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -31,17 +30,19 @@ func AuthMiddleware(
 
 		//API KEY
 		if apiKey != "" {
-			userID, err := apiKeys.Validate(apiKey)
+			key, err := apiKeys.Validate(apiKey)
 			if err != nil {
 				log.Info().Err(err).Msg("API key validation failed")
 				return fiber.ErrUnauthorized
 			}
 
 			log.Debug().
-				Uint("user_id", userID).
+				Uint("user_id", key.UserID).
+				Uint("api_key_id", key.ID).
 				Msg("authorized via API key")
 
-			c.Locals("user_id", userID)
+			c.Locals("user_id", key.UserID)
+			c.Locals("api_key", key)
 			c.Locals("auth_type", "api_key")
 			return c.Next()
 		}
@@ -50,20 +51,18 @@ func AuthMiddleware(
 		if strings.HasPrefix(authHeader, "Bearer ") {
 			tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
 
-			claims, err := jwtManager.Parse(tokenStr)
+			claims, userID, err := sessions.Authenticate(tokenStr)
 			if err != nil {
 				log.Info().Err(err).Msg("JWT validation failed")
 				return fiber.ErrUnauthorized
 			}
 
-			userIDRaw := claims["user_id"]
-			userID := uint(userIDRaw.(float64))
-
 			log.Debug().
 				Uint("user_id", userID).
 				Msg("authorized via JWT")
 
 			c.Locals("user_id", userID)
+			c.Locals("jwt_claims", claims)
 			c.Locals("auth_type", "jwt")
 			return c.Next()
 		}
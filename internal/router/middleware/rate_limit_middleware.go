@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"mws-ai/internal/models"
+	"mws-ai/pkg/logger"
+	"mws-ai/pkg/ratelimit"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RateLimit spends one token of the budget per request. The bucket is
+// per API key, else per user, else per client IP, so it must run after
+// the auth middleware to tell callers apart. Responses carry the
+// RateLimit-* headers; a store outage lets requests through.
+func RateLimit(limiter *ratelimit.Limiter, budget string) fiber.Handler {
+	return rateLimit(limiter, budget, rateLimitKey)
+}
+
+// RateLimitIP buckets by client IP only. It runs before the auth
+// middleware, so requests with bad tokens or keys are budgeted too.
+func RateLimitIP(limiter *ratelimit.Limiter, budget string) fiber.Handler {
+	return rateLimit(limiter, budget, func(c *fiber.Ctx) string {
+		return "ip:" + c.IP()
+	})
+}
+
+func rateLimit(limiter *ratelimit.Limiter, budget string, keyFunc func(c *fiber.Ctx) string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		key := keyFunc(c)
+
+		res, limited, err := limiter.Take(budget, key)
+		if err != nil {
+			logger.Log.Error().
+				Str("component", "rate_limit").
+				Str("budget", budget).
+				Err(err).
+				Msg("rate limit store failed, allowing request")
+
+			return c.Next()
+		}
+		if !limited {
+			return c.Next()
+		}
+
+		c.Set("RateLimit-Policy", limiter.Policy(budget))
+		c.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetIn)))
+
+		if !res.Allowed {
+			logger.Log.Info().
+				Str("component", "rate_limit").
+				Str("budget", budget).
+				Str("key", key).
+				Str("path", c.Path()).
+				Msg("rate limit exceeded")
+
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(res.RetryIn)))
+			return fiber.NewError(fiber.StatusTooManyRequests, "rate limit exceeded")
+		}
+
+		return c.Next()
+	}
+}
+
+func rateLimitKey(c *fiber.Ctx) string {
+	if key, ok := c.Locals("api_key").(*models.ApiKey); ok {
+		return fmt.Sprintf("key:%d", key.ID)
+	}
+	if userID, ok := c.Locals("user_id").(uint); ok {
+		return fmt.Sprintf("user:%d", userID)
+	}
+	return "ip:" + c.IP()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mws-ai/pkg/ratelimit"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRateLimitIPRunsBeforeAuth(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), map[string]ratelimit.Limit{
+		"ip":     {Burst: 3, Per: time.Hour},
+		"manage": {Burst: 1, Per: time.Hour},
+	})
+
+	app := fiber.New()
+	app.Get("/",
+		RateLimitIP(limiter, "ip"),
+		func(c *fiber.Ctx) error {
+			// аутентификация: без заголовка — 401
+			if c.Get("X-User") == "" {
+				return fiber.ErrUnauthorized
+			}
+			c.Locals("user_id", uint(len(c.Get("X-User"))))
+			return c.Next()
+		},
+		RateLimit(limiter, "manage"),
+		func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) },
+	)
+
+	do := func(user string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		if user != "" {
+			req.Header.Set("X-User", user)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	steps := []struct {
+		name string
+		user string
+		want int
+	}{
+		{"bad credentials are rejected", "", fiber.StatusUnauthorized},
+		{"user spends the per-actor budget", "a", fiber.StatusOK},
+		{"per-actor budget exhausted", "a", fiber.StatusTooManyRequests},
+		// IP-бюджет исчерпан, хотя запросы без токена не дошли до лимита по пользователю
+		{"per-IP budget exhausted before auth", "", fiber.StatusTooManyRequests},
+		{"per-IP budget covers every user", "bb", fiber.StatusTooManyRequests},
+	}
+
+	for _, s := range steps {
+		if got := do(s.user); got != s.want {
+			t.Fatalf("%s: status %d, want %d", s.name, got, s.want)
+		}
+	}
+}
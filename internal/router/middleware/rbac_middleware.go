@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"errors"
+	"strconv"
+
+	"mws-ai/internal/auth/rbac"
+	"mws-ai/internal/models"
+	"mws-ai/internal/services"
+	"mws-ai/pkg/jwt"
+	"mws-ai/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+// OrgMiddleware resolves the organization the request acts in and the
+// caller's effective role there. Must run after an auth middleware.
+//
+// JWT callers pick an organization with the X-Organization-ID header
+// (default: their oldest membership). API keys are bound to the
+// organization they were issued in, capped by their type and limited
+// to their scopes and projects. Organizations that require 2FA reject
+// sessions that didn't pass a second factor.
+func OrgMiddleware(orgs *services.OrganizationService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		log := logger.Log.With().
+			Str("component", "auth").
+			Str("middleware", "Org").
+			Str("path", c.Path()).
+			Logger()
+
+		userID, ok := c.Locals("user_id").(uint)
+		if !ok {
+			log.Warn().Msg("user_id missing in context")
+			return fiber.ErrUnauthorized
+		}
+
+		var orgID uint
+		key, isAPIKey := c.Locals("api_key").(*models.ApiKey)
+
+		if isAPIKey {
+			orgID = key.OrganizationID
+		} else if raw := c.Get("X-Organization-ID"); raw != "" {
+			v, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil || v == 0 {
+				log.Debug().Str("org_header", raw).Msg("invalid organization header")
+				return fiber.NewError(fiber.StatusBadRequest, "invalid X-Organization-ID")
+			}
+			orgID = uint(v)
+		}
+
+		actor, err := orgs.ResolveActor(userID, orgID)
+		if err != nil {
+			if errors.Is(err, services.ErrNotMember) || errors.Is(err, services.ErrNoOrganizations) {
+				log.Info().
+					Err(err).
+					Uint("user_id", userID).
+					Uint("org_id", orgID).
+					Msg("organization access denied")
+
+				return fiber.ErrForbidden
+			}
+
+			log.Error().Err(err).Msg("failed to resolve organization")
+			return fiber.ErrInternalServerError
+		}
+
+		// 2FA-политика организации касается сессий пользователей; API-ключи — машинные
+		if !isAPIKey {
+			required, err := orgs.RequiresMFA(actor.OrgID)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to check organization mfa policy")
+				return fiber.ErrInternalServerError
+			}
+
+			claims, _ := c.Locals("jwt_claims").(gojwt.MapClaims)
+			if required && !jwt.MFA(claims) {
+				log.Info().
+					Uint("user_id", userID).
+					Uint("org_id", actor.OrgID).
+					Msg("session without 2FA rejected by organization policy")
+
+				return fiber.NewError(fiber.StatusForbidden, services.ErrMFARequired.Error())
+			}
+		}
+
+		if isAPIKey {
+			actor.APIKeyID = key.ID
+			actor.Role = rbac.Min(actor.Role, services.KeyRole(key.Type))
+			actor.Scopes = services.KeyScopes(key)
+			actor.Projects = services.KeyProjects(key)
+		}
+
+		log.Debug().
+			Uint("user_id", actor.UserID).
+			Uint("org_id", actor.OrgID).
+			Str("role", string(actor.Role)).
+			Msg("organization resolved")
+
+		rbac.SetActor(c, actor)
+		return c.Next()
+	}
+}
+
+// RequirePermission rejects the request with 403 unless the actor's
+// role grants the permission
+func RequirePermission(perm rbac.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		actor, ok := rbac.ActorFromCtx(c)
+		if !ok {
+			logger.Log.Warn().
+				Str("component", "auth").
+				Str("middleware", "RequirePermission").
+				Str("path", c.Path()).
+				Msg("actor missing in context")
+
+			return fiber.ErrUnauthorized
+		}
+
+		if !actor.Can(perm) {
+			logger.Log.Info().
+				Str("component", "auth").
+				Str("middleware", "RequirePermission").
+				Str("path", c.Path()).
+				Uint("user_id", actor.UserID).
+				Uint("org_id", actor.OrgID).
+				Str("role", string(actor.Role)).
+				Str("permission", string(perm)).
+				Msg("permission denied")
+
+			return fiber.ErrForbidden
+		}
+
+		return c.Next()
+	}
+}
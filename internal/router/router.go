@@ -10,6 +10,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 
+	"mws-ai/internal/auth/rbac"
 	"mws-ai/internal/config"
 	"mws-ai/internal/router/middleware"
 	"mws-ai/internal/services/clients"
@@ -18,58 +19,163 @@ import (
 	analysisHandlers "mws-ai/internal/handlers/analysis"
 	authHandlers "mws-ai/internal/handlers/auth"
 	healthHandlers "mws-ai/internal/handlers/health"
+	orgHandlers "mws-ai/internal/handlers/org"
+	suppressionHandlers "mws-ai/internal/handlers/suppression"
 
 	"mws-ai/internal/repository"
 	sarif "mws-ai/internal/sarif"
 	"mws-ai/internal/services"
+	"mws-ai/internal/source"
+	"mws-ai/pkg/blob"
 	jwtpkg "mws-ai/pkg/jwt"
+	"mws-ai/pkg/logger"
+	"mws-ai/pkg/ratelimit"
 )
 
-func Setup(cfg *config.Config, db *gorm.DB) *fiber.App {
-	app := fiber.New()
+// maxSourceFile skips huge generated files in source archives
+const maxSourceFile = 1 << 20
+
+func Setup(cfg *config.Config, db *gorm.DB) (*fiber.App, error) {
+	maxUpload := int64(cfg.UploadMaxMB) << 20
+	maxSource := int64(cfg.SourceMaxMB) << 20
+
+	app := fiber.New(fiber.Config{
+		// отчёт, необязательный архив исходников и запас на multipart-обвязку
+		BodyLimit: int(maxUpload+maxSource) + 1<<20,
+	})
 
 	middleware.DefaultMiddleware(app)
 
-	jwtManager := jwtpkg.NewJWTManager(
-		cfg.JWTSecret,
-		15*time.Minute,
-		7*24*time.Hour,
-	)
+	jwtManager, err := newJWTManager(cfg, app)
+	if err != nil {
+		return nil, err
+	}
 
 	// INIT REPOSITORIES
 	userRepo := repository.NewUserRepository(db)
 	apiKeyRepo := repository.NewAPIKeyRepository(db)
 	analysisRepo := repository.NewAnalysisRepository(db)
 	findingRepo := repository.NewFindingRepository(db)
+	orgRepo := repository.NewOrganizationRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	revokedTokenRepo := repository.NewRevokedTokenRepository(db)
+	oidcStateRepo := repository.NewOIDCStateRepository(db)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	recoveryCodeRepo := repository.NewRecoveryCodeRepository(db)
+	suppressionRepo := repository.NewSuppressionRepository(db)
 
 	// INIT PARSER
 	parser := sarif.NewParser()
 
 	// INIT CLIENTS 4 PIPELINE
-	heuristicClient := clients.NewHeuristicClient(cfg.HeuristicURL)
+	heuristicClient := newHeuristicClient(cfg)
 	mlClient := clients.NewMLClient(cfg.MLURL)
-	llmClient := clients.NewLLMClient(cfg.LLMURL)
-	// INIT PIPELINE EXECUTOR
-	pipeline := services.NewPipelineExecutor(heuristicClient, mlClient, llmClient)
+	llmClient := clients.NewLLMClient(cfg.LLMURL, cfg.LLMRedaction)
 	// INIT SERVICES
-	authService := services.NewAuthService(userRepo, jwtManager)
+	sessionService := services.NewSessionService(jwtManager, userRepo, revokedTokenRepo, refreshTokenRepo)
+	orgService := services.NewOrganizationService(orgRepo, userRepo)
+	auditService := services.NewAuditService(auditRepo)
+	suppressionService := services.NewSuppressionService(suppressionRepo, auditService)
+	// INIT PIPELINE EXECUTOR
+	pipeline := services.NewPipelineExecutor(suppressionService, heuristicClient, mlClient, llmClient)
+	loginGuard := services.NewLoginGuard(loginAttemptRepo, auditService, services.LockoutPolicy{
+		MaxFailures:   cfg.LoginMaxFailures,
+		IPMaxFailures: cfg.LoginIPMaxFailures,
+		Window:        time.Duration(cfg.LoginFailureWindowMin) * time.Minute,
+		BaseLockout:   time.Duration(cfg.LoginLockoutBaseSec) * time.Second,
+		MaxLockout:    time.Duration(cfg.LoginLockoutMaxMin) * time.Minute,
+	})
+	passwordPolicy := services.PasswordPolicy{
+		MinLength:     cfg.PasswordMinLength,
+		RequireUpper:  cfg.PasswordRequireUpper,
+		RequireLower:  cfg.PasswordRequireLower,
+		RequireDigit:  cfg.PasswordRequireDigit,
+		RequireSymbol: cfg.PasswordRequireSymbol,
+	}
+	authService := services.NewAuthService(
+		userRepo,
+		refreshTokenRepo,
+		orgService,
+		jwtManager,
+		passwordPolicy,
+		loginGuard,
+		newMailer(cfg),
+		services.EmailSettings{
+			AppURL:              cfg.AppURL,
+			VerifyTTL:           time.Duration(cfg.EmailVerifyTTLHours) * time.Hour,
+			ResetTTL:            time.Duration(cfg.PasswordResetTTLMin) * time.Minute,
+			RequireVerification: cfg.EmailVerificationRequired,
+		},
+		auditService,
+	)
 	apiKeyService := services.NewAPIKeyService(apiKeyRepo)
+	secrets, err := cfg.Keyring()
+	if err != nil {
+		return nil, err
+	}
+	blobs, err := cfg.BlobStore()
+	if err != nil {
+		return nil, err
+	}
+	// сырой отчёт содержит все секреты открытым текстом
+	if secrets != nil {
+		blobs = blob.NewSealedStore(blobs, secrets)
+	}
+	mfaService := services.NewMFAService(userRepo, recoveryCodeRepo, authService, auditService, secrets, cfg.MFAIssuer)
 	analysisService := services.NewAnalysisService(
 		analysisRepo,
 		findingRepo,
 		parser,
 		pipeline,
+		blobs,
+		secrets,
+		auditService,
+		services.SourceContext{
+			Lines: cfg.SourceContextLines,
+			Limits: source.Limits{
+				MaxFileSize: maxSourceFile,
+				MaxUnpacked: int64(cfg.SourceMaxUnpackedMB) << 20,
+			},
+		},
 	)
+	startRetention(app, services.NewReportRetention(
+		analysisRepo,
+		blobs,
+		time.Duration(cfg.ReportRetentionDays)*24*time.Hour,
+	))
 	// INIT HANDLERS
-	authHandler := authHandlers.NewAuthHandler(authService)
+	authHandler := authHandlers.NewAuthHandler(authService, sessionService)
+	mfaHandler := authHandlers.NewMFAHandler(mfaService)
 	apiKeyHandler := authHandlers.NewAPIKeyHandler(apiKeyService)
+	orgHandler := orgHandlers.NewOrgHandler(orgService)
+	suppressionHandler := suppressionHandlers.NewSuppressionHandler(suppressionService)
 
 	analysisHandler := analysisHandlers.NewAnalysisHandler(analysisService)
-	uploadHandler := analysisHandlers.NewUploadHandler(analysisService, cfg.UploadDir)
+	uploadHandler := analysisHandlers.NewUploadHandler(
+		analysisService,
+		cfg.UploadDir,
+		maxUpload,
+		maxSource,
+		sarif.Limits{
+			MaxUnpacked: int64(cfg.UploadMaxUnpackedMB) << 20,
+			MaxFiles:    cfg.UploadMaxFiles,
+		},
+	)
+
+	// RATE LIMITS
+	limiter := newRateLimiter(cfg, db)
+	authLimit := middleware.RateLimit(limiter, budgetAuth)
+	// per-IP бюджет до аутентификации: запросы с плохими токенами тоже считаются
+	ipLimit := middleware.RateLimitIP(limiter, budgetIP)
+	manageLimit := middleware.RateLimit(limiter, budgetManage)
 
 	// ROUTER STRUCTURE
 	api := app.Group("/api")
 
+	// JWKS (публичные ключи для проверки наших токенов другими сервисами)
+	app.Get("/.well-known/jwks.json", authHandlers.JWKSHandler(jwtManager))
+
 	// HEALTH CHECKPOINT
 	api.Get("/health", healthHandlers.HealthHandler())
 
@@ -79,24 +185,239 @@ func Setup(cfg *config.Config, db *gorm.DB) *fiber.App {
 	// AUTH ROUTES
 	authGroup := api.Group("/auth")
 	{
-		authGroup.Post("/register", authHandler.Register())
-		authGroup.Post("/login", authHandler.Login())
+		authGroup.Post("/register", authLimit, authHandler.Register())
+		authGroup.Post("/login", authLimit, authHandler.Login())
+		authGroup.Post("/refresh", authLimit, authHandler.Refresh())
+		authGroup.Get("/verify-email", authLimit, authHandler.VerifyEmail())
+		authGroup.Post("/verify-email/resend", authLimit, authHandler.ResendVerification())
+		authGroup.Post("/password-reset", authLimit, authHandler.RequestPasswordReset())
+		authGroup.Post("/password-reset/confirm", authLimit, authHandler.ResetPassword())
+		authGroup.Post("/logout", ipLimit, authMiddleware.JWTMiddleware(sessionService), authHandler.Logout())
+		authGroup.Post("/logout-all", ipLimit, authMiddleware.JWTMiddleware(sessionService), authHandler.LogoutAll())
+
+		// SSO (OIDC), только если настроен провайдер
+		if cfg.OIDCEnabled() {
+			oidcCfg := oidcConfig(cfg)
+			oidcService := services.NewOIDCService(
+				oidcCfg,
+				clients.NewOIDCClient(oidcCfg),
+				oidcStateRepo,
+				userRepo,
+				orgService,
+				authService,
+			)
+			oidcHandler := authHandlers.NewOIDCHandler(oidcService, cfg.AppEnv != "dev")
+
+			authGroup.Get("/oidc/login", authLimit, oidcHandler.Login())
+			authGroup.Get("/oidc/callback", authLimit, oidcHandler.Callback())
+		}
 
 		// выдача API ключа
-		authGroup.Post("/api-key", authMiddleware.JWTMiddleware(jwtManager), apiKeyHandler.CreateAPIKey())
+		authGroup.Post("/api-key",
+			ipLimit,
+			authMiddleware.JWTMiddleware(sessionService),
+			middleware.OrgMiddleware(orgService),
+			manageLimit,
+			middleware.RequirePermission(rbac.PermAPIKeysManage),
+			apiKeyHandler.CreateAPIKey(),
+		)
+	}
+	mfaGroup := authGroup.Group("/mfa")
+	{
+		// второй шаг входа: по mfa_token, без JWT
+		mfaGroup.Post("/verify", authLimit, mfaHandler.Verify())
+
+		mfaSession := []fiber.Handler{ipLimit, authMiddleware.JWTMiddleware(sessionService), manageLimit}
+
+		mfaGroup.Get("/", append(mfaSession, mfaHandler.Status())...)
+		mfaGroup.Post("/totp", append(mfaSession, mfaHandler.Enroll())...)
+		mfaGroup.Post("/totp/confirm", append(mfaSession, mfaHandler.ConfirmEnrollment())...)
+		mfaGroup.Post("/totp/disable", append(mfaSession, mfaHandler.Disable())...)
+		mfaGroup.Post("/recovery-codes", append(mfaSession, mfaHandler.RegenerateRecoveryCodes())...)
+	}
+	apiKeysGroup := authGroup.Group("/api-keys",
+		ipLimit,
+		authMiddleware.JWTMiddleware(sessionService),
+		middleware.OrgMiddleware(orgService),
+		manageLimit,
+		middleware.RequirePermission(rbac.PermAPIKeysManage),
+	)
+	{
+		apiKeysGroup.Get("/", apiKeyHandler.ListAPIKeys())
+		apiKeysGroup.Delete("/:id", apiKeyHandler.RevokeAPIKey())
+		apiKeysGroup.Post("/:id/rotate", apiKeyHandler.RotateAPIKey())
+	}
+
+	// ORGANIZATION ROUTES (JWT only)
+	orgGroup := api.Group("/orgs", ipLimit, authMiddleware.JWTMiddleware(sessionService), manageLimit)
+	{
+		orgGroup.Get("/", orgHandler.List())
+		orgGroup.Post("/", orgHandler.Create())
+	}
+	orgGroup.Patch("/settings",
+		middleware.OrgMiddleware(orgService),
+		middleware.RequirePermission(rbac.PermOrgManage),
+		orgHandler.UpdateSettings(),
+	)
+	membersGroup := orgGroup.Group("/members", middleware.OrgMiddleware(orgService))
+	{
+		membersGroup.Get("/", middleware.RequirePermission(rbac.PermOrgRead), orgHandler.ListMembers())
+		membersGroup.Post("/", middleware.RequirePermission(rbac.PermOrgManage), orgHandler.AddMember())
+		membersGroup.Patch("/:user_id", middleware.RequirePermission(rbac.PermOrgManage), orgHandler.UpdateMemberRole())
+		membersGroup.Delete("/:user_id", middleware.RequirePermission(rbac.PermOrgManage), orgHandler.RemoveMember())
 	}
 
 	// ANALYSIS ROUTES (protected)
-	analysisGroup := api.Group("/analyses", middleware.AuthMiddleware(jwtManager, apiKeyService))
+	analysisGroup := api.Group("/analyses",
+		ipLimit,
+		middleware.AuthMiddleware(sessionService, apiKeyService),
+		middleware.OrgMiddleware(orgService),
+	)
 	{
-		analysisGroup.Get("/", analysisHandler.List())
-		analysisGroup.Get("/:id", analysisHandler.Get())
+		uploadLimit := middleware.RateLimit(limiter, budgetUpload)
+		readLimit := middleware.RateLimit(limiter, budgetRead)
+
+		analysisGroup.Post("/upload", uploadLimit, middleware.RequirePermission(rbac.PermAnalysesWrite), uploadHandler.Upload())
+		analysisGroup.Post("/", uploadLimit, middleware.RequirePermission(rbac.PermAnalysesWrite), uploadHandler.UploadRaw())
+		analysisGroup.Get("/:id", readLimit, middleware.RequirePermission(rbac.PermAnalysesRead), analysisHandler.Get())
+		analysisGroup.Get("/:id/findings/:finding_id/value", readLimit, middleware.RequirePermission(rbac.PermFindingsReveal), analysisHandler.RevealValue())
+		analysisGroup.Get("/", readLimit, middleware.RequirePermission(rbac.PermAnalysesRead), analysisHandler.List())
 	}
+
+	// SUPPRESSION RULES
+	suppressionGroup := api.Group("/suppressions",
+		ipLimit,
+		middleware.AuthMiddleware(sessionService, apiKeyService),
+		middleware.OrgMiddleware(orgService),
+		manageLimit,
+	)
 	{
-		analysisGroup.Post("/upload", uploadHandler.Upload())
-		analysisGroup.Get("/:id", analysisHandler.Get())
-		analysisGroup.Get("/", analysisHandler.List())
+		suppressionGroup.Get("/", middleware.RequirePermission(rbac.PermAnalysesRead), suppressionHandler.List())
+		suppressionGroup.Post("/", middleware.RequirePermission(rbac.PermSuppressionsManage), suppressionHandler.Create())
+		suppressionGroup.Put("/:id", middleware.RequirePermission(rbac.PermSuppressionsManage), suppressionHandler.Update())
+		suppressionGroup.Delete("/:id", middleware.RequirePermission(rbac.PermSuppressionsManage), suppressionHandler.Delete())
 	}
 
-	return app
+	return app, nil
+}
+
+// rate limit budgets
+const (
+	budgetUpload = "upload"
+	budgetRead   = "read"
+	budgetAuth   = "auth"   // public auth endpoints, per IP
+	budgetIP     = "ip"     // every authenticated route, per IP, before auth
+	budgetManage = "manage" // management routes, per key or user
+)
+
+func newRateLimiter(cfg *config.Config, db *gorm.DB) *ratelimit.Limiter {
+	limits := map[string]ratelimit.Limit{}
+
+	for budget, raw := range map[string]string{
+		budgetUpload: cfg.RateLimitUpload,
+		budgetRead:   cfg.RateLimitRead,
+		budgetAuth:   cfg.RateLimitAuth,
+		budgetIP:     cfg.RateLimitIP,
+		budgetManage: cfg.RateLimitManage,
+	} {
+		if raw == "off" {
+			continue
+		}
+		// формат уже проверен в config.Validate
+		limit, _ := ratelimit.ParseLimit(raw)
+		limits[budget] = limit
+	}
+
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimitStore == "postgres" {
+		store = repository.NewRateLimitStore(db)
+	}
+
+	return ratelimit.NewLimiter(store, limits)
+}
+
+func newHeuristicClient(cfg *config.Config) services.HeuristicClient {
+	if cfg.HeuristicDriver == "native" {
+		return clients.NewNativeHeuristic()
+	}
+	return clients.NewHeuristicClient(cfg.HeuristicURL)
+}
+
+func newMailer(cfg *config.Config) services.Mailer {
+	switch cfg.MailDriver {
+	case "smtp":
+		return clients.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, cfg.MailFrom)
+	case "file":
+		return clients.NewFileMailer(cfg.MailDir, cfg.MailFrom)
+	default:
+		return clients.NewLogMailer()
+	}
+}
+
+// startRetention purges old raw reports hourly until shutdown
+func startRetention(app *fiber.App, retention *services.ReportRetention) {
+	stop := make(chan struct{})
+	app.Hooks().OnShutdown(func() error {
+		close(stop)
+		return nil
+	})
+
+	retention.Start(time.Hour, stop)
+}
+
+func oidcConfig(cfg *config.Config) services.OIDCConfig {
+	groupRoles := make(map[string]rbac.Role, len(cfg.OIDCGroupRoles))
+	for group, role := range cfg.OIDCGroupRoles {
+		groupRoles[group] = rbac.Role(role)
+	}
+
+	return services.OIDCConfig{
+		Issuer:         cfg.OIDCIssuer,
+		ClientID:       cfg.OIDCClientID,
+		ClientSecret:   cfg.OIDCClientSecret,
+		RedirectURL:    cfg.OIDCRedirectURL,
+		Scopes:         cfg.OIDCScopes,
+		EmailClaim:     cfg.OIDCEmailClaim,
+		GroupsClaim:    cfg.OIDCGroupsClaim,
+		GroupRoles:     groupRoles,
+		OrganizationID: uint(cfg.OIDCOrgID),
+	}
+}
+
+// newJWTManager builds the token manager for the configured algorithm and
+// starts scheduled key rotation for asymmetric keys
+func newJWTManager(cfg *config.Config, app *fiber.App) (*jwtpkg.JWTManager, error) {
+	accessTTL := time.Duration(cfg.JWTAccessExpireMin) * time.Minute
+	refreshTTL := time.Duration(cfg.JWTRefreshExpireHours) * time.Hour
+
+	if cfg.JWTAlg == jwtpkg.AlgHS256 {
+		return jwtpkg.NewJWTManager(cfg.JWTSecret, accessTTL, refreshTTL), nil
+	}
+
+	// ключ остаётся в JWKS, пока могут жить подписанные им refresh-токены
+	keys, err := jwtpkg.LoadKeyRing(cfg.JWTAlg, cfg.JWTKeyDir, refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	stop := make(chan struct{})
+	app.Hooks().OnShutdown(func() error {
+		close(stop)
+		return nil
+	})
+
+	keys.StartRotation(
+		time.Duration(cfg.JWTKeyRotationHours)*time.Hour,
+		stop,
+		func(err error) {
+			logger.Log.Error().Err(err).Str("component", "jwt").Msg("key rotation failed")
+		},
+	)
+
+	logger.Log.Info().
+		Str("alg", cfg.JWTAlg).
+		Str("key_dir", cfg.JWTKeyDir).
+		Msg("JWT key ring loaded")
+
+	return jwtpkg.NewJWTManagerWithKeys(keys, accessTTL, refreshTTL), nil
 }
@@ -0,0 +1,286 @@
+package sarif
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("file is neither JSON nor a gzip, zstd or zip archive")
+	ErrArchiveTooLarge   = errors.New("archive expands beyond the allowed size")
+	ErrTooManyFiles      = errors.New("archive contains too many files")
+	ErrEmptyArchive      = errors.New("archive contains no SARIF files")
+)
+
+// Format is the container an upload arrives in
+type Format int
+
+const (
+	FormatUnknown Format = iota
+	FormatJSON
+	FormatGzip
+	FormatZstd
+	FormatZip
+)
+
+// Limits guard against decompression bombs
+type Limits struct {
+	// MaxUnpacked caps the total decompressed size in bytes
+	MaxUnpacked int64
+	// MaxFiles caps the number of SARIF files in a zip archive
+	MaxFiles int
+}
+
+// DetectFormat looks at magic bytes, never at the file name
+func DetectFormat(head []byte) Format {
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return FormatGzip
+	case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return FormatZstd
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return FormatZip
+	case LooksLikeJSON(head):
+		return FormatJSON
+	}
+	return FormatUnknown
+}
+
+// Unpack turns a compressed upload into a single SARIF file and returns
+// its path. Plain JSON is returned as is. Every archive member is
+// validated on its own, so errors point at the right file; the runs of
+// all members are merged into one log, which becomes one analysis.
+// On success the compressed original is removed.
+func Unpack(src string, limits Limits) (string, error) {
+	format, err := detectFile(src)
+	if err != nil {
+		return "", err
+	}
+
+	var members []member
+	switch format {
+	case FormatJSON:
+		return src, nil
+	case FormatGzip, FormatZstd:
+		members, err = unpackStream(src, format, limits)
+	case FormatZip:
+		members, err = unpackZip(src, limits)
+	default:
+		return "", ErrUnsupportedFormat
+	}
+	if err != nil {
+		return "", err
+	}
+
+	dst := src + ".sarif"
+	if err := writeMerged(dst, members); err != nil {
+		_ = os.Remove(dst)
+		return "", err
+	}
+
+	_ = os.Remove(src)
+	return dst, nil
+}
+
+// member is one decompressed SARIF document
+type member struct {
+	name string
+	data []byte
+}
+
+func detectFile(src string) (Format, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return FormatUnknown, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return FormatUnknown, err
+	}
+
+	return DetectFormat(head[:n]), nil
+}
+
+// unpackStream handles single-file compression (gzip, zstd)
+func unpackStream(src string, format Format, limits Limits) ([]member, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader
+	switch format {
+	case FormatGzip:
+		gz, err := gzip.NewReader(bufio.NewReader(f))
+		if err != nil {
+			return nil, &ValidationError{Path: "$", Message: "corrupt gzip stream: " + err.Error()}
+		}
+		defer gz.Close()
+		r = gz
+	case FormatZstd:
+		zr, err := zstd.NewReader(f,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(uint64(limits.MaxUnpacked)),
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	budget := limits.MaxUnpacked
+	data, err := readLimited(r, &budget)
+	if err != nil {
+		return nil, err
+	}
+
+	m := member{name: strings.TrimSuffix(path.Base(src), path.Ext(src)), data: data}
+	if err := validateMember(m, false); err != nil {
+		return nil, err
+	}
+
+	return []member{m}, nil
+}
+
+func unpackZip(src string, limits Limits) ([]member, error) {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return nil, &ValidationError{Path: "$", Message: "corrupt zip archive: " + err.Error()}
+	}
+	defer zr.Close()
+
+	budget := limits.MaxUnpacked
+	members := make([]member, 0)
+
+	for _, zf := range zr.File {
+		if !isSarifEntry(zf) {
+			continue
+		}
+		if len(members) == limits.MaxFiles {
+			return nil, ErrTooManyFiles
+		}
+		// заголовку не верим, но явный перебор отсекаем сразу
+		if zf.UncompressedSize64 > uint64(budget) {
+			return nil, ErrArchiveTooLarge
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, &ValidationError{Path: "$", Message: fmt.Sprintf("%s: %v", zf.Name, err)}
+		}
+		data, err := readLimited(rc, &budget)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		m := member{name: zf.Name, data: data}
+		if err := validateMember(m, true); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	if len(members) == 0 {
+		return nil, ErrEmptyArchive
+	}
+
+	return members, nil
+}
+
+// isSarifEntry skips directories, OS junk and non-report files
+func isSarifEntry(zf *zip.File) bool {
+	if zf.FileInfo().IsDir() || strings.HasPrefix(zf.Name, "__MACOSX/") {
+		return false
+	}
+
+	base := path.Base(zf.Name)
+	if strings.HasPrefix(base, ".") {
+		return false
+	}
+
+	name := strings.ToLower(base)
+	return strings.HasSuffix(name, ".sarif") || strings.HasSuffix(name, ".json")
+}
+
+// readLimited reads r and charges it to the shared budget
+func readLimited(r io.Reader, budget *int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, *budget+1))
+	// zstd отказывает раньше лимита, если окно больше MaxUnpacked
+	if errors.Is(err, zstd.ErrWindowSizeExceeded) || errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		return nil, ErrArchiveTooLarge
+	}
+	if err != nil {
+		return nil, &ValidationError{Path: "$", Message: "corrupt compressed data: " + err.Error()}
+	}
+	if int64(len(data)) > *budget {
+		return nil, ErrArchiveTooLarge
+	}
+
+	*budget -= int64(len(data))
+	return data, nil
+}
+
+func validateMember(m member, named bool) error {
+	err := ValidateBytes(m.data)
+
+	var invalid *ValidationError
+	if named && errors.As(err, &invalid) {
+		invalid.File = m.name
+	}
+	return err
+}
+
+// writeMerged concatenates the runs of all members into one SARIF log.
+// Members are already validated, so runs is known to be an array.
+func writeMerged(dst string, members []member) error {
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, `{"version":%q,"runs":[`, SupportedVersion)
+
+	first := true
+	for _, m := range members {
+		var doc struct {
+			Runs []json.RawMessage `json:"runs"`
+		}
+		if err := json.Unmarshal(trimBOM(m.data), &doc); err != nil {
+			return fmt.Errorf("%s: %w", m.name, err)
+		}
+
+		for _, run := range doc.Runs {
+			if !first {
+				w.WriteByte(',')
+			}
+			first = false
+			w.Write(run)
+		}
+	}
+
+	w.WriteString("]}")
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,262 @@
+package sarif
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var testLimits = Limits{MaxUnpacked: 1 << 20, MaxFiles: 3}
+
+// sarifDoc is a valid log with one run; pad inflates it with whitespace,
+// which compresses to almost nothing
+func sarifDoc(tool string, pad int) []byte {
+	return []byte(`{"version":"2.1.0","runs":[{"tool":{"driver":{"name":"` + tool + `"}}}]}` + strings.Repeat(" ", pad))
+}
+
+func writeTemp(t *testing.T, data []byte) string {
+	t.Helper()
+
+	src := filepath.Join(t.TempDir(), "upload")
+	if err := os.WriteFile(src, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func gzipped(data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func zstded(data []byte) []byte {
+	w, _ := zstd.NewWriter(nil)
+	defer w.Close()
+	return w.EncodeAll(data, nil)
+}
+
+func zipped(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		head []byte
+		want Format
+	}{
+		{[]byte(` {"version"`), FormatJSON},
+		{gzipped([]byte("{}")), FormatGzip},
+		{zstded([]byte("{}")), FormatZstd},
+		{[]byte("PK\x03\x04rest"), FormatZip},
+		{[]byte("<sarif/>"), FormatUnknown},
+		{nil, FormatUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := DetectFormat(tt.head); got != tt.want {
+			t.Errorf("DetectFormat(%q) = %d, want %d", tt.head[:min(len(tt.head), 8)], got, tt.want)
+		}
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      func(t *testing.T) []byte
+		wantTools []string
+		wantErr   error
+	}{
+		{
+			name:      "gzip",
+			data:      func(*testing.T) []byte { return gzipped(sarifDoc("a", 0)) },
+			wantTools: []string{"a"},
+		},
+		{
+			name:      "zstd",
+			data:      func(*testing.T) []byte { return zstded(sarifDoc("a", 0)) },
+			wantTools: []string{"a"},
+		},
+		{
+			name: "zip merges runs and skips junk",
+			data: func(t *testing.T) []byte {
+				return zipped(t, map[string][]byte{
+					"a.sarif":            sarifDoc("a", 0),
+					"nested/b.json":      sarifDoc("b", 0),
+					"__MACOSX/._a.sarif": []byte("junk"),
+					".hidden.sarif":      []byte("junk"),
+					"README.md":          []byte("junk"),
+				})
+			},
+			wantTools: []string{"a", "b"},
+		},
+		{
+			name:    "gzip bomb",
+			data:    func(*testing.T) []byte { return gzipped(sarifDoc("a", 8<<20)) },
+			wantErr: ErrArchiveTooLarge,
+		},
+		{
+			name:    "zstd bomb",
+			data:    func(*testing.T) []byte { return zstded(sarifDoc("a", 8<<20)) },
+			wantErr: ErrArchiveTooLarge,
+		},
+		{
+			name: "zip members share one budget",
+			data: func(t *testing.T) []byte {
+				return zipped(t, map[string][]byte{
+					"a.sarif": sarifDoc("a", 600<<10),
+					"b.sarif": sarifDoc("b", 600<<10),
+				})
+			},
+			wantErr: ErrArchiveTooLarge,
+		},
+		{
+			name: "zip with too many files",
+			data: func(t *testing.T) []byte {
+				files := map[string][]byte{}
+				for _, n := range []string{"a", "b", "c", "d"} {
+					files[n+".sarif"] = sarifDoc(n, 0)
+				}
+				return zipped(t, files)
+			},
+			wantErr: ErrTooManyFiles,
+		},
+		{
+			name:    "zip without reports",
+			data:    func(t *testing.T) []byte { return zipped(t, map[string][]byte{"notes.txt": []byte("hi")}) },
+			wantErr: ErrEmptyArchive,
+		},
+		{
+			name:    "unknown format",
+			data:    func(*testing.T) []byte { return []byte("<xml/>") },
+			wantErr: ErrUnsupportedFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := writeTemp(t, tt.data(t))
+
+			dst, err := Unpack(src, testLimits)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Unpack err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unpack: %v", err)
+			}
+
+			if _, err := os.Stat(src); !os.IsNotExist(err) {
+				t.Error("compressed original was not removed")
+			}
+
+			var log Sarif
+			data, _ := os.ReadFile(dst)
+			if err := ValidateBytes(data); err != nil {
+				t.Fatalf("merged log is invalid: %v", err)
+			}
+			if err := json.Unmarshal(data, &log); err != nil {
+				t.Fatal(err)
+			}
+
+			var tools []string
+			for _, run := range log.Runs {
+				tools = append(tools, run.Tool.Driver.Name)
+			}
+			slices.Sort(tools)
+			// zipped пишет файлы в порядке обхода map
+			if strings.Join(tools, ",") != strings.Join(tt.wantTools, ",") {
+				t.Fatalf("runs from %v, want %v", tools, tt.wantTools)
+			}
+		})
+	}
+}
+
+// rawZip stores a deflated member under an arbitrary declared size
+func rawZip(t *testing.T, doc []byte, declared uint64) []byte {
+	t.Helper()
+
+	var deflated bytes.Buffer
+	fw, _ := flate.NewWriter(&deflated, flate.BestCompression)
+	fw.Write(doc)
+	fw.Close()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.CreateRaw(&zip.FileHeader{
+		Name:               "a.sarif",
+		Method:             zip.Deflate,
+		CRC32:              crc32.ChecksumIEEE(doc),
+		CompressedSize64:   uint64(deflated.Len()),
+		UncompressedSize64: declared,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(deflated.Bytes())
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUnpackZipDeclaredSize(t *testing.T) {
+	doc := sarifDoc("a", 8<<20)
+
+	// честный заголовок сверх бюджета отсекается до распаковки
+	if _, err := Unpack(writeTemp(t, rawZip(t, doc, uint64(len(doc)))), testLimits); !errors.Is(err, ErrArchiveTooLarge) {
+		t.Fatalf("Unpack err = %v, want ErrArchiveTooLarge", err)
+	}
+
+	// заниженный размер не даёт распаковать больше заявленного
+	if _, err := Unpack(writeTemp(t, rawZip(t, doc, 100)), testLimits); err == nil {
+		t.Fatal("Unpack accepted a member larger than its declared size")
+	}
+}
+
+func TestUnpackNamesInvalidMember(t *testing.T) {
+	src := writeTemp(t, zipped(t, map[string][]byte{"reports/bad.sarif": []byte(`{"version":"1.0"}`)}))
+
+	var invalid *ValidationError
+	if _, err := Unpack(src, testLimits); !errors.As(err, &invalid) || invalid.File != "reports/bad.sarif" {
+		t.Fatalf("Unpack err = %v, want a ValidationError for reports/bad.sarif", err)
+	}
+}
+
+func TestUnpackKeepsPlainJSON(t *testing.T) {
+	src := writeTemp(t, sarifDoc("a", 0))
+
+	dst, err := Unpack(src, testLimits)
+	if err != nil || dst != src {
+		t.Fatalf("Unpack = %q, %v, want the source path", dst, err)
+	}
+}
@@ -21,7 +21,7 @@ func (p *Parser) Parse(path string) ([]models.Finding, error) {
 	}
 
 	var sarif Sarif
-	if err := json.Unmarshal(data, &sarif); err != nil {
+	if err := json.Unmarshal(trimBOM(data), &sarif); err != nil {
 		return nil, fmt.Errorf("parse sarif json: %w", err)
 	}
 
@@ -31,38 +31,42 @@ func (p *Parser) Parse(path string) ([]models.Finding, error) {
 func convertToFindings(s Sarif) []models.Finding {
 	findings := make([]models.Finding, 0)
 
-	if len(s.Runs) == 0 {
-		return findings
-	}
-
-	for _, result := range s.Runs[0].Results {
-		for _, loc := range result.Locations {
-
-			line := loc.PhysicalLocation.Region.StartLine
-			var lineEnd *int
-			if loc.PhysicalLocation.Region.EndLine != 0 {
-				v := loc.PhysicalLocation.Region.EndLine
-				lineEnd = &v
-			}
-
-			value := result.Properties.Snippet
-			if value == "" {
-				value = result.Message.Text
+	// несколько runs — это несколько сканеров или файлов из одного архива
+	for _, run := range s.Runs {
+		for _, result := range run.Results {
+			for _, loc := range result.Locations {
+
+				line := loc.PhysicalLocation.Region.StartLine
+				var lineEnd *int
+				if loc.PhysicalLocation.Region.EndLine != 0 {
+					v := loc.PhysicalLocation.Region.EndLine
+					lineEnd = &v
+				}
+
+				value := result.Properties.Snippet
+				if value == "" {
+					value = result.Message.Text
+				}
+
+				f := models.Finding{
+					FilePath: loc.PhysicalLocation.ArtifactLocation.URI,
+					Line:     line,
+					LineEnd:  lineEnd,
+
+					Value:  value,
+					RuleID: result.RuleID,
+
+					Severity:          result.Properties.Severity,
+					ScannerConfidence: result.Properties.Confidence,
+				}
+
+				if ctx := loc.PhysicalLocation.ContextRegion; ctx != nil && ctx.Snippet != nil {
+					f.Context = ctx.Snippet.Text
+					f.ContextStartLine = ctx.StartLine
+				}
+
+				findings = append(findings, f)
 			}
-
-			f := models.Finding{
-				FilePath: loc.PhysicalLocation.ArtifactLocation.URI,
-				Line:     line,
-				LineEnd:  lineEnd,
-
-				Value:  value,
-				RuleID: result.RuleID,
-
-				Severity:          result.Properties.Severity,
-				ScannerConfidence: result.Properties.Confidence,
-			}
-
-			findings = append(findings, f)
 		}
 	}
 
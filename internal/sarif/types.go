@@ -39,6 +39,8 @@ type Location struct {
 type PhysicalLocation struct {
 	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
 	Region           Region           `json:"region"`
+	// ContextRegion is the surrounding source, when the scanner includes it
+	ContextRegion *Region `json:"contextRegion,omitempty"`
 }
 
 type ArtifactLocation struct {
@@ -46,8 +48,13 @@ type ArtifactLocation struct {
 }
 
 type Region struct {
-	StartLine int `json:"startLine"`
-	EndLine   int `json:"endLine,omitempty"`
+	StartLine int              `json:"startLine"`
+	EndLine   int              `json:"endLine,omitempty"`
+	Snippet   *ArtifactContent `json:"snippet,omitempty"`
+}
+
+type ArtifactContent struct {
+	Text string `json:"text"`
 }
 
 type Properties struct {
@@ -0,0 +1,383 @@
+package sarif
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// SupportedVersion is the SARIF version accepted on upload
+const SupportedVersion = "2.1.0"
+
+// ValidationError describes why a document is not valid SARIF.
+// Path is a JSONPath to the offending value ("$" for the document);
+// File names the archive member, if the upload was an archive.
+type ValidationError struct {
+	File    string
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("invalid sarif in %s at %s: %s", e.File, e.Path, e.Message)
+	}
+	return fmt.Sprintf("invalid sarif at %s: %s", e.Path, e.Message)
+}
+
+// Validate checks the file with ValidateBytes
+func (p *Parser) Validate(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read sarif file: %w", err)
+	}
+	return ValidateBytes(data)
+}
+
+// ValidateBytes checks the document against the parts of the SARIF 2.1.0
+// schema the pipeline relies on: the run/tool/result structure, required
+// properties and value types. Unknown properties are allowed, as in the
+// schema itself.
+func ValidateBytes(data []byte) error {
+	data = trimBOM(data)
+
+	// дешёвая проверка до разбора: это вообще JSON-объект?
+	if !LooksLikeJSON(data) {
+		return &ValidationError{Path: "$", Message: "not a JSON object"}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return syntaxError(data, err)
+	}
+	if dec.More() {
+		return &ValidationError{Path: "$", Message: "unexpected data after the JSON document"}
+	}
+
+	v := validator{}
+	v.root(doc)
+
+	if v.err != nil {
+		return v.err
+	}
+	return nil
+}
+
+// LooksLikeJSON sniffs the head of a file: a SARIF log is a JSON object,
+// so the first significant byte must be '{'
+func LooksLikeJSON(head []byte) bool {
+	trimmed := bytes.TrimLeft(trimBOM(head), " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// trimBOM drops a UTF-8 byte order mark, which some Windows tools emit
+func trimBOM(data []byte) []byte {
+	return bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+}
+
+// syntaxError reports a JSON syntax error with its line and column
+func syntaxError(data []byte, err error) error {
+	var se *json.SyntaxError
+	if !errors.As(err, &se) {
+		return &ValidationError{Path: "$", Message: err.Error()}
+	}
+
+	// Offset считает и сам ошибочный байт
+	end := min(max(int(se.Offset)-1, 0), len(data))
+
+	line, col := 1, 1
+	for _, b := range data[:end] {
+		if b == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+
+	return &ValidationError{
+		Path:    "$",
+		Message: fmt.Sprintf("malformed JSON at line %d, column %d: %s", line, col, se.Error()),
+	}
+}
+
+// validator walks the document and keeps the first error
+type validator struct {
+	err *ValidationError
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	if v.err == nil {
+		v.err = &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)}
+	}
+}
+
+func (v *validator) root(doc interface{}) {
+	obj := v.object(doc, "$")
+	if obj == nil {
+		return
+	}
+
+	version, ok := v.requiredString(obj, "$", "version")
+	if ok && version != SupportedVersion {
+		v.fail("$.version", "unsupported version %q, expected %q", version, SupportedVersion)
+	}
+
+	runs := v.requiredArray(obj, "$", "runs")
+	for i, run := range runs {
+		v.run(run, fmt.Sprintf("$.runs[%d]", i))
+	}
+}
+
+func (v *validator) run(val interface{}, path string) {
+	run := v.object(val, path)
+	if run == nil {
+		return
+	}
+
+	tool := v.requiredObject(run, path, "tool")
+	if tool != nil {
+		driver := v.requiredObject(tool, path+".tool", "driver")
+		if driver != nil {
+			v.requiredString(driver, path+".tool.driver", "name")
+		}
+	}
+
+	results, present := run["results"]
+	if !present || results == nil {
+		return
+	}
+
+	list := v.array(results, path+".results")
+	for i, r := range list {
+		v.result(r, fmt.Sprintf("%s.results[%d]", path, i))
+	}
+}
+
+func (v *validator) result(val interface{}, path string) {
+	res := v.object(val, path)
+	if res == nil {
+		return
+	}
+
+	v.optionalString(res, path, "ruleId")
+
+	msg := v.requiredObject(res, path, "message")
+	if msg != nil {
+		_, hasText := msg["text"]
+		_, hasID := msg["id"]
+		if !hasText && !hasID {
+			v.fail(path+".message", `requires "text" or "id"`)
+		}
+		v.optionalString(msg, path+".message", "text")
+		v.optionalString(msg, path+".message", "id")
+	}
+
+	if props, ok := res["properties"]; ok {
+		v.properties(props, path+".properties")
+	}
+
+	locs, ok := res["locations"]
+	if !ok {
+		return
+	}
+
+	for i, l := range v.array(locs, path+".locations") {
+		v.location(l, fmt.Sprintf("%s.locations[%d]", path, i))
+	}
+}
+
+// properties checks the property bag keys the parser reads into typed
+// fields; anything else in the bag is left alone
+func (v *validator) properties(val interface{}, path string) {
+	props := v.object(val, path)
+	if props == nil {
+		return
+	}
+
+	for _, key := range []string{"snippet", "severity", "aiVerdict", "source"} {
+		v.optionalString(props, path, key)
+	}
+	v.optionalNumber(props, path, "confidence")
+}
+
+func (v *validator) location(val interface{}, path string) {
+	loc := v.object(val, path)
+	if loc == nil {
+		return
+	}
+
+	physVal, ok := loc["physicalLocation"]
+	if !ok {
+		return
+	}
+
+	path += ".physicalLocation"
+	phys := v.object(physVal, path)
+	if phys == nil {
+		return
+	}
+
+	if al, ok := phys["artifactLocation"]; ok {
+		if obj := v.object(al, path+".artifactLocation"); obj != nil {
+			v.optionalString(obj, path+".artifactLocation", "uri")
+		}
+	}
+
+	for _, key := range []string{"region", "contextRegion"} {
+		if r, ok := phys[key]; ok {
+			v.region(r, path+"."+key)
+		}
+	}
+}
+
+func (v *validator) region(val interface{}, path string) {
+	region := v.object(val, path)
+	if region == nil {
+		return
+	}
+
+	start, hasStart := v.optionalPositiveInt(region, path, "startLine")
+	end, hasEnd := v.optionalPositiveInt(region, path, "endLine")
+
+	if hasStart && hasEnd && end < start {
+		v.fail(path+".endLine", "must not be less than startLine (%d)", start)
+	}
+
+	if snippet, ok := region["snippet"]; ok {
+		if obj := v.object(snippet, path+".snippet"); obj != nil {
+			v.optionalString(obj, path+".snippet", "text")
+		}
+	}
+}
+
+// =====================
+// TYPE HELPERS
+// =====================
+
+func (v *validator) object(val interface{}, path string) map[string]interface{} {
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		v.fail(path, "expected object, got %s", typeName(val))
+		return nil
+	}
+	return obj
+}
+
+func (v *validator) array(val interface{}, path string) []interface{} {
+	arr, ok := val.([]interface{})
+	if !ok {
+		v.fail(path, "expected array, got %s", typeName(val))
+		return nil
+	}
+	return arr
+}
+
+func (v *validator) requiredObject(obj map[string]interface{}, path, key string) map[string]interface{} {
+	val, ok := obj[key]
+	if !ok {
+		v.fail(path, "missing required property %q", key)
+		return nil
+	}
+	return v.object(val, path+"."+key)
+}
+
+func (v *validator) requiredArray(obj map[string]interface{}, path, key string) []interface{} {
+	val, ok := obj[key]
+	if !ok {
+		v.fail(path, "missing required property %q", key)
+		return nil
+	}
+	return v.array(val, path+"."+key)
+}
+
+func (v *validator) requiredString(obj map[string]interface{}, path, key string) (string, bool) {
+	if _, ok := obj[key]; !ok {
+		v.fail(path, "missing required property %q", key)
+		return "", false
+	}
+	return v.optionalString(obj, path, key)
+}
+
+func (v *validator) optionalString(obj map[string]interface{}, path, key string) (string, bool) {
+	val, ok := obj[key]
+	if !ok {
+		return "", false
+	}
+
+	s, ok := val.(string)
+	if !ok {
+		v.fail(path+"."+key, "expected string, got %s", typeName(val))
+		return "", false
+	}
+	return s, true
+}
+
+func (v *validator) optionalNumber(obj map[string]interface{}, path, key string) (float64, bool) {
+	val, ok := obj[key]
+	if !ok {
+		return 0, false
+	}
+
+	num, ok := val.(json.Number)
+	if !ok {
+		v.fail(path+"."+key, "expected number, got %s", typeName(val))
+		return 0, false
+	}
+
+	f, err := num.Float64()
+	if err != nil {
+		v.fail(path+"."+key, "expected number, got %s", num)
+		return 0, false
+	}
+	return f, true
+}
+
+func (v *validator) optionalPositiveInt(obj map[string]interface{}, path, key string) (int, bool) {
+	val, ok := obj[key]
+	if !ok {
+		return 0, false
+	}
+
+	num, ok := val.(json.Number)
+	if !ok {
+		v.fail(path+"."+key, "expected integer, got %s", typeName(val))
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(num.String())
+	if err != nil {
+		v.fail(path+"."+key, "expected integer, got %s", num)
+		return 0, false
+	}
+	if n < 1 {
+		v.fail(path+"."+key, "must be >= 1, got %d", n)
+		return 0, false
+	}
+
+	return n, true
+}
+
+func typeName(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", val)
+}
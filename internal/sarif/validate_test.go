@@ -0,0 +1,135 @@
+package sarif
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const validDoc = `{
+  "version": "2.1.0",
+  "runs": [{
+    "tool": {"driver": {"name": "gitleaks"}},
+    "results": [{
+      "ruleId": "aws-access-key",
+      "message": {"text": "AWS key"},
+      "locations": [{"physicalLocation": {
+        "artifactLocation": {"uri": "main.go"},
+        "region": {"startLine": 3, "endLine": 4, "snippet": {"text": "key"}},
+        "contextRegion": {"startLine": 1, "endLine": 6}
+      }}]
+    }]
+  }]
+}`
+
+func TestValidateBytes(t *testing.T) {
+	// doc подставляет фрагмент results в минимальный документ
+	doc := func(results string) string {
+		return `{"version":"2.1.0","runs":[{"tool":{"driver":{"name":"x"}},"results":[` + results + `]}]}`
+	}
+
+	tests := []struct {
+		name     string
+		data     string
+		wantPath string
+		wantMsg  string
+	}{
+		{"valid", validDoc, "", ""},
+		{"BOM and no results", "\xef\xbb\xbf" + `{"version":"2.1.0","runs":[{"tool":{"driver":{"name":"x"}}}]}`, "", ""},
+		{"null results", `{"version":"2.1.0","runs":[{"tool":{"driver":{"name":"x"}},"results":null}]}`, "", ""},
+		{"message by id", doc(`{"message":{"id":"default"}}`), "", ""},
+
+		{"not an object", `[1,2]`, "$", "not a JSON object"},
+		{"empty", ``, "$", "not a JSON object"},
+		{"malformed", "{\n  \"version\": \"2.1.0\",\n  oops\n}", "$", "line 3, column 3"},
+		{"trailing data", `{"version":"2.1.0","runs":[]} {}`, "$", "unexpected data"},
+		{"missing version", `{"runs":[]}`, "$", `missing required property "version"`},
+		{"wrong version", `{"version":"2.0.0","runs":[]}`, "$.version", "unsupported version"},
+		{"runs not array", `{"version":"2.1.0","runs":{}}`, "$.runs", "expected array, got object"},
+		{"missing driver name", `{"version":"2.1.0","runs":[{"tool":{"driver":{}}}]}`, "$.runs[0].tool.driver", `"name"`},
+		{"results not array", `{"version":"2.1.0","runs":[{"tool":{"driver":{"name":"x"}},"results":"x"}]}`, "$.runs[0].results", "expected array"},
+		{"message without text", doc(`{"message":{}}`), "$.runs[0].results[0].message", `"text" or "id"`},
+		{"ruleId not string", doc(`{"ruleId":1,"message":{"text":"t"}}`), "$.runs[0].results[0].ruleId", "got number"},
+		{"confidence as text", doc(`{"message":{"text":"t"},"properties":{"confidence":"high"}}`), "$.runs[0].results[0].properties.confidence", "expected number, got string"},
+		{"severity as number", doc(`{"message":{"text":"t"},"properties":{"severity":3}}`), "$.runs[0].results[0].properties.severity", "expected string, got number"},
+		{"snippet as object", doc(`{"message":{"text":"t"},"properties":{"snippet":{"text":"x"}}}`), "$.runs[0].results[0].properties.snippet", "expected string, got object"},
+		{"typed properties", doc(`{"message":{"text":"t"},"properties":{"severity":"high","confidence":0.9,"snippet":"x","tags":["a"]}}`), "", ""},
+		{"properties not object", doc(`{"message":{"text":"t"},"properties":[]}`), "$.runs[0].results[0].properties", "expected object"},
+		{
+			"uri not string",
+			doc(`{"message":{"text":"t"},"locations":[{"physicalLocation":{"artifactLocation":{"uri":null}}}]}`),
+			"$.runs[0].results[0].locations[0].physicalLocation.artifactLocation.uri", "got null",
+		},
+		{
+			"zero start line",
+			doc(`{"message":{"text":"t"},"locations":[{"physicalLocation":{"region":{"startLine":0}}}]}`),
+			"$.runs[0].results[0].locations[0].physicalLocation.region.startLine", "must be >= 1",
+		},
+		{
+			"fractional line",
+			doc(`{"message":{"text":"t"},"locations":[{"physicalLocation":{"region":{"startLine":1.5}}}]}`),
+			"$.runs[0].results[0].locations[0].physicalLocation.region.startLine", "expected integer",
+		},
+		{
+			"end before start",
+			doc(`{"message":{"text":"t"},"locations":[{"physicalLocation":{"contextRegion":{"startLine":5,"endLine":2}}}]}`),
+			"$.runs[0].results[0].locations[0].physicalLocation.contextRegion.endLine", "less than startLine (5)",
+		},
+		// первая ошибка побеждает
+		{"first error is kept", doc(`{"message":{}},{"ruleId":1}`), "$.runs[0].results[0].message", `"text" or "id"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateBytes([]byte(tt.data))
+			if tt.wantPath == "" {
+				if err != nil {
+					t.Fatalf("ValidateBytes: %v", err)
+				}
+				// всё, что прошло валидацию, должен принять и парсер
+				var log Sarif
+				if err := json.Unmarshal(trimBOM([]byte(tt.data)), &log); err != nil {
+					t.Fatalf("valid document does not parse: %v", err)
+				}
+				return
+			}
+
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("err = %v, want *ValidationError", err)
+			}
+			if ve.Path != tt.wantPath || !strings.Contains(ve.Message, tt.wantMsg) {
+				t.Fatalf("err = %s: %s, want %s: …%s…", ve.Path, ve.Message, tt.wantPath, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestLooksLikeJSON(t *testing.T) {
+	tests := []struct {
+		head string
+		want bool
+	}{
+		{`{"version"`, true},
+		{"\xef\xbb\xbf \r\n\t{", true},
+		{"", false},
+		{"   ", false},
+		{"<?xml", false},
+		{"PK\x03\x04", false},
+		{"\x1f\x8b", false},
+	}
+
+	for _, tt := range tests {
+		if got := LooksLikeJSON([]byte(tt.head)); got != tt.want {
+			t.Errorf("LooksLikeJSON(%q) = %v, want %v", tt.head, got, tt.want)
+		}
+	}
+}
+
+func TestValidationErrorNamesArchiveMember(t *testing.T) {
+	err := &ValidationError{File: "reports/a.sarif", Path: "$.runs", Message: "expected array"}
+	if got := err.Error(); got != "invalid sarif in reports/a.sarif at $.runs: expected array" {
+		t.Fatalf("Error() = %q", got)
+	}
+}
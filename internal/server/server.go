@@ -13,7 +13,10 @@ import (
 )
 
 func Run(cfg *config.Config, db *gorm.DB) {
-	app := router.Setup(cfg, db)
+	app, err := router.Setup(cfg, db)
+	if err != nil {
+		logger.Log.Fatal().Err(err).Msg("Failed to set up router")
+	}
 
 	errChan := make(chan error)
 
@@ -1,16 +1,38 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"time"
 
+	"mws-ai/internal/auth/rbac"
 	"mws-ai/internal/models"
 	"mws-ai/internal/repository"
+	"mws-ai/internal/source"
+	"mws-ai/pkg/blob"
+	"mws-ai/pkg/envelope"
 	"mws-ai/pkg/logger"
+	"mws-ai/pkg/mask"
 )
 
+// ErrAnalysisNotFound is returned both for missing analyses and for analyses
+// of another organization, so callers can't probe foreign IDs.
+var ErrAnalysisNotFound = errors.New("analysis not found")
+
+// ErrFindingNotFound is returned for missing findings and for findings
+// of another analysis
+var ErrFindingNotFound = errors.New("finding not found")
+
+// ErrProjectNotAllowed is returned when a project-restricted API key
+// uploads a report for a repository outside its list.
+var ErrProjectNotAllowed = errors.New("api key is not allowed for this repository")
+
 // Interfaces
 type SarifParser interface {
 	Parse(filePath string) ([]models.Finding, error)
+	// Validate rejects malformed reports before an analysis is created
+	Validate(filePath string) error
 }
 
 type AnalysisService struct {
@@ -18,6 +40,18 @@ type AnalysisService struct {
 	findingRepo  repository.FindingRepository
 	parser       SarifParser
 	pipeline     PipelineExecutor
+	blobs        blob.Store
+	secrets      *envelope.Keyring // nil: values stay in plaintext (dev)
+	audit        *AuditService
+	context      SourceContext
+}
+
+// SourceContext controls how much of an uploaded source archive is
+// attached to each finding
+type SourceContext struct {
+	// Lines before and after the finding
+	Lines  int
+	Limits source.Limits
 }
 
 func NewAnalysisService(
@@ -25,32 +59,86 @@ func NewAnalysisService(
 	findingRepo repository.FindingRepository,
 	parser SarifParser,
 	pipeline PipelineExecutor,
+	blobs blob.Store,
+	secrets *envelope.Keyring,
+	audit *AuditService,
+	context SourceContext,
 ) *AnalysisService {
 	return &AnalysisService{
 		analysisRepo: analysisRepo,
 		findingRepo:  findingRepo,
 		parser:       parser,
 		pipeline:     pipeline,
+		blobs:        blobs,
+		secrets:      secrets,
+		audit:        audit,
+		context:      context,
 	}
 }
 
+// UploadMeta carries descriptive data about an uploaded report
+type UploadMeta struct {
+	FileName   string
+	Repository string
+	Commit     string
+	Branch     string
+	// SourcePath is an optional zip or tar.gz of the scanned sources;
+	// it is only read for context and removed after processing
+	SourcePath string
+}
+
 // =====================
 // UPLOAD ENTRYPOINT
 // =====================
+
+// Upload takes ownership of filePath and meta.SourcePath: they are removed
+// on every error here, or by processAnalysis once it's done with them.
 func (s *AnalysisService) Upload(
-	userID uint,
+	actor rbac.Actor,
 	filePath string,
+	meta UploadMeta,
 ) (*models.Analysis, error) {
 
 	log := logger.Log.With().
 		Str("service", "analysis").
 		Str("method", "Upload").
-		Uint("user_id", userID).
+		Uint("user_id", actor.UserID).
+		Uint("org_id", actor.OrgID).
 		Logger()
 
+	started := false
+	defer func() {
+		if !started {
+			removeUpload(filePath, meta.SourcePath)
+		}
+	}()
+
+	if !actor.CanAccessProject(meta.Repository) {
+		log.Info().
+			Str("repository", meta.Repository).
+			Uint("api_key_id", actor.APIKeyID).
+			Msg("upload rejected: repository not allowed for key")
+
+		return nil, ErrProjectNotAllowed
+	}
+
+	if err := s.parser.Validate(filePath); err != nil {
+		log.Info().
+			Err(err).
+			Str("file_name", meta.FileName).
+			Msg("upload rejected: invalid report")
+
+		return nil, err
+	}
+
 	analysis := &models.Analysis{
-		UserID: userID,
-		Status: "processing",
+		OrganizationID: actor.OrgID,
+		UserID:         actor.UserID,
+		FileName:       meta.FileName,
+		Repository:     meta.Repository,
+		Commit:         meta.Commit,
+		Branch:         meta.Branch,
+		Status:         "processing",
 	}
 
 	if err := s.analysisRepo.Create(analysis); err != nil {
@@ -61,17 +149,79 @@ func (s *AnalysisService) Upload(
 		Uint("analysis_id", analysis.ID).
 		Msg("analysis created")
 
-	go s.processAnalysis(analysis.ID, filePath)
+	key := reportKey(analysis)
+	if err := s.storeReport(key, filePath); err != nil {
+		log.Error().
+			Uint("analysis_id", analysis.ID).
+			Err(err).
+			Msg("failed to store raw report")
+
+		s.markFailed(analysis.ID)
+		return nil, err
+	}
+
+	if err := s.analysisRepo.SetStorageKey(analysis.ID, key); err != nil {
+		_ = s.blobs.Delete(key)
+		s.markFailed(analysis.ID)
+		return nil, err
+	}
+	analysis.StorageKey = key
+
+	started = true
+	go s.processAnalysis(analysis.ID, analysis.OrganizationID, filePath, meta.SourcePath)
 
 	return analysis, nil
 }
 
+// removeUpload drops the temporary files of an upload
+func removeUpload(filePath, sourcePath string) {
+	_ = os.Remove(filePath)
+	if sourcePath != "" {
+		_ = os.Remove(sourcePath)
+	}
+}
+
+// markFailed closes an analysis that can't be processed; a failure here
+// would leave it in "processing" forever, so it is at least logged
+func (s *AnalysisService) markFailed(id uint) {
+	if err := s.analysisRepo.UpdateStatus(id, "failed"); err != nil {
+		logger.Log.Error().
+			Str("service", "analysis").
+			Str("method", "markFailed").
+			Uint("analysis_id", id).
+			Err(err).
+			Msg("failed to mark analysis failed")
+	}
+}
+
+// reportKey places raw reports per organization
+func reportKey(a *models.Analysis) string {
+	return fmt.Sprintf("reports/%d/%d.sarif", a.OrganizationID, a.ID)
+}
+
+func (s *AnalysisService) storeReport(key, filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	return s.blobs.Put(key, f, info.Size())
+}
+
 // =====================
 // PROCESS ANALYSIS
 // =====================
 func (s *AnalysisService) processAnalysis(
 	analysisID uint,
+	orgID uint,
 	filePath string,
+	sourcePath string,
 ) {
 
 	log := logger.Log.With().
@@ -82,26 +232,50 @@ func (s *AnalysisService) processAnalysis(
 
 	start := time.Now()
 
+	// оригинал уже в хранилище, локальная копия — только для разбора
+	defer removeUpload(filePath, sourcePath)
+
 	// ---------- PARSE SARIF ----------
 	parsed, err := s.parser.Parse(filePath)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to parse sarif")
-		_ = s.analysisRepo.UpdateStatus(analysisID, "failed")
+		s.markFailed(analysisID)
 		return
 	}
 
+	// ---------- SOURCE CONTEXT ----------
+	if sourcePath != "" {
+		s.attachContext(parsed, sourcePath)
+	}
+
 	findings := make([]models.Finding, len(parsed))
 	for i := range parsed {
 		parsed[i].AnalysisID = analysisID
+		// контекст нужен pipeline и UI, но сам секрет в нём не храним
+		parsed[i].Context = mask.RedactContext(parsed[i].Context, parsed[i].Value)
 		findings[i] = parsed[i]
+		findings[i].ValueMasked = mask.Mask(parsed[i].Value)
+
+		sealed, err := s.sealValue(parsed[i].Value)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to encrypt finding value")
+			s.markFailed(analysisID)
+			return
+		}
+		findings[i].Value = sealed
 	}
 
 	if err := s.findingRepo.BulkInsert(findings); err != nil {
 		log.Error().Err(err).Msg("failed to insert findings")
-		_ = s.analysisRepo.UpdateStatus(analysisID, "failed")
+		s.markFailed(analysisID)
 		return
 	}
 
+	// в базе шифртекст, а pipeline нужен исходный секрет
+	for i := range findings {
+		findings[i].Value = parsed[i].Value
+	}
+
 	log.Debug().
 		Int("findings_count", len(findings)).
 		Msg("findings inserted")
@@ -112,9 +286,9 @@ func (s *AnalysisService) processAnalysis(
 		ptrs[i] = &findings[i]
 	}
 
-	if err := s.pipeline.Process(ptrs); err != nil {
+	if err := s.pipeline.Process(orgID, ptrs); err != nil {
 		log.Error().Err(err).Msg("pipeline failed")
-		_ = s.analysisRepo.UpdateStatus(analysisID, "failed")
+		s.markFailed(analysisID)
 		return
 	}
 
@@ -125,6 +299,9 @@ func (s *AnalysisService) processAnalysis(
 	for _, f := range ptrs {
 		f.Status = "processed"
 
+		// LLM любит цитировать найденный секрет в объяснении
+		redactFinding(f)
+
 		if f.FinalVerdict != nil {
 			switch *f.FinalVerdict {
 			case "TP":
@@ -147,6 +324,9 @@ func (s *AnalysisService) processAnalysis(
 			"final_verdict":       f.FinalVerdict,
 			"status":              f.Status,
 			"decision_source":     f.DecisionSource,
+			"suppression_rule_id": f.SuppressionRuleID,
+			"suppression_comment": f.SuppressionComment,
+			"format_validation":   f.FormatValidation,
 		})
 	}
 
@@ -161,27 +341,213 @@ func (s *AnalysisService) processAnalysis(
 		Msg("analysis completed")
 }
 
-func (s *AnalysisService) ListByUser(userID uint) ([]models.Analysis, error) {
-	return s.analysisRepo.ListByUser(userID)
+// attachContext fills the context of findings the scanner left without
+// one. Context is best-effort: a broken archive never fails the analysis.
+func (s *AnalysisService) attachContext(findings []models.Finding, sourcePath string) {
+	wants := make([]source.Want, 0, len(findings))
+	idx := make([]int, 0, len(findings))
+
+	for i, f := range findings {
+		if f.Context != "" {
+			continue
+		}
+		lineEnd := 0
+		if f.LineEnd != nil {
+			lineEnd = *f.LineEnd
+		}
+		wants = append(wants, source.Want{Path: f.FilePath, Line: f.Line, LineEnd: lineEnd})
+		idx = append(idx, i)
+	}
+	if len(wants) == 0 {
+		return
+	}
+
+	snippets, err := source.Extract(sourcePath, wants, s.context.Lines, s.context.Limits)
+	if err != nil {
+		logger.Log.Warn().
+			Str("service", "analysis").
+			Str("method", "attachContext").
+			Err(err).
+			Msg("cannot read source archive, findings keep no context")
+		return
+	}
+
+	found := 0
+	for j, sn := range snippets {
+		if sn.Text == "" {
+			continue
+		}
+		findings[idx[j]].Context = sn.Text
+		findings[idx[j]].ContextStartLine = sn.StartLine
+		found++
+	}
+
+	logger.Log.Debug().
+		Str("service", "analysis").
+		Str("method", "attachContext").
+		Int("wanted", len(wants)).
+		Int("found", found).
+		Msg("source context attached")
+}
+
+// List returns one page of the organization's analyses together with
+// a summary over the whole filtered set.
+func (s *AnalysisService) List(
+	actor rbac.Actor,
+	filter repository.AnalysisFilter,
+) ([]models.Analysis, *repository.AnalysisSummary, error) {
+
+	filter.OrganizationID = actor.OrgID
+	filter.Repositories = actor.Projects
+
+	analyses, err := s.analysisRepo.List(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	summary, err := s.analysisRepo.Summarize(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return analyses, summary, nil
 }
 
-func (s *AnalysisService) GetByID(id uint) (*models.Analysis, error) {
-	return s.analysisRepo.GetByID(id)
+func (s *AnalysisService) GetByID(actor rbac.Actor, id uint) (*models.Analysis, error) {
+	return s.authorize(actor, id)
 }
 
 func (s *AnalysisService) GetDetails(
+	actor rbac.Actor,
 	id uint,
 ) (*models.Analysis, []models.Finding, error) {
 
-	analysis, err := s.analysisRepo.GetByID(id)
-	if err != nil || analysis == nil {
+	analysis, err := s.authorize(actor, id)
+	if err != nil {
 		return nil, nil, err
 	}
 
-	findings, err := s.findingRepo.ListByAnalysis(id)
+	findings, err := s.findingRepo.ListByAnalysis(analysis.ID)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	// findings до появления маскирования: превью считаем на лету
+	for i := range findings {
+		if findings[i].ValueMasked != "" {
+			continue
+		}
+		if v, err := s.openValue(findings[i].Value); err == nil {
+			findings[i].ValueMasked = mask.Mask(v)
+		}
+	}
+
 	return analysis, findings, nil
 }
+
+// AuditSecretRevealed is recorded for every full disclosure of a value
+const AuditSecretRevealed = "finding.value_revealed"
+
+// RevealValue decrypts the secret of a single finding. This is the only
+// place values are decrypted for callers, and every call is audited.
+func (s *AnalysisService) RevealValue(
+	actor rbac.Actor,
+	analysisID uint,
+	findingID uint,
+	ip string,
+) (string, error) {
+
+	analysis, err := s.authorize(actor, analysisID)
+	if err != nil {
+		return "", err
+	}
+
+	finding, err := s.findingRepo.GetByID(findingID)
+	if err != nil {
+		return "", err
+	}
+	if finding == nil || finding.AnalysisID != analysis.ID {
+		return "", ErrFindingNotFound
+	}
+
+	value, err := s.openValue(finding.Value)
+	if err != nil {
+		return "", err
+	}
+
+	s.audit.Record(&models.AuditLog{
+		Event:          AuditSecretRevealed,
+		UserID:         &actor.UserID,
+		OrganizationID: &actor.OrgID,
+		IP:             ip,
+		Subject:        finding.ValueMasked,
+		Details: map[string]interface{}{
+			"analysis_id": analysis.ID,
+			"finding_id":  finding.ID,
+			"rule_id":     finding.RuleID,
+		},
+	})
+
+	return value, nil
+}
+
+// redactFinding masks the finding's own secret in free-text fields
+func redactFinding(f *models.Finding) {
+	for _, field := range []*string{f.LlmExplanation, f.HeuristicReason} {
+		if field != nil {
+			*field = mask.Redact(*field, f.Value)
+		}
+	}
+}
+
+// =====================
+// VALUE ENCRYPTION
+// =====================
+
+func (s *AnalysisService) sealValue(v string) (string, error) {
+	if s.secrets == nil {
+		return v, nil
+	}
+	return s.secrets.Seal(v)
+}
+
+func (s *AnalysisService) openValue(v string) (string, error) {
+	if s.secrets == nil {
+		if envelope.IsSealed(v) {
+			return "", envelope.ErrUnknownKey
+		}
+		return v, nil
+	}
+	return s.secrets.Open(v)
+}
+
+// =====================
+// AUTHORIZATION
+// =====================
+
+// authorize loads the analysis and checks that it belongs to the actor's
+// organization. Every read or write of an analysis (and its findings) on
+// behalf of a caller must go through here.
+func (s *AnalysisService) authorize(actor rbac.Actor, id uint) (*models.Analysis, error) {
+	analysis, err := s.analysisRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if analysis == nil ||
+		analysis.OrganizationID != actor.OrgID ||
+		!actor.CanAccessProject(analysis.Repository) {
+		logger.Log.Info().
+			Str("service", "analysis").
+			Str("method", "authorize").
+			Uint("user_id", actor.UserID).
+			Uint("org_id", actor.OrgID).
+			Uint("analysis_id", id).
+			Bool("exists", analysis != nil).
+			Msg("analysis access denied")
+
+		return nil, ErrAnalysisNotFound
+	}
+
+	return analysis, nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mws-ai/internal/auth/rbac"
+	"mws-ai/internal/models"
+)
+
+func newTestAnalysisService() *AnalysisService {
+	analyses := &fakeAnalysisRepo{analyses: map[uint]*models.Analysis{
+		1: {ID: 1, OrganizationID: 10, UserID: 100, Repository: "acme/api"},
+		2: {ID: 2, OrganizationID: 20, UserID: 200, Repository: "other/app"},
+	}}
+	findings := &fakeFindingRepo{findings: map[uint]*models.Finding{
+		11: {ID: 11, AnalysisID: 1, Value: "ghp_own", ValueMasked: "ghp_…"},
+		21: {ID: 21, AnalysisID: 2, Value: "ghp_foreign", ValueMasked: "ghp_…"},
+	}}
+
+	return NewAnalysisService(analyses, findings, nil, nil, nil, nil, nil, SourceContext{})
+}
+
+func TestAnalysisAuthorize(t *testing.T) {
+	s := newTestAnalysisService()
+	owner := rbac.Actor{UserID: 100, OrgID: 10, Role: rbac.RoleViewer}
+
+	tests := []struct {
+		name    string
+		actor   rbac.Actor
+		id      uint
+		wantErr error
+	}{
+		{"own analysis", owner, 1, nil},
+		{"another organization's analysis", owner, 2, ErrAnalysisNotFound},
+		{"missing analysis", owner, 404, ErrAnalysisNotFound},
+		{
+			"same organization, other project",
+			rbac.Actor{UserID: 100, OrgID: 10, Role: rbac.RoleViewer, Projects: []string{"acme/web"}},
+			1,
+			ErrAnalysisNotFound,
+		},
+		{
+			"another member of the organization",
+			rbac.Actor{UserID: 101, OrgID: 10, Role: rbac.RoleReviewer},
+			1,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := s.GetByID(tt.actor, tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByID err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (a == nil || a.ID != tt.id) {
+				t.Fatalf("GetByID = %+v, want analysis %d", a, tt.id)
+			}
+			if tt.wantErr != nil && a != nil {
+				t.Fatalf("GetByID leaked %+v", a)
+			}
+		})
+	}
+}
+
+func TestAnalysisGetDetailsScopesFindings(t *testing.T) {
+	s := newTestAnalysisService()
+	owner := rbac.Actor{UserID: 100, OrgID: 10, Role: rbac.RoleViewer}
+
+	_, findings, err := s.GetDetails(owner, 1)
+	if err != nil {
+		t.Fatalf("GetDetails: %v", err)
+	}
+	if len(findings) != 1 || findings[0].ID != 11 {
+		t.Fatalf("GetDetails findings = %+v, want only finding 11", findings)
+	}
+
+	if _, _, err := s.GetDetails(owner, 2); !errors.Is(err, ErrAnalysisNotFound) {
+		t.Fatalf("GetDetails foreign err = %v, want ErrAnalysisNotFound", err)
+	}
+}
+
+func TestAnalysisRevealValueScopesFindings(t *testing.T) {
+	s := newTestAnalysisService()
+	owner := rbac.Actor{UserID: 100, OrgID: 10, Role: rbac.RoleReviewer}
+
+	// finding другого анализа через свой analysis_id
+	if _, err := s.RevealValue(owner, 1, 21, "127.0.0.1"); !errors.Is(err, ErrFindingNotFound) {
+		t.Fatalf("RevealValue cross-analysis err = %v, want ErrFindingNotFound", err)
+	}
+	if _, err := s.RevealValue(owner, 2, 21, "127.0.0.1"); !errors.Is(err, ErrAnalysisNotFound) {
+		t.Fatalf("RevealValue foreign err = %v, want ErrAnalysisNotFound", err)
+	}
+}
+
+type fakeParser struct {
+	SarifParser
+	invalid error
+}
+
+func (p fakeParser) Validate(string) error { return p.invalid }
+
+// TestUploadRemovesFilesOnError: temporary files never outlive a failed
+// upload, and an analysis that was created doesn't stay "processing"
+func TestUploadRemovesFilesOnError(t *testing.T) {
+	storageDown := errors.New("storage unavailable")
+	badReport := errors.New("invalid sarif")
+
+	tests := []struct {
+		name       string
+		actor      rbac.Actor
+		parser     fakeParser
+		blobs      *fakeBlobs
+		setKeyErr  error
+		wantErr    error
+		wantFailed bool
+	}{
+		{"project not allowed", rbac.Actor{OrgID: 10, Projects: []string{"acme/web"}}, fakeParser{}, &fakeBlobs{}, nil, ErrProjectNotAllowed, false},
+		{"invalid report", rbac.Actor{OrgID: 10}, fakeParser{invalid: badReport}, &fakeBlobs{}, nil, badReport, false},
+		{"storage error", rbac.Actor{OrgID: 10}, fakeParser{}, &fakeBlobs{putErr: storageDown}, nil, storageDown, true},
+		{"storage key not saved", rbac.Actor{OrgID: 10}, fakeParser{}, &fakeBlobs{}, storageDown, storageDown, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			report := filepath.Join(dir, "upload.sarif")
+			source := filepath.Join(dir, "source.zip")
+			for _, p := range []string{report, source} {
+				if err := os.WriteFile(p, []byte("{}"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			analyses := &fakeAnalysisRepo{analyses: map[uint]*models.Analysis{}, setKeyErr: tt.setKeyErr}
+			s := NewAnalysisService(analyses, nil, tt.parser, nil, tt.blobs, nil, nil, SourceContext{})
+
+			_, err := s.Upload(tt.actor, report, UploadMeta{Repository: "acme/api", SourcePath: source})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Upload err = %v, want %v", err, tt.wantErr)
+			}
+
+			for _, p := range []string{report, source} {
+				if _, err := os.Stat(p); !os.IsNotExist(err) {
+					t.Errorf("%s left behind", filepath.Base(p))
+				}
+			}
+
+			if created := len(analyses.analyses) > 0; created != tt.wantFailed {
+				t.Fatalf("analysis created = %v, want %v", created, tt.wantFailed)
+			}
+			for _, a := range analyses.analyses {
+				if a.Status != "failed" {
+					t.Fatalf("analysis status = %q, want failed", a.Status)
+				}
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/uuid"
 
+	"mws-ai/internal/auth/rbac"
 	"mws-ai/internal/models"
 	"mws-ai/internal/repository"
 	"mws-ai/pkg/logger"
@@ -18,6 +19,21 @@ var (
 	ErrExpiredAPIKey  = errors.New("api key expired")
 	ErrInactiveAPIKey = errors.New("api key inactive")
 	ErrEmptyAPIKey    = errors.New("empty api key")
+	ErrInvalidKeyType = errors.New("invalid api key type")
+	ErrInvalidScope   = errors.New("invalid api key scope")
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyRevoked  = errors.New("api key already revoked")
+	ErrAPIKeyRotated  = errors.New("api key already rotated")
+)
+
+const (
+	apiKeyRawPrefix = "mws_sk_"
+	// how many chars of the raw key are stored to identify it in listings
+	apiKeyDisplayLen = len(apiKeyRawPrefix) + 8
+
+	// DefaultRotationGrace is how long the old key keeps working after rotation
+	DefaultRotationGrace = 24 * time.Hour
+	MaxRotationGrace     = 30 * 24 * time.Hour
 )
 
 type APIKeyService struct {
@@ -28,70 +44,261 @@ func NewAPIKeyService(repo repository.APIKeyRepository) *APIKeyService {
 	return &APIKeyService{repo: repo}
 }
 
-// Generate creates a new API key (returned ONCE)
+// keyTypeRoles caps what a key can do regardless of its owner's role
+var keyTypeRoles = map[string]rbac.Role{
+	"admin":       rbac.RoleAdmin,
+	"service":     rbac.RoleReviewer,
+	"integration": rbac.RoleReviewer,
+}
+
+// KeyRole returns the maximum role a key of the given type may act with
+func KeyRole(keyType string) rbac.Role {
+	if role, ok := keyTypeRoles[keyType]; ok {
+		return role
+	}
+	return rbac.RoleViewer
+}
+
+// KeyScopes returns the scopes stored on the key, nil if unrestricted
+func KeyScopes(key *models.ApiKey) []rbac.Permission {
+	if len(key.Scopes) == 0 {
+		return nil
+	}
+	scopes := make([]rbac.Permission, 0, len(key.Scopes))
+	for _, s := range key.Scopes {
+		scopes = append(scopes, rbac.Permission(s))
+	}
+	return scopes
+}
+
+// KeyProjects returns the repositories the key is limited to, nil if any
+func KeyProjects(key *models.ApiKey) []string {
+	if len(key.Projects) == 0 {
+		return nil
+	}
+	return key.Projects
+}
+
+// APIKeyOptions describes a key to be generated
+type APIKeyOptions struct {
+	Name     string
+	Type     string
+	TTL      time.Duration
+	Scopes   []rbac.Permission // empty = everything the type allows
+	Projects []string          // empty = all repositories
+}
+
+// Generate creates a new API key bound to the actor's organization
+// (returned ONCE)
 func (s *APIKeyService) Generate(
-	userID uint,
-	keyType string,
-	ttl time.Duration,
-) (string, error) {
+	actor rbac.Actor,
+	opts APIKeyOptions,
+) (string, *models.ApiKey, error) {
 
 	log := logger.Log.With().
 		Str("service", "api_key").
 		Str("method", "Generate").
-		Uint("user_id", userID).
-		Str("type", keyType).
+		Uint("user_id", actor.UserID).
+		Uint("org_id", actor.OrgID).
+		Str("type", opts.Type).
 		Logger()
 
-	rawKey := "mws_sk_" + uuid.New().String()
+	if _, ok := keyTypeRoles[opts.Type]; !ok {
+		return "", nil, ErrInvalidKeyType
+	}
 
-	hash := sha256.Sum256([]byte(rawKey))
-	hashStr := hex.EncodeToString(hash[:])
+	scopes := make([]string, 0, len(opts.Scopes))
+	for _, sc := range opts.Scopes {
+		if !rbac.ValidKeyScope(sc) {
+			return "", nil, ErrInvalidScope
+		}
+		scopes = append(scopes, string(sc))
+	}
 
-	expiresAt := time.Now().Add(ttl)
+	expiresAt := time.Now().Add(opts.TTL)
 
 	apiKey := &models.ApiKey{
-		UserID:    userID,
-		Hash:      hashStr,
-		Type:      keyType,
-		Active:    true,
-		CreatedAt: time.Now(),
-		ExpiresAt: &expiresAt,
+		UserID:         actor.UserID,
+		OrganizationID: actor.OrgID,
+		Name:           opts.Name,
+		Type:           opts.Type,
+		Scopes:         scopes,
+		Projects:       opts.Projects,
+		ExpiresAt:      &expiresAt,
 	}
 
-	if err := s.repo.Create(apiKey); err != nil {
+	rawKey, err := s.issue(apiKey)
+	if err != nil {
 		log.Error().Err(err).Msg("failed to store API key")
-		return "", err
+		return "", nil, err
 	}
 
 	log.Info().
 		Uint("api_key_id", apiKey.ID).
+		Strs("scopes", scopes).
+		Strs("projects", opts.Projects).
 		Msg("API key generated")
 
+	return rawKey, apiKey, nil
+}
+
+// issue generates the secret for a prepared key and stores it
+func (s *APIKeyService) issue(apiKey *models.ApiKey) (string, error) {
+	rawKey := apiKeyRawPrefix + uuid.New().String()
+
+	apiKey.Hash = hashAPIKey(rawKey)
+	apiKey.Prefix = rawKey[:apiKeyDisplayLen]
+	apiKey.Active = true
+	apiKey.CreatedAt = time.Now()
+
+	if err := s.repo.Create(apiKey); err != nil {
+		return "", err
+	}
+
 	return rawKey, nil
 }
 
-// Validate checks API key and returns user_id
-func (s *APIKeyService) Validate(rawKey string) (uint, error) {
+func hashAPIKey(rawKey string) string {
+	hash := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(hash[:])
+}
+
+// =====================
+// LIFECYCLE
+// =====================
+
+// List returns all keys of the actor's organization (without secrets)
+func (s *APIKeyService) List(actor rbac.Actor) ([]models.ApiKey, error) {
+	return s.repo.ListByOrganization(actor.OrgID)
+}
+
+// Revoke disables the key immediately
+func (s *APIKeyService) Revoke(actor rbac.Actor, id uint) error {
+	key, err := s.findOwned(actor, id)
+	if err != nil {
+		return err
+	}
+
+	if key.RevokedAt != nil {
+		return ErrAPIKeyRevoked
+	}
+
+	if err := s.repo.Revoke(key.ID, time.Now()); err != nil {
+		return err
+	}
+
+	logger.Log.Info().
+		Str("service", "api_key").
+		Str("method", "Revoke").
+		Uint("api_key_id", key.ID).
+		Uint("actor_id", actor.UserID).
+		Msg("API key revoked")
+
+	return nil
+}
+
+// Rotate issues a replacement with the same settings and lifetime; the old
+// key keeps working until the returned grace end
+func (s *APIKeyService) Rotate(
+	actor rbac.Actor,
+	id uint,
+	grace time.Duration,
+) (string, *models.ApiKey, time.Time, error) {
+
+	log := logger.Log.With().
+		Str("service", "api_key").
+		Str("method", "Rotate").
+		Uint("api_key_id", id).
+		Uint("actor_id", actor.UserID).
+		Logger()
+
+	old, err := s.findOwned(actor, id)
+	if err != nil {
+		return "", nil, time.Time{}, err
+	}
+
+	if old.RevokedAt != nil || !old.Active {
+		return "", nil, time.Time{}, ErrAPIKeyRevoked
+	}
+	if old.ReplacedByID != nil {
+		return "", nil, time.Time{}, ErrAPIKeyRotated
+	}
+
+	if grace < 0 {
+		grace = 0
+	}
+	if grace > MaxRotationGrace {
+		grace = MaxRotationGrace
+	}
+
+	now := time.Now()
+
+	replacement := &models.ApiKey{
+		UserID:         old.UserID,
+		OrganizationID: old.OrganizationID,
+		Name:           old.Name,
+		Type:           old.Type,
+		Scopes:         old.Scopes,
+		Projects:       old.Projects,
+	}
+	if old.ExpiresAt != nil {
+		expiresAt := now.Add(old.ExpiresAt.Sub(old.CreatedAt))
+		replacement.ExpiresAt = &expiresAt
+	}
+
+	rawKey, err := s.issue(replacement)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to store replacement key")
+		return "", nil, time.Time{}, err
+	}
+
+	graceEnd := now.Add(grace)
+	if old.ExpiresAt != nil && old.ExpiresAt.Before(graceEnd) {
+		graceEnd = *old.ExpiresAt
+	}
+
+	if err := s.repo.MarkRotated(old.ID, replacement.ID, graceEnd); err != nil {
+		return "", nil, time.Time{}, err
+	}
+
+	log.Info().
+		Uint("replacement_id", replacement.ID).
+		Time("old_expires_at", graceEnd).
+		Msg("API key rotated")
+
+	return rawKey, replacement, graceEnd, nil
+}
+
+func (s *APIKeyService) findOwned(actor rbac.Actor, id uint) (*models.ApiKey, error) {
+	key, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || key.OrganizationID != actor.OrgID {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// Validate checks API key and returns the stored key
+func (s *APIKeyService) Validate(rawKey string) (*models.ApiKey, error) {
 	log := logger.Log.With().
 		Str("service", "api_key").
 		Str("method", "Validate").
 		Logger()
 
 	if rawKey == "" {
-		return 0, ErrEmptyAPIKey
+		return nil, ErrEmptyAPIKey
 	}
 
-	hash := sha256.Sum256([]byte(rawKey))
-	hashStr := hex.EncodeToString(hash[:])
-
-	key, err := s.repo.FindActiveByHash(hashStr)
+	key, err := s.repo.FindActiveByHash(hashAPIKey(rawKey))
 	if err != nil {
 		log.Error().Err(err).Msg("api key lookup failed")
-		return 0, ErrInvalidAPIKey
+		return nil, ErrInvalidAPIKey
 	}
 
 	if key == nil {
-		return 0, ErrInvalidAPIKey
+		return nil, ErrInvalidAPIKey
 	}
 
 	now := time.Now()
@@ -103,5 +310,5 @@ func (s *APIKeyService) Validate(rawKey string) (uint, error) {
 		Str("type", key.Type).
 		Msg("API key validated")
 
-	return key.UserID, nil
+	return key, nil
 }
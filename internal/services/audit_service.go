@@ -0,0 +1,43 @@
+package services
+
+import (
+	"mws-ai/internal/models"
+	"mws-ai/internal/repository"
+	"mws-ai/pkg/logger"
+)
+
+// Audit events
+const (
+	AuditAccountLocked = "auth.account_locked"
+	AuditIPLocked      = "auth.ip_locked"
+)
+
+type AuditService struct {
+	repo repository.AuditRepository
+}
+
+func NewAuditService(repo repository.AuditRepository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// Record stores the entry and mirrors it to the application log.
+// A failed write is logged but never fails the calling request.
+func (s *AuditService) Record(entry *models.AuditLog) {
+	ev := logger.Log.Info().
+		Str("component", "audit").
+		Str("event", entry.Event).
+		Str("subject", entry.Subject).
+		Str("ip", entry.IP)
+
+	if entry.UserID != nil {
+		ev = ev.Uint("user_id", *entry.UserID)
+	}
+	if entry.OrganizationID != nil {
+		ev = ev.Uint("org_id", *entry.OrganizationID)
+	}
+
+	ev.Fields(entry.Details).Msg("audit event")
+
+	// ошибка уже залогирована в репозитории
+	_ = s.repo.Create(entry)
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"mws-ai/internal/models"
+	"mws-ai/pkg/jwt"
+	"mws-ai/pkg/logger"
+)
+
+var ErrInvalidEmailToken = errors.New("invalid or expired token")
+
+const AuditPasswordReset = "auth.password_reset"
+
+// EmailSettings configures the verification and password reset emails
+type EmailSettings struct {
+	// AppURL is the public base URL used in links
+	AppURL string
+
+	VerifyTTL time.Duration
+	ResetTTL  time.Duration
+
+	// RequireVerification blocks password login until the email is verified
+	RequireVerification bool
+}
+
+// =====================
+// EMAIL VERIFICATION
+// =====================
+
+// VerifyEmail marks the token's user as verified. Repeating it is harmless.
+func (s *AuthService) VerifyEmail(token string) error {
+	user, err := s.userFromActionToken(token, jwt.TokenTypeEmailVerify)
+	if err != nil {
+		return err
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+
+	if err := s.users.Update(user); err != nil {
+		return err
+	}
+
+	logger.Log.Info().
+		Str("service", "auth").
+		Str("method", "VerifyEmail").
+		Uint("user_id", user.ID).
+		Msg("email verified")
+
+	return nil
+}
+
+// ResendVerification sends a new link if the account exists and is not
+// verified yet. The outcome is not reported, so emails can't be probed.
+func (s *AuthService) ResendVerification(email string) error {
+	user, err := s.users.FindByEmail(email)
+	if err != nil {
+		return err
+	}
+
+	if user != nil && user.EmailVerifiedAt == nil {
+		s.sendVerification(user)
+	}
+
+	return nil
+}
+
+func (s *AuthService) sendVerification(user *models.User) {
+	token, err := s.jwt.GenerateActionToken(user.ID, user.TokenVersion, jwt.TokenTypeEmailVerify, s.email.VerifyTTL)
+	if err != nil {
+		logger.Log.Error().
+			Str("service", "auth").
+			Str("method", "sendVerification").
+			Uint("user_id", user.ID).
+			Err(err).
+			Msg("failed to issue verification token")
+		return
+	}
+
+	link := s.link("/api/auth/verify-email", token)
+
+	s.send(Mail{
+		To:      user.Email,
+		Subject: "Подтвердите email",
+		Body: fmt.Sprintf(
+			"Чтобы подтвердить адрес %s, откройте ссылку:\n\n%s\n\nСсылка действует %s.\n",
+			user.Email, link, s.email.VerifyTTL,
+		),
+	})
+}
+
+// =====================
+// PASSWORD RESET
+// =====================
+
+// RequestPasswordReset emails a reset link if the account exists.
+// SSO-only accounts have no password to reset.
+func (s *AuthService) RequestPasswordReset(email string) error {
+	user, err := s.users.FindByEmail(email)
+	if err != nil {
+		return err
+	}
+
+	if user == nil || user.PasswordHash == "" {
+		logger.Log.Info().
+			Str("service", "auth").
+			Str("method", "RequestPasswordReset").
+			Str("email", email).
+			Msg("reset requested for unknown or SSO-only account")
+		return nil
+	}
+
+	token, err := s.jwt.GenerateActionToken(user.ID, user.TokenVersion, jwt.TokenTypePasswordReset, s.email.ResetTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.link("/reset-password", token)
+
+	s.send(Mail{
+		To:      user.Email,
+		Subject: "Сброс пароля",
+		Body: fmt.Sprintf(
+			"Для сброса пароля откройте ссылку:\n\n%s\n\nСсылка действует %s. "+
+				"Если вы не запрашивали сброс, просто проигнорируйте письмо.\n",
+			link, s.email.ResetTTL,
+		),
+	})
+
+	return nil
+}
+
+// ResetPassword sets a new password. It bumps the token version, which
+// logs out every session and makes the reset token itself single-use.
+func (s *AuthService) ResetPassword(token, password, ip string) error {
+	user, err := s.userFromActionToken(token, jwt.TokenTypePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	if err := s.passwords.Validate(password, user.Email); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	user.PasswordHash = string(hash)
+	// ссылка пришла на этот адрес — значит, он подтверждён
+	if user.EmailVerifiedAt == nil {
+		user.EmailVerifiedAt = &now
+	}
+
+	if err := s.users.Update(user); err != nil {
+		return err
+	}
+	if err := s.users.IncrementTokenVersion(user.ID); err != nil {
+		return err
+	}
+	if err := s.refreshTokens.RevokeAllForUser(user.ID, now); err != nil {
+		return err
+	}
+	if err := s.guard.Succeed(user.Email); err != nil {
+		return err
+	}
+
+	s.audit.Record(&models.AuditLog{
+		Event:   AuditPasswordReset,
+		UserID:  &user.ID,
+		IP:      ip,
+		Subject: user.Email,
+	})
+
+	return nil
+}
+
+// userFromActionToken checks the token type, signature, expiry and that
+// the user's token version hasn't moved on since it was issued
+func (s *AuthService) userFromActionToken(token, tokenType string) (*models.User, error) {
+	claims, err := s.jwt.ParseAction(token, tokenType)
+	if err != nil {
+		return nil, ErrInvalidEmailToken
+	}
+
+	userID, ok := jwt.UserID(claims)
+	if !ok {
+		return nil, ErrInvalidEmailToken
+	}
+
+	user, err := s.users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || jwt.Version(claims) != user.TokenVersion {
+		return nil, ErrInvalidEmailToken
+	}
+
+	return user, nil
+}
+
+func (s *AuthService) link(path, token string) string {
+	return strings.TrimSuffix(s.email.AppURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// send delivers in the background: SMTP latency must not slow down the
+// request or reveal whether the account exists
+func (s *AuthService) send(m Mail) {
+	go func() {
+		if err := s.mailer.Send(m); err != nil {
+			logger.Log.Error().
+				Str("service", "auth").
+				Str("method", "send").
+				Str("to", m.To).
+				Str("subject", m.Subject).
+				Err(err).
+				Msg("failed to send email")
+		}
+	}()
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"errors"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
@@ -14,16 +15,43 @@ import (
 
 var ErrInvalidCredentials = errors.New("invalid email or password")
 var ErrUserAlreadyExists = errors.New("user already exists")
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+var ErrEmailNotVerified = errors.New("email is not verified")
 
 type AuthService struct {
-	users repository.UserRepository
-	jwt   *jwt.JWTManager
+	users         repository.UserRepository
+	refreshTokens repository.RefreshTokenRepository
+	orgs          *OrganizationService
+	jwt           *jwt.JWTManager
+	passwords     PasswordPolicy
+	guard         *LoginGuard
+	mailer        Mailer
+	email         EmailSettings
+	audit         *AuditService
 }
 
-func NewAuthService(users repository.UserRepository, jwt *jwt.JWTManager) *AuthService {
+func NewAuthService(
+	users repository.UserRepository,
+	refreshTokens repository.RefreshTokenRepository,
+	orgs *OrganizationService,
+	jwt *jwt.JWTManager,
+	passwords PasswordPolicy,
+	guard *LoginGuard,
+	mailer Mailer,
+	email EmailSettings,
+	audit *AuditService,
+) *AuthService {
 	return &AuthService{
-		users: users,
-		jwt:   jwt,
+		users:         users,
+		refreshTokens: refreshTokens,
+		orgs:          orgs,
+		jwt:           jwt,
+		passwords:     passwords,
+		guard:         guard,
+		mailer:        mailer,
+		email:         email,
+		audit:         audit,
 	}
 }
 
@@ -36,6 +64,14 @@ func (s *AuthService) Register(req dto.RegisterRequest) (*models.User, error) {
 
 	log.Debug().Msg("registration started")
 
+	if err := s.passwords.Validate(req.Password, req.Email); err != nil {
+		log.Info().
+			Err(err).
+			Msg("registration rejected: weak password")
+
+		return nil, err
+	}
+
 	existing, err := s.users.FindByEmail(req.Email)
 	if err != nil {
 		log.Error().
@@ -69,7 +105,8 @@ func (s *AuthService) Register(req dto.RegisterRequest) (*models.User, error) {
 		PasswordHash: string(hash),
 	}
 
-	if err := s.users.Create(user); err != nil {
+	// каждый пользователь получает личную организацию, где он admin
+	if _, err := s.orgs.CreateWithUser(user); err != nil {
 		log.Error().
 			Err(err).
 			Msg("failed to create user")
@@ -77,6 +114,8 @@ func (s *AuthService) Register(req dto.RegisterRequest) (*models.User, error) {
 		return nil, err
 	}
 
+	s.sendVerification(user)
+
 	log.Info().
 		Uint("user_id", user.ID).
 		Msg("user registered successfully")
@@ -84,15 +123,27 @@ func (s *AuthService) Register(req dto.RegisterRequest) (*models.User, error) {
 	return user, nil
 }
 
-func (s *AuthService) Login(req dto.LoginRequest) (*dto.AuthResponse, error) {
+// Login checks the password; ip is the client address used for per-IP
+// lockout. Locked accounts and IPs get a *LockoutError before the
+// password is even looked at.
+func (s *AuthService) Login(req dto.LoginRequest, ip string) (*dto.AuthResponse, error) {
 	log := logger.Log.With().
 		Str("service", "auth").
 		Str("method", "Login").
 		Str("email", req.Email).
+		Str("ip", ip).
 		Logger()
 
 	log.Debug().Msg("login attempt")
 
+	if err := s.guard.Check(req.Email, ip); err != nil {
+		log.Info().
+			Err(err).
+			Msg("login blocked")
+
+		return nil, err
+	}
+
 	user, err := s.users.FindByEmail(req.Email)
 	if err != nil {
 		log.Error().
@@ -106,7 +157,7 @@ func (s *AuthService) Login(req dto.LoginRequest) (*dto.AuthResponse, error) {
 		log.Info().
 			Msg("login failed: user not found")
 
-		return nil, ErrInvalidCredentials
+		return nil, s.loginFailed(req.Email, ip, nil)
 	}
 
 	if err := bcrypt.CompareHashAndPassword(
@@ -118,25 +169,23 @@ func (s *AuthService) Login(req dto.LoginRequest) (*dto.AuthResponse, error) {
 			Uint("user_id", user.ID).
 			Msg("login failed: invalid password")
 
-		return nil, ErrInvalidCredentials
+		return nil, s.loginFailed(req.Email, ip, &user.ID)
 	}
 
-	access, err := s.jwt.GenerateAccessToken(user.ID)
-	if err != nil {
-		log.Error().
+	if s.email.RequireVerification && user.EmailVerifiedAt == nil {
+		log.Info().
 			Uint("user_id", user.ID).
-			Err(err).
-			Msg("failed to generate access token")
+			Msg("login rejected: email not verified")
 
-		return nil, err
+		return nil, ErrEmailNotVerified
 	}
 
-	refresh, err := s.jwt.GenerateRefreshToken(user.ID)
+	resp, err := s.completeLogin(user, false)
 	if err != nil {
 		log.Error().
 			Uint("user_id", user.ID).
 			Err(err).
-			Msg("failed to generate refresh token")
+			Msg("failed to issue tokens")
 
 		return nil, err
 	}
@@ -145,8 +194,140 @@ func (s *AuthService) Login(req dto.LoginRequest) (*dto.AuthResponse, error) {
 		Uint("user_id", user.ID).
 		Msg("login successful")
 
+	return resp, nil
+}
+
+// completeLogin finishes a first-factor login. Users with 2FA get an MFA
+// challenge instead of tokens (unless the identity provider already did
+// MFA); the failed-attempt counter is only reset once no step is left,
+// so re-entering the password can't buy more guesses at the code.
+func (s *AuthService) completeLogin(user *models.User, mfaDone bool) (*dto.AuthResponse, error) {
+	if user.TOTPEnabledAt != nil && !mfaDone {
+		token, err := s.jwt.GenerateActionToken(user.ID, user.TokenVersion, jwt.TokenTypeMFAChallenge, MFAChallengeTTL)
+		if err != nil {
+			return nil, err
+		}
+
+		return &dto.AuthResponse{
+			MFARequired: true,
+			MFAToken:    token,
+		}, nil
+	}
+
+	if err := s.guard.Succeed(user.Email); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, "", mfaDone)
+}
+
+// loginFailed counts the failure and returns the error for the caller
+func (s *AuthService) loginFailed(email, ip string, userID *uint) error {
+	if err := s.guard.Fail(email, ip, userID); err != nil {
+		return err
+	}
+	return ErrInvalidCredentials
+}
+
+// Refresh exchanges a refresh token for a new token pair. Each refresh
+// token is single-use: presenting one twice means it leaked, so the
+// whole family (every token descended from that login) is revoked.
+func (s *AuthService) Refresh(refreshToken string) (*dto.AuthResponse, error) {
+	log := logger.Log.With().
+		Str("service", "auth").
+		Str("method", "Refresh").
+		Logger()
+
+	claims, err := s.jwt.ParseRefresh(refreshToken)
+	if err != nil {
+		log.Info().Err(err).Msg("refresh token rejected")
+		return nil, ErrInvalidRefreshToken
+	}
+
+	jti, _ := claims["jti"].(string)
+
+	stored, err := s.refreshTokens.FindByJTI(jti)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		log.Info().Msg("refresh token not found")
+		return nil, ErrInvalidRefreshToken
+	}
+
+	log = log.With().
+		Uint("user_id", stored.UserID).
+		Str("family_id", stored.FamilyID).
+		Logger()
+
+	if stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		log.Info().Msg("refresh token revoked or expired")
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.users.FindByID(stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || jwt.Version(claims) != user.TokenVersion {
+		log.Info().Msg("refresh token from a logged out session")
+		return nil, ErrInvalidRefreshToken
+	}
+
+	now := time.Now()
+
+	fresh := stored.UsedAt == nil
+	if fresh {
+		fresh, err = s.refreshTokens.MarkUsed(stored.ID, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !fresh {
+		log.Warn().Msg("refresh token reuse detected, revoking family")
+
+		if err := s.refreshTokens.RevokeFamily(stored.FamilyID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	resp, err := s.issueTokens(user, stored.FamilyID, jwt.MFA(claims))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to issue tokens")
+		return nil, err
+	}
+
+	log.Info().Msg("tokens refreshed")
+
+	return resp, nil
+}
+
+// issueTokens creates an access/refresh pair; familyID == "" starts a new
+// family. mfa marks sessions that passed a second factor.
+func (s *AuthService) issueTokens(user *models.User, familyID string, mfa bool) (*dto.AuthResponse, error) {
+	access, err := s.jwt.GenerateAccessToken(user.ID, user.TokenVersion, mfa)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := s.jwt.GenerateRefreshToken(user.ID, user.TokenVersion, familyID, mfa)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.refreshTokens.Create(&models.RefreshToken{
+		JTI:       refresh.JTI,
+		FamilyID:  refresh.FamilyID,
+		UserID:    user.ID,
+		ExpiresAt: refresh.ExpiresAt,
+	}); err != nil {
+		return nil, err
+	}
+
 	return &dto.AuthResponse{
 		AccessToken:  access,
-		RefreshToken: refresh,
+		RefreshToken: refresh.Token,
 	}, nil
 }
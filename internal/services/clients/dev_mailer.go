@@ -0,0 +1,77 @@
+package clients
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"mws-ai/internal/services"
+	"mws-ai/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// DEV MAILERS: nothing leaves the machine
+
+type logMailer struct {
+	// out не проходит через чистку логов: ссылки подтверждения и сброса
+	// пароля содержат JWT, который маскировщик иначе спрячет
+	out io.Writer
+}
+
+// NewLogMailer prints emails to stderr, next to the application log.
+// The body is written as is, so links in it stay usable.
+func NewLogMailer() services.Mailer {
+	return &logMailer{out: os.Stderr}
+}
+
+func (m *logMailer) Send(msg services.Mail) error {
+	_, err := fmt.Fprintf(m.out,
+		"----- email (log mailer) -----\nTo: %s\nSubject: %s\n\n%s\n------------------------------\n",
+		msg.To, msg.Subject, msg.Body,
+	)
+	if err != nil {
+		return fmt.Errorf("mail write error: %w", err)
+	}
+
+	logger.Log.Info().
+		Str("component", "mailer").
+		Str("to", msg.To).
+		Str("subject", msg.Subject).
+		Msg("email printed to stderr (log mailer)")
+
+	return nil
+}
+
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer stores every email as an .eml file in dir
+func NewFileMailer(dir, from string) services.Mailer {
+	return &fileMailer{dir: dir, from: from}
+}
+
+func (m *fileMailer) Send(msg services.Mail) error {
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("mail dir error: %w", err)
+	}
+
+	name := fmt.Sprintf("%s_%s.eml", time.Now().Format("20060102T150405"), uuid.New().String()[:8])
+	path := filepath.Join(m.dir, name)
+
+	if err := os.WriteFile(path, buildMessage(m.from, msg), 0o600); err != nil {
+		return fmt.Errorf("mail write error: %w", err)
+	}
+
+	logger.Log.Debug().
+		Str("component", "mailer").
+		Str("to", msg.To).
+		Str("file", path).
+		Msg("email stored")
+
+	return nil
+}
@@ -25,9 +25,11 @@ func NewHeuristicClient(baseURL string) services.HeuristicClient {
 
 // DTO
 type heuristicRequest struct {
-	ID       uint   `json:"id"`
-	FilePath string `json:"file_path"`
-	Value    string `json:"value"`
+	ID               uint   `json:"id"`
+	FilePath         string `json:"file_path"`
+	Value            string `json:"value"`
+	Context          string `json:"context,omitempty"`
+	ContextStartLine int    `json:"context_start_line,omitempty"`
 }
 
 type heuristicResponse struct {
@@ -55,9 +57,11 @@ func (h *heuristicHTTP) AnalyzeBatch(
 	req := make([]heuristicRequest, 0, len(findings))
 	for _, f := range findings {
 		req = append(req, heuristicRequest{
-			ID:       f.ID,
-			FilePath: f.FilePath,
-			Value:    f.Value,
+			ID:               f.ID,
+			FilePath:         f.FilePath,
+			Value:            f.Value,
+			Context:          f.Context,
+			ContextStartLine: f.ContextStartLine,
 		})
 	}
 
@@ -9,16 +9,28 @@ import (
 
 	"mws-ai/internal/models"
 	"mws-ai/internal/services"
+	"mws-ai/pkg/mask"
+)
+
+// LLM redaction modes
+const (
+	LLMModeRaw      = "raw"      // the secret itself is sent
+	LLMModeRedacted = "redacted" // only mask.Describe of the secret is sent
 )
 
 type llmHTTP struct {
 	url    string
+	mode   string
 	client *http.Client
 }
 
-func NewLLMClient(url string) services.LLMClient {
+// NewLLMClient sends findings to the LLM service. In LLMModeRedacted the
+// value never leaves the process: the model gets a structured description
+// instead, which is what a third-party hosted model should see.
+func NewLLMClient(url, mode string) services.LLMClient {
 	return &llmHTTP{
-		url: url,
+		url:  url,
+		mode: mode,
 		client: &http.Client{
 			Timeout: 5 * time.Minute,
 		},
@@ -29,11 +41,19 @@ type llmFinding struct {
 	ID       uint   `json:"id"`
 	FilePath string `json:"file_path"`
 	Line     int    `json:"line"`
-	Value    string `json:"value"`
 	RuleID   string `json:"rule_id"`
+
+	// raw
+	Value   string `json:"value,omitempty"`
+	Context string `json:"context,omitempty"`
+	// redacted: context is inside the description
+	Secret *mask.Description `json:"secret,omitempty"`
+
+	ContextStartLine int `json:"context_start_line,omitempty"`
 }
 
 type llmRequest struct {
+	Mode     string       `json:"mode"`
 	Findings []llmFinding `json:"findings"`
 }
 
@@ -55,17 +75,30 @@ func (c *llmHTTP) AnalyzeBatch(
 
 	// build request
 	req := llmRequest{
+		Mode:     c.mode,
 		Findings: make([]llmFinding, 0, len(findings)),
 	}
 
 	for _, f := range findings {
-		req.Findings = append(req.Findings, llmFinding{
+		item := llmFinding{
 			ID:       f.ID,
 			FilePath: f.FilePath,
 			Line:     f.Line,
-			Value:    f.Value,
 			RuleID:   f.RuleID,
-		})
+		}
+
+		if c.mode == LLMModeRaw {
+			item.Value = f.Value
+			item.Context = f.Context
+		} else {
+			d := mask.Describe(f.Value, f.Context)
+			item.Secret = &d
+		}
+		if f.Context != "" {
+			item.ContextStartLine = f.ContextStartLine
+		}
+
+		req.Findings = append(req.Findings, item)
 	}
 
 	payload, err := json.Marshal(req)
@@ -0,0 +1,311 @@
+package clients
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"mws-ai/internal/services"
+)
+
+type oidcHTTP struct {
+	cfg    services.OIDCConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]interface{}
+	keysAt    time.Time
+	// keysTriedAt is the last JWKS request, successful or not
+	keysTriedAt time.Time
+}
+
+func NewOIDCClient(cfg services.OIDCConfig) services.OIDCProvider {
+	return &oidcHTTP{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// DTO
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcTokenResponse struct {
+	IDToken     string `json:"id_token"`
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+type oidcJWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type oidcJWKS struct {
+	Keys []oidcJWK `json:"keys"`
+}
+
+const oidcKeysTTL = time.Hour
+
+// oidcKeysRefetch limits JWKS requests caused by unknown kids: anyone
+// can put an arbitrary kid into a token
+const oidcKeysRefetch = time.Minute
+
+// CLIENT
+
+func (o *oidcHTTP) AuthCodeURL(state, nonce, codeChallenge string) (string, error) {
+	d, err := o.discover()
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", o.cfg.ClientID)
+	q.Set("redirect_uri", o.cfg.RedirectURL)
+	q.Set("scope", strings.Join(o.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+func (o *oidcHTTP) Exchange(code, codeVerifier, nonce string) (map[string]interface{}, error) {
+	d, err := o.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", o.cfg.RedirectURL)
+	form.Set("client_id", o.cfg.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if o.cfg.ClientSecret != "" {
+		form.Set("client_secret", o.cfg.ClientSecret)
+	}
+
+	resp, err := o.client.PostForm(d.TokenEndpoint, form)
+	if err != nil {
+		return nil, fmt.Errorf("oidc token request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var tok oidcTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("oidc token decode error: %w", err)
+	}
+	if tok.IDToken == "" {
+		return nil, errors.New("oidc token response has no id_token")
+	}
+
+	return o.verifyIDToken(d, tok.IDToken, nonce)
+}
+
+// verifyIDToken checks signature, issuer, audience, expiry and nonce
+func (o *oidcHTTP) verifyIDToken(
+	d *oidcDiscovery,
+	raw string,
+	nonce string,
+) (map[string]interface{}, error) {
+
+	token, err := jwt.Parse(
+		raw,
+		func(t *jwt.Token) (interface{}, error) {
+			kid, _ := t.Header["kid"].(string)
+			return o.key(d, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384"}),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(o.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	return claims, nil
+}
+
+func (o *oidcHTTP) discover() (*oidcDiscovery, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.discovery != nil {
+		return o.discovery, nil
+	}
+
+	wellKnown := strings.TrimSuffix(o.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+
+	var d oidcDiscovery
+	if err := o.getJSON(wellKnown, &d); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+
+	if strings.TrimSuffix(d.Issuer, "/") != strings.TrimSuffix(o.cfg.Issuer, "/") {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch %q", d.Issuer)
+	}
+
+	o.discovery = &d
+	return o.discovery, nil
+}
+
+// key returns the provider key by kid, refetching JWKS when the kid is
+// unknown (provider rotated keys) or the cache is stale
+func (o *oidcHTTP) key(d *oidcDiscovery, kid string) (interface{}, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if k, ok := lookupJWK(o.keys, kid); ok && time.Since(o.keysAt) < oidcKeysTTL {
+		return k, nil
+	}
+
+	if time.Since(o.keysTriedAt) < oidcKeysRefetch {
+		return nil, fmt.Errorf("oidc jwks: unknown kid %q", kid)
+	}
+	o.keysTriedAt = time.Now()
+
+	var set oidcJWKS
+	if err := o.getJSON(d.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		pub, err := parseJWK(k)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	o.keys = keys
+	o.keysAt = time.Now()
+
+	k, ok := lookupJWK(keys, kid)
+	if !ok {
+		return nil, fmt.Errorf("oidc jwks: unknown kid %q", kid)
+	}
+
+	return k, nil
+}
+
+func lookupJWK(keys map[string]interface{}, kid string) (interface{}, bool) {
+	if k, ok := keys[kid]; ok {
+		return k, true
+	}
+
+	// провайдер с одним ключом может не указывать kid
+	if kid == "" && len(keys) == 1 {
+		for _, only := range keys {
+			return only, true
+		}
+	}
+
+	return nil, false
+}
+
+func (o *oidcHTTP) getJSON(u string, out interface{}) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", u, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func parseJWK(k oidcJWK) (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
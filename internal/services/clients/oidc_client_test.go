@@ -0,0 +1,321 @@
+package clients
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"mws-ai/internal/services"
+)
+
+const testClientID = "mws-ai-test"
+
+// mockOIDC is a local identity provider with discovery, JWKS and token
+// endpoints. Codes are registered by the test with the claims the
+// id_token should carry.
+type mockOIDC struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+
+	mu        sync.Mutex
+	codes     map[string]mockCode
+	jwksCalls int
+}
+
+type mockCode struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockOIDC(t *testing.T) *mockOIDC {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockOIDC{t: t, key: key, kid: "k1", codes: map[string]mockCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/token", m.token)
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+
+	return m
+}
+
+func (m *mockOIDC) issuer() string {
+	return m.server.URL
+}
+
+func (m *mockOIDC) config() services.OIDCConfig {
+	return services.OIDCConfig{
+		Issuer:      m.issuer(),
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost/api/auth/oidc/callback",
+		Scopes:      []string{"openid", "email"},
+		EmailClaim:  "email",
+		GroupsClaim: "groups",
+	}
+}
+
+// claims returns valid id_token claims for the nonce
+func (m *mockOIDC) claims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            m.issuer(),
+		"aud":            testClientID,
+		"sub":            "user-1",
+		"email":          "dev@example.com",
+		"email_verified": true,
+		"nonce":          nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	}
+}
+
+// authorize plays the authorization endpoint: it reads state, nonce and
+// the PKCE challenge from the URL and registers a code for claims
+func (m *mockOIDC) authorize(authURL, code string, claims func(nonce string) jwt.MapClaims) (state string) {
+	m.t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		m.t.Fatalf("authorization URL without PKCE: %s", authURL)
+	}
+
+	m.mu.Lock()
+	m.codes[code] = mockCode{challenge: q.Get("code_challenge"), claims: claims(q.Get("nonce"))}
+	m.mu.Unlock()
+
+	return q.Get("state")
+}
+
+func (m *mockOIDC) sign(claims jwt.MapClaims, kid string) string {
+	m.t.Helper()
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok.Header["kid"] = kid
+
+	s, err := tok.SignedString(m.key)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return s
+}
+
+func (m *mockOIDC) discovery(w http.ResponseWriter, _ *http.Request) {
+	_ = json.NewEncoder(w).Encode(oidcDiscovery{
+		Issuer:                m.issuer(),
+		AuthorizationEndpoint: m.issuer() + "/authorize",
+		TokenEndpoint:         m.issuer() + "/token",
+		JWKSURI:               m.issuer() + "/jwks",
+	})
+}
+
+func (m *mockOIDC) jwks(w http.ResponseWriter, _ *http.Request) {
+	m.mu.Lock()
+	m.jwksCalls++
+	m.mu.Unlock()
+
+	pub := m.key.PublicKey
+	_ = json.NewEncoder(w).Encode(oidcJWKS{Keys: []oidcJWK{{
+		Kty: "RSA",
+		Kid: m.kid,
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+func (m *mockOIDC) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("client_id") != testClientID {
+		http.Error(w, "invalid_client", http.StatusUnauthorized)
+		return
+	}
+
+	m.mu.Lock()
+	code, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	if !ok {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != code.challenge {
+		http.Error(w, "invalid_grant: pkce", http.StatusBadRequest)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(oidcTokenResponse{
+		IDToken:   m.sign(code.claims, m.kid),
+		TokenType: "Bearer",
+	})
+}
+
+func (m *mockOIDC) jwksRequests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.jwksCalls
+}
+
+// =====================
+// CLIENT
+// =====================
+
+// exchange runs one login against the provider: authorization URL with a
+// fresh PKCE pair, then the code exchange expecting wantNonce
+func exchange(t *testing.T, m *mockOIDC, o services.OIDCProvider, claims jwt.MapClaims, wantNonce string) (map[string]interface{}, error) {
+	t.Helper()
+
+	verifier := "verifier-" + strings.Repeat("x", 40)
+	sum := sha256.Sum256([]byte(verifier))
+
+	authURL, err := o.AuthCodeURL("state", "nonce", base64.RawURLEncoding.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.authorize(authURL, "code", func(string) jwt.MapClaims { return claims })
+
+	return o.Exchange("code", verifier, wantNonce)
+}
+
+func TestOIDCExchangeVerifiesIDToken(t *testing.T) {
+	m := newMockOIDC(t)
+
+	with := func(key string, v interface{}) jwt.MapClaims {
+		c := m.claims("nonce")
+		c[key] = v
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		nonce   string
+		wantErr string
+	}{
+		{"valid token", m.claims("nonce"), "nonce", ""},
+		{"nonce mismatch", m.claims("other"), "nonce", "nonce mismatch"},
+		{"foreign audience", with("aud", "another-client"), "nonce", "audience"},
+		{"foreign issuer", with("iss", "https://evil.example.com"), "nonce", "issuer"},
+		{"expired", with("exp", time.Now().Add(-time.Hour).Unix()), "nonce", "expired"},
+		{"no expiry", func() jwt.MapClaims { c := m.claims("nonce"); delete(c, "exp"); return c }(), "nonce", "exp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewOIDCClient(m.config())
+
+			claims, err := exchange(t, m, o, tt.claims, tt.nonce)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Exchange: %v", err)
+				}
+				if claims["sub"] != "user-1" {
+					t.Fatalf("Exchange claims = %v", claims)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Exchange err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOIDCExchangeRejectsWrongPKCEVerifier(t *testing.T) {
+	m := newMockOIDC(t)
+	o := NewOIDCClient(m.config())
+
+	authURL, err := o.AuthCodeURL("state", "nonce", "challenge-of-another-verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.authorize(authURL, "code", m.claims)
+
+	if _, err := o.Exchange("code", "verifier", "nonce"); err == nil {
+		t.Fatal("Exchange accepted a code with the wrong PKCE verifier")
+	}
+}
+
+func TestOIDCRejectsDiscoveryIssuerMismatch(t *testing.T) {
+	m := newMockOIDC(t)
+
+	cfg := m.config()
+	cfg.Issuer = m.issuer() + "/realms/other"
+	o := NewOIDCClient(cfg)
+
+	// discovery отдаёт другой issuer — дальше не идём
+	if _, err := o.AuthCodeURL("s", "n", "c"); err == nil {
+		t.Fatal("AuthCodeURL trusted a discovery document of another issuer")
+	}
+}
+
+func TestOIDCUnknownKidRefetchIsThrottled(t *testing.T) {
+	m := newMockOIDC(t)
+	o := NewOIDCClient(m.config()).(*oidcHTTP)
+
+	d, err := o.discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := o.key(d, m.kid); err != nil {
+		t.Fatalf("key(%q): %v", m.kid, err)
+	}
+	if got := m.jwksRequests(); got != 1 {
+		t.Fatalf("JWKS requests = %d, want 1", got)
+	}
+
+	for i := 0; i < 20; i++ {
+		if _, err := o.key(d, "forged-kid"); err == nil {
+			t.Fatal("unknown kid accepted")
+		}
+	}
+	if got := m.jwksRequests(); got != 1 {
+		t.Fatalf("JWKS requests after forged kids = %d, want 1", got)
+	}
+
+	// известный ключ по-прежнему из кеша
+	if _, err := o.key(d, m.kid); err != nil {
+		t.Fatalf("key(%q) after forged kids: %v", m.kid, err)
+	}
+
+	// ротация у провайдера: после интервала новый kid подтягивается
+	m.mu.Lock()
+	m.kid = "k2"
+	m.mu.Unlock()
+	o.keysTriedAt = time.Now().Add(-oidcKeysRefetch)
+
+	if _, err := o.key(d, "k2"); err != nil {
+		t.Fatalf("key(k2) after rotation: %v", err)
+	}
+	if got := m.jwksRequests(); got != 2 {
+		t.Fatalf("JWKS requests after rotation = %d, want 2", got)
+	}
+}
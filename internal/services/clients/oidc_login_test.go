@@ -0,0 +1,377 @@
+package clients
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+
+	"mws-ai/internal/auth/rbac"
+	"mws-ai/internal/models"
+	"mws-ai/internal/repository"
+	"mws-ai/internal/services"
+	"mws-ai/pkg/jwt"
+)
+
+// In-memory repositories for the OIDC login flow
+
+type fakeOIDCStates struct {
+	states map[string]*models.OIDCLoginState
+}
+
+func (r *fakeOIDCStates) Create(s *models.OIDCLoginState) error {
+	r.states[s.State] = s
+	return nil
+}
+
+func (r *fakeOIDCStates) Consume(state string) (*models.OIDCLoginState, error) {
+	s := r.states[state]
+	delete(r.states, state)
+	return s, nil
+}
+
+func (r *fakeOIDCStates) DeleteExpired(time.Time) error { return nil }
+
+type fakeUsers struct {
+	repository.UserRepository
+	users []*models.User
+}
+
+func (r *fakeUsers) FindByEmail(email string) (*models.User, error) {
+	for _, u := range r.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUsers) FindByOIDCIdentity(issuer, subject string) (*models.User, error) {
+	for _, u := range r.users {
+		if u.OIDCIssuer != nil && *u.OIDCIssuer == issuer && u.OIDCSubject != nil && *u.OIDCSubject == subject {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUsers) Update(*models.User) error { return nil }
+
+type fakeOrgs struct {
+	repository.OrganizationRepository
+	users       *fakeUsers
+	orgs        map[uint]*models.Organization
+	memberships []*models.Membership
+}
+
+func (r *fakeOrgs) CreateWithOwner(user *models.User, org *models.Organization, owner *models.Membership) error {
+	user.ID = uint(len(r.users.users) + 100)
+	r.users.users = append(r.users.users, user)
+
+	org.ID = uint(len(r.orgs) + 100)
+	r.orgs[org.ID] = org
+
+	owner.UserID, owner.OrganizationID = user.ID, org.ID
+	r.memberships = append(r.memberships, owner)
+	return nil
+}
+
+func (r *fakeOrgs) FindByID(id uint) (*models.Organization, error) {
+	return r.orgs[id], nil
+}
+
+func (r *fakeOrgs) FindMembership(orgID, userID uint) (*models.Membership, error) {
+	for _, m := range r.memberships {
+		if m.OrganizationID == orgID && m.UserID == userID {
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeOrgs) AddMember(m *models.Membership) error {
+	r.memberships = append(r.memberships, m)
+	return nil
+}
+
+func (r *fakeOrgs) UpdateMemberRole(orgID, userID uint, role string) error {
+	m, _ := r.FindMembership(orgID, userID)
+	m.Role = role
+	return nil
+}
+
+func (r *fakeOrgs) CountAdmins(orgID uint) (int64, error) {
+	var n int64
+	for _, m := range r.memberships {
+		if m.OrganizationID == orgID && m.Role == string(rbac.RoleAdmin) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+type fakeRefreshTokens struct {
+	repository.RefreshTokenRepository
+}
+
+func (r *fakeRefreshTokens) Create(*models.RefreshToken) error { return nil }
+
+type fakeLoginAttempts struct {
+	repository.LoginAttemptRepository
+}
+
+func (r *fakeLoginAttempts) Delete(string) error { return nil }
+
+// =====================
+// FLOW
+// =====================
+
+const corpOrgID = 1
+
+type oidcFlow struct {
+	provider *mockOIDC
+	service  *services.OIDCService
+	users    *fakeUsers
+	orgs     *fakeOrgs
+}
+
+func newOIDCFlow(t *testing.T) *oidcFlow {
+	t.Helper()
+
+	m := newMockOIDC(t)
+	users := &fakeUsers{}
+	orgs := &fakeOrgs{
+		users: users,
+		orgs:  map[uint]*models.Organization{corpOrgID: {ID: corpOrgID, Name: "corp"}},
+	}
+
+	cfg := m.config()
+	cfg.OrganizationID = corpOrgID
+	cfg.GroupRoles = map[string]rbac.Role{
+		"secops": rbac.RoleReviewer,
+		"admins": rbac.RoleAdmin,
+	}
+
+	orgService := services.NewOrganizationService(orgs, users)
+	auth := services.NewAuthService(
+		users,
+		&fakeRefreshTokens{},
+		orgService,
+		jwt.NewJWTManager("test-secret", time.Minute, time.Hour),
+		services.PasswordPolicy{},
+		services.NewLoginGuard(&fakeLoginAttempts{}, nil, services.LockoutPolicy{}),
+		nil,
+		services.EmailSettings{},
+		nil,
+	)
+
+	return &oidcFlow{
+		provider: m,
+		service: services.NewOIDCService(
+			cfg,
+			NewOIDCClient(cfg),
+			&fakeOIDCStates{states: map[string]*models.OIDCLoginState{}},
+			users,
+			orgService,
+			auth,
+		),
+		users: users,
+		orgs:  orgs,
+	}
+}
+
+// login runs Begin, lets the provider authorize with claims, then Callback
+func (f *oidcFlow) login(t *testing.T, claims func(nonce string) gojwt.MapClaims) (string, error) {
+	t.Helper()
+
+	authURL, _, err := f.service.Begin()
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	state := f.provider.authorize(authURL, "code", claims)
+
+	resp, err := f.service.Callback(state, "code")
+	if err != nil {
+		return state, err
+	}
+	if resp.AccessToken == "" || resp.RefreshToken == "" {
+		t.Fatalf("Callback returned no tokens: %+v", resp)
+	}
+	return state, nil
+}
+
+func TestOIDCCallbackProvisionsNewUser(t *testing.T) {
+	f := newOIDCFlow(t)
+
+	if _, err := f.login(t, f.provider.claims); err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+
+	u, _ := f.users.FindByEmail("dev@example.com")
+	if u == nil {
+		t.Fatal("user was not provisioned")
+	}
+	if u.OIDCSubject == nil || *u.OIDCSubject != "user-1" || u.EmailVerifiedAt == nil {
+		t.Fatalf("provisioned user = %+v", u)
+	}
+
+	// личная организация, где пользователь admin
+	var personal *models.Membership
+	for _, m := range f.orgs.memberships {
+		if m.UserID == u.ID && m.OrganizationID != corpOrgID {
+			personal = m
+		}
+	}
+	if personal == nil || personal.Role != string(rbac.RoleAdmin) {
+		t.Fatalf("personal organization membership = %+v", personal)
+	}
+
+	// повторный вход находит того же пользователя по identity
+	if _, err := f.login(t, f.provider.claims); err != nil {
+		t.Fatalf("second Callback: %v", err)
+	}
+	if len(f.users.users) != 1 {
+		t.Fatalf("users = %d, want 1", len(f.users.users))
+	}
+}
+
+func TestOIDCCallbackRejectsBadState(t *testing.T) {
+	f := newOIDCFlow(t)
+
+	if _, err := f.service.Callback("never-issued", "code"); !errors.Is(err, services.ErrOIDCInvalidState) {
+		t.Fatalf("unknown state err = %v, want ErrOIDCInvalidState", err)
+	}
+
+	state, err := f.login(t, f.provider.claims)
+	if err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	// state одноразовый
+	if _, err := f.service.Callback(state, "code"); !errors.Is(err, services.ErrOIDCInvalidState) {
+		t.Fatalf("reused state err = %v, want ErrOIDCInvalidState", err)
+	}
+}
+
+func TestOIDCCallbackRejectsBadToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(c gojwt.MapClaims)
+	}{
+		{"nonce of another login", func(c gojwt.MapClaims) { c["nonce"] = "stolen" }},
+		{"foreign audience", func(c gojwt.MapClaims) { c["aud"] = "another-client" }},
+		{"foreign issuer", func(c gojwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{"expired", func(c gojwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOIDCFlow(t)
+
+			_, err := f.login(t, func(nonce string) gojwt.MapClaims {
+				c := f.provider.claims(nonce)
+				tt.mutate(c)
+				return c
+			})
+			if !errors.Is(err, services.ErrOIDCExchange) {
+				t.Fatalf("Callback err = %v, want ErrOIDCExchange", err)
+			}
+			if len(f.users.users) != 0 {
+				t.Fatal("user provisioned from a rejected token")
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackLinksOnlyVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		verified interface{}
+		wantErr  error
+	}{
+		{"verified email links", true, nil},
+		{"unverified email", false, services.ErrOIDCEmailConflict},
+		{"no email_verified claim", nil, services.ErrOIDCEmailConflict},
+		{"string instead of bool", "true", services.ErrOIDCEmailConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOIDCFlow(t)
+			existing := &models.User{ID: 7, Email: "dev@example.com", PasswordHash: "x"}
+			f.users.users = append(f.users.users, existing)
+
+			_, err := f.login(t, func(nonce string) gojwt.MapClaims {
+				c := f.provider.claims(nonce)
+				if tt.verified == nil {
+					delete(c, "email_verified")
+				} else {
+					c["email_verified"] = tt.verified
+				}
+				return c
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Callback err = %v, want %v", err, tt.wantErr)
+			}
+
+			linked := existing.OIDCSubject != nil
+			if linked != (tt.wantErr == nil) {
+				t.Fatalf("account linked = %v", linked)
+			}
+			if len(f.users.users) != 1 {
+				t.Fatal("a second account was created for the same email")
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackRefusesAccountLinkedElsewhere(t *testing.T) {
+	f := newOIDCFlow(t)
+	issuer, subject := "https://other-idp.example.com", "someone-else"
+	f.users.users = append(f.users.users, &models.User{
+		ID: 7, Email: "dev@example.com", OIDCIssuer: &issuer, OIDCSubject: &subject,
+	})
+
+	if _, err := f.login(t, f.provider.claims); !errors.Is(err, services.ErrOIDCEmailConflict) {
+		t.Fatalf("Callback err = %v, want ErrOIDCEmailConflict", err)
+	}
+}
+
+func TestOIDCCallbackMapsGroupsToRole(t *testing.T) {
+	f := newOIDCFlow(t)
+
+	withGroups := func(groups ...interface{}) func(string) gojwt.MapClaims {
+		return func(nonce string) gojwt.MapClaims {
+			c := f.provider.claims(nonce)
+			c["groups"] = groups
+			return c
+		}
+	}
+	role := func() string {
+		u, _ := f.users.FindByEmail("dev@example.com")
+		m, _ := f.orgs.FindMembership(corpOrgID, u.ID)
+		if m == nil {
+			return ""
+		}
+		return m.Role
+	}
+
+	steps := []struct {
+		name   string
+		groups []interface{}
+		want   string
+	}{
+		{"unmapped groups grant nothing", []interface{}{"developers"}, ""},
+		{"mapped group adds membership", []interface{}{"developers", "secops"}, string(rbac.RoleReviewer)},
+		{"most privileged group wins", []interface{}{"secops", "admins"}, string(rbac.RoleAdmin)},
+	}
+
+	for _, s := range steps {
+		if _, err := f.login(t, withGroups(s.groups...)); err != nil {
+			t.Fatalf("%s: Callback: %v", s.name, err)
+		}
+		if got := role(); got != s.want {
+			t.Fatalf("%s: role in corp = %q, want %q", s.name, got, s.want)
+		}
+	}
+}
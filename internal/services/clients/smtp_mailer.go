@@ -0,0 +1,60 @@
+package clients
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"mws-ai/internal/services"
+)
+
+type smtpMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer sends through an SMTP relay. STARTTLS is used when the
+// server offers it; auth is skipped when user is empty.
+func NewSMTPMailer(host string, port int, user, password, from string) services.Mailer {
+	var auth smtp.Auth
+	if user != "" {
+		auth = smtp.PlainAuth("", user, password, host)
+	}
+
+	return &smtpMailer{
+		addr: net.JoinHostPort(host, fmt.Sprint(port)),
+		from: from,
+		auth: auth,
+	}
+}
+
+func (m *smtpMailer) Send(msg services.Mail) error {
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, buildMessage(m.from, msg)); err != nil {
+		return fmt.Errorf("smtp send error: %w", err)
+	}
+	return nil
+}
+
+// buildMessage renders RFC 5322 headers and a UTF-8 text body
+func buildMessage(from string, msg services.Mail) []byte {
+	var b strings.Builder
+
+	// переводы строк в заголовках = header injection
+	clean := strings.NewReplacer("\r", "", "\n", "")
+
+	fmt.Fprintf(&b, "From: %s\r\n", clean.Replace(from))
+	fmt.Fprintf(&b, "To: %s\r\n", clean.Replace(msg.To))
+	// заголовки — только ASCII, кириллица идёт encoded-word (RFC 2047)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", clean.Replace(msg.Subject)))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return []byte(b.String())
+}
@@ -0,0 +1,62 @@
+package clients
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"mws-ai/internal/services"
+)
+
+func TestBuildMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+	}{
+		{"ascii", "Verify your email"},
+		{"cyrillic", "Подтвердите email"},
+		{"long cyrillic", strings.Repeat("Сброс пароля ", 10)},
+		{"header injection", "Hi\r\nBcc: victim@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := buildMessage("MWS AI <noreply@example.com>", services.Mail{
+				To:      "dev@example.com",
+				Subject: tt.subject,
+				Body:    "Привет\nссылка",
+			})
+
+			head := raw[:bytes.Index(raw, []byte("\r\n\r\n"))]
+			for _, c := range head {
+				if c >= 0x80 {
+					t.Fatalf("non-ASCII byte in headers:\n%s", head)
+				}
+			}
+
+			msg, err := mail.ReadMessage(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Header.Get("Bcc") != "" {
+				t.Fatal("subject injected a header")
+			}
+
+			subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := strings.NewReplacer("\r", "", "\n", "").Replace(tt.subject); subject != want {
+				t.Fatalf("Subject = %q, want %q", subject, want)
+			}
+
+			body, _ := io.ReadAll(msg.Body)
+			if string(body) != "Привет\r\nссылка" {
+				t.Fatalf("body = %q", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"mws-ai/internal/models"
+	"mws-ai/internal/repository"
+)
+
+// In-memory repositories for service tests. Embedding the interface
+// leaves every method a test doesn't expect to call as a nil panic.
+
+type fakeAnalysisRepo struct {
+	repository.AnalysisRepository
+	analyses  map[uint]*models.Analysis
+	setKeyErr error
+}
+
+func (r *fakeAnalysisRepo) Create(a *models.Analysis) error {
+	a.ID = uint(len(r.analyses) + 1)
+	cp := *a
+	r.analyses[a.ID] = &cp
+	return nil
+}
+
+func (r *fakeAnalysisRepo) UpdateStatus(id uint, status string) error {
+	r.analyses[id].Status = status
+	return nil
+}
+
+func (r *fakeAnalysisRepo) SetStorageKey(id uint, key string) error {
+	if r.setKeyErr != nil {
+		return r.setKeyErr
+	}
+	r.analyses[id].StorageKey = key
+	return nil
+}
+
+func (r *fakeAnalysisRepo) GetByID(id uint) (*models.Analysis, error) {
+	a, ok := r.analyses[id]
+	if !ok {
+		return nil, nil
+	}
+	cp := *a
+	return &cp, nil
+}
+
+func (r *fakeAnalysisRepo) ListExpiredReports(before time.Time, afterID uint, limit int) ([]models.Analysis, error) {
+	ids := make([]uint, 0, len(r.analyses))
+	for id := range r.analyses {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	var out []models.Analysis
+	for _, id := range ids {
+		a := r.analyses[id]
+		if id > afterID && a.StorageKey != "" && a.UploadedAt.Before(before) && len(out) < limit {
+			out = append(out, *a)
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeAnalysisRepo) MarkReportPurged(id uint, at time.Time) error {
+	r.analyses[id].StorageKey = ""
+	r.analyses[id].ReportPurgedAt = &at
+	return nil
+}
+
+type fakeFindingRepo struct {
+	repository.FindingRepository
+	findings map[uint]*models.Finding
+}
+
+func (r *fakeFindingRepo) GetByID(id uint) (*models.Finding, error) {
+	f, ok := r.findings[id]
+	if !ok {
+		return nil, nil
+	}
+	cp := *f
+	return &cp, nil
+}
+
+func (r *fakeFindingRepo) ListByAnalysis(analysisID uint) ([]models.Finding, error) {
+	var out []models.Finding
+	for _, f := range r.findings {
+		if f.AnalysisID == analysisID {
+			out = append(out, *f)
+		}
+	}
+	return out, nil
+}
+
+type fakeAuditRepo struct {
+	mu      sync.Mutex
+	entries []*models.AuditLog
+}
+
+func (r *fakeAuditRepo) Create(entry *models.AuditLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func (r *fakeAuditRepo) events(event string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, e := range r.entries {
+		if e.Event == event {
+			n++
+		}
+	}
+	return n
+}
+
+// fakeLoginAttempts mirrors the upsert semantics of the Postgres repository
+type fakeLoginAttempts struct {
+	mu       sync.Mutex
+	attempts map[string]*models.LoginAttempt
+}
+
+func (r *fakeLoginAttempts) FindByKeys(keys []string) ([]models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out []models.LoginAttempt
+	for _, k := range keys {
+		if a, ok := r.attempts[k]; ok {
+			out = append(out, *a)
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeLoginAttempts) RecordFailure(key string, now time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.attempts[key]
+	stale := ok && a.LastFailureAt.Before(now.Add(-window)) &&
+		(a.LockedUntil == nil || a.LockedUntil.Before(now))
+
+	if !ok || stale {
+		a = &models.LoginAttempt{Key: key}
+		r.attempts[key] = a
+	}
+	a.Failures++
+	a.LastFailureAt = now
+
+	cp := *a
+	return &cp, nil
+}
+
+func (r *fakeLoginAttempts) Lock(key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if a, ok := r.attempts[key]; ok && (a.LockedUntil == nil || until.After(*a.LockedUntil)) {
+		a.LockedUntil = &until
+	}
+	return nil
+}
+
+func (r *fakeLoginAttempts) Delete(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.attempts, key)
+	return nil
+}
+
+type fakeUserRepo struct {
+	repository.UserRepository
+	users map[uint]*models.User
+}
+
+func (r *fakeUserRepo) FindByID(id uint) (*models.User, error) {
+	u, ok := r.users[id]
+	if !ok {
+		return nil, nil
+	}
+	cp := *u
+	return &cp, nil
+}
+
+func (r *fakeUserRepo) Update(user *models.User) error {
+	cp := *user
+	r.users[user.ID] = &cp
+	return nil
+}
+
+func (r *fakeUserRepo) AdvanceTOTPStep(id uint, step int64) (bool, error) {
+	u := r.users[id]
+	if u.TOTPLastStep >= step {
+		return false, nil
+	}
+	u.TOTPLastStep = step
+	return true, nil
+}
+
+type fakeRecoveryCodes struct {
+	hashes map[uint][]string
+}
+
+func (r *fakeRecoveryCodes) ReplaceForUser(userID uint, hashes []string) error {
+	r.hashes[userID] = hashes
+	return nil
+}
+
+func (r *fakeRecoveryCodes) Consume(userID uint, hash string, at time.Time) (bool, error) {
+	i := slices.Index(r.hashes[userID], hash)
+	if i < 0 {
+		return false, nil
+	}
+	r.hashes[userID] = slices.Delete(r.hashes[userID], i, i+1)
+	return true, nil
+}
+
+func (r *fakeRecoveryCodes) CountUnused(userID uint) (int64, error) {
+	return int64(len(r.hashes[userID])), nil
+}
+
+func (r *fakeRecoveryCodes) DeleteForUser(userID uint) error {
+	delete(r.hashes, userID)
+	return nil
+}
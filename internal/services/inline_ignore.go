@@ -0,0 +1,112 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"mws-ai/internal/models"
+	"mws-ai/pkg/mask"
+)
+
+// ignorePattern matches the inline markers of this and other scanners:
+// mws-ai:ignore, gitleaks:allow, trufflehog:ignore, pragma: allowlist secret
+var ignorePattern = regexp.MustCompile(
+	`(?i)\b(mws-ai:ignore|gitleaks:allow|trufflehog:ignore|pragma:\s*allowlist\s+secret)\b`,
+)
+
+// commentStart finds where a comment begins on a line; the rarer
+// markers (SQL/Lua "--", ini ";", batch REM) are only tried after the
+// common ones, since they also show up inside code
+var (
+	commentStart     = regexp.MustCompile(`//|/\*|<!--|#`)
+	commentStartRare = regexp.MustCompile(`--|;|\bREM\b`)
+)
+
+// maxIgnoreComment bounds the comment text stored on the finding
+const maxIgnoreComment = 200
+
+// inlineIgnore looks for an ignore marker on the finding's lines or in a
+// comment-only line right above them and returns the comment text.
+// Without a usable line position in the context nothing is checked: a
+// marker inside the value would let anyone ignore their own finding.
+func inlineIgnore(f *models.Finding) (string, bool) {
+	lines, first := ignoreCandidates(f)
+
+	for i, line := range lines {
+		loc := ignorePattern.FindStringIndex(line)
+		if loc == nil {
+			continue
+		}
+
+		// строка выше считается, только если это целиком комментарий:
+		// маркер в конце чужой строки относится к ней, а не к нашей
+		if i == 0 && first && !isCommentLine(line) {
+			continue
+		}
+
+		comment := ignoreComment(line, loc[0])
+		// контекст обычно уже замаскирован, но сырое значение в нём не исключено
+		comment = mask.Scrub(mask.Redact(comment, mask.FindSecret(f.Value)))
+
+		return comment, true
+	}
+
+	return "", false
+}
+
+// ignoreCandidates returns the line above (flagged by the second result)
+// followed by the finding's own lines
+func ignoreCandidates(f *models.Finding) ([]string, bool) {
+	if f.Context == "" || f.ContextStartLine < 1 {
+		return nil, false
+	}
+
+	ctx := strings.Split(f.Context, "\n")
+
+	from := f.Line - f.ContextStartLine
+	to := from
+	if f.LineEnd != nil && *f.LineEnd > f.Line {
+		to += *f.LineEnd - f.Line
+	}
+	if from < 0 || from >= len(ctx) {
+		return nil, false
+	}
+	to = min(to, len(ctx)-1)
+
+	if from == 0 {
+		return ctx[from : to+1], false
+	}
+	return ctx[from-1 : to+1], true
+}
+
+func isCommentLine(line string) bool {
+	start := findCommentStart(line)
+	return start >= 0 && strings.TrimSpace(line[:start]) == ""
+}
+
+func findCommentStart(s string) int {
+	for _, re := range []*regexp.Regexp{commentStart, commentStartRare} {
+		if loc := re.FindStringIndex(s); loc != nil {
+			return loc[0]
+		}
+	}
+	return -1
+}
+
+// ignoreComment cuts the comment the marker belongs to out of the line
+func ignoreComment(line string, marker int) string {
+	start := marker
+	if i := findCommentStart(line[:marker]); i >= 0 {
+		start = i
+	}
+
+	comment := strings.TrimSpace(line[start:])
+	comment = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(comment, "*/"), "-->"))
+
+	if utf8.RuneCountInString(comment) > maxIgnoreComment {
+		comment = string([]rune(comment)[:maxIgnoreComment]) + "…"
+	}
+
+	return comment
+}
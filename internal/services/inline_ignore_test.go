@@ -0,0 +1,94 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"mws-ai/internal/models"
+)
+
+func TestInlineIgnore(t *testing.T) {
+	lineEnd := func(n int) *int { return &n }
+
+	tests := []struct {
+		name        string
+		finding     models.Finding
+		wantIgnored bool
+		wantComment string
+	}{
+		{
+			name: "marker on the finding line",
+			finding: models.Finding{
+				Line: 11, ContextStartLine: 10,
+				Context: "func main() {\n\ttoken := \"abc\" // gitleaks:allow test fixture\n}",
+			},
+			wantIgnored: true,
+			wantComment: "// gitleaks:allow test fixture",
+		},
+		{
+			name: "comment-only line above",
+			finding: models.Finding{
+				Line: 2, ContextStartLine: 1,
+				Context: "# pragma: allowlist secret\npassword = hunter2",
+			},
+			wantIgnored: true,
+			wantComment: "# pragma: allowlist secret",
+		},
+		{
+			name: "marker ending the line above belongs to it",
+			finding: models.Finding{
+				Line: 2, ContextStartLine: 1,
+				Context: "a = 1 # mws-ai:ignore\nb = secret",
+			},
+		},
+		{
+			name: "multi-line finding",
+			finding: models.Finding{
+				Line: 1, LineEnd: lineEnd(3), ContextStartLine: 1,
+				Context: "key = \"\"\"\nMIIE...\n\"\"\" -- trufflehog:ignore",
+			},
+			wantIgnored: true,
+			wantComment: "-- trufflehog:ignore",
+		},
+		{
+			name: "no context: the value is not checked",
+			finding: models.Finding{
+				Value: "sk_live_abc // mws-ai:ignore",
+			},
+		},
+		{
+			name: "line outside the context",
+			finding: models.Finding{
+				Value: "mws-ai:ignore", Line: 50, ContextStartLine: 1,
+				Context: "x = 1 # mws-ai:ignore",
+			},
+		},
+		{
+			name: "nosecret is not a marker",
+			finding: models.Finding{
+				Line: 1, ContextStartLine: 1,
+				Context: "api_key = load() # nosecret",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comment, ok := inlineIgnore(&tt.finding)
+			if ok != tt.wantIgnored {
+				t.Fatalf("ignored = %v, want %v (comment %q)", ok, tt.wantIgnored, comment)
+			}
+			if comment != tt.wantComment {
+				t.Fatalf("comment = %q, want %q", comment, tt.wantComment)
+			}
+		})
+	}
+}
+
+func TestIgnoreCommentIsTruncated(t *testing.T) {
+	got := ignoreComment("# mws-ai:ignore "+strings.Repeat("я", 300), 2)
+	if n := utf8.RuneCountInString(got); n != maxIgnoreComment+1 {
+		t.Fatalf("comment length = %d runes, want %d", n, maxIgnoreComment+1)
+	}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mws-ai/internal/models"
+	"mws-ai/internal/repository"
+	"mws-ai/pkg/logger"
+)
+
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+
+// LockoutError carries the time until which login is blocked.
+// errors.Is(err, ErrTooManyAttempts) matches it.
+type LockoutError struct {
+	Until time.Time
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyAttempts, e.Until.UTC().Format(time.RFC3339))
+}
+
+func (e *LockoutError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// LockoutPolicy configures brute-force protection. After MaxFailures
+// failures within Window the key is locked for BaseLockout, doubled with
+// every further failure up to MaxLockout.
+type LockoutPolicy struct {
+	MaxFailures   int // per account
+	IPMaxFailures int // per client IP, higher: offices share addresses
+	Window        time.Duration
+	BaseLockout   time.Duration
+	MaxLockout    time.Duration
+}
+
+// LoginGuard tracks failed logins per account and per client IP
+type LoginGuard struct {
+	attempts repository.LoginAttemptRepository
+	audit    *AuditService
+	policy   LockoutPolicy
+}
+
+func NewLoginGuard(
+	attempts repository.LoginAttemptRepository,
+	audit *AuditService,
+	policy LockoutPolicy,
+) *LoginGuard {
+	return &LoginGuard{
+		attempts: attempts,
+		audit:    audit,
+		policy:   policy,
+	}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns a *LockoutError if the account or the IP is locked
+func (g *LoginGuard) Check(email, ip string) error {
+	attempts, err := g.attempts.FindByKeys([]string{accountKey(email), ipKey(ip)})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	var until time.Time
+	for _, a := range attempts {
+		if a.LockedUntil != nil && a.LockedUntil.After(now) && a.LockedUntil.After(until) {
+			until = *a.LockedUntil
+		}
+	}
+
+	if !until.IsZero() {
+		return &LockoutError{Until: until}
+	}
+
+	return nil
+}
+
+// Fail records a failed login for both the account and the IP.
+// Unknown emails are counted too, so lockouts don't reveal which exist.
+func (g *LoginGuard) Fail(email, ip string, userID *uint) error {
+	if err := g.fail(accountKey(email), g.policy.MaxFailures, func(until time.Time, failures int) {
+		g.audit.Record(&models.AuditLog{
+			Event:   AuditAccountLocked,
+			UserID:  userID,
+			IP:      ip,
+			Subject: email,
+			Details: map[string]interface{}{
+				"failures":     failures,
+				"locked_until": until.UTC().Format(time.RFC3339),
+			},
+		})
+	}); err != nil {
+		return err
+	}
+
+	return g.fail(ipKey(ip), g.policy.IPMaxFailures, func(until time.Time, failures int) {
+		g.audit.Record(&models.AuditLog{
+			Event:   AuditIPLocked,
+			IP:      ip,
+			Subject: ip,
+			Details: map[string]interface{}{
+				"failures":     failures,
+				"locked_until": until.UTC().Format(time.RFC3339),
+			},
+		})
+	})
+}
+
+// Succeed clears the account counter. The IP counter is left alone:
+// otherwise an attacker could reset it by logging into their own account.
+func (g *LoginGuard) Succeed(email string) error {
+	return g.attempts.Delete(accountKey(email))
+}
+
+// fail counts the failure atomically and decides on the lockout from the
+// returned counter, so parallel attempts can't exceed the threshold
+func (g *LoginGuard) fail(key string, max int, onLock func(until time.Time, failures int)) error {
+	now := time.Now()
+
+	a, err := g.attempts.RecordFailure(key, now, g.policy.Window)
+	if err != nil {
+		return err
+	}
+
+	if a.Failures < max {
+		return nil
+	}
+
+	until := now.Add(g.lockoutFor(a.Failures - max))
+	if err := g.attempts.Lock(key, until); err != nil {
+		return err
+	}
+
+	logger.Log.Warn().
+		Str("service", "login_guard").
+		Str("key", key).
+		Int("failures", a.Failures).
+		Time("locked_until", until).
+		Msg("login locked")
+
+	onLock(until, a.Failures)
+	return nil
+}
+
+// lockoutFor returns BaseLockout * 2^extra, capped at MaxLockout
+func (g *LoginGuard) lockoutFor(extra int) time.Duration {
+	d := g.policy.BaseLockout
+	for i := 0; i < extra && d < g.policy.MaxLockout; i++ {
+		d *= 2
+	}
+	if d > g.policy.MaxLockout {
+		d = g.policy.MaxLockout
+	}
+	return d
+}
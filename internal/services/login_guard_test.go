@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"mws-ai/internal/models"
+)
+
+var testLockout = LockoutPolicy{
+	MaxFailures:   5,
+	IPMaxFailures: 20,
+	Window:        15 * time.Minute,
+	BaseLockout:   time.Minute,
+	MaxLockout:    time.Hour,
+}
+
+func newTestGuard() (*LoginGuard, *fakeLoginAttempts, *fakeAuditRepo) {
+	attempts := &fakeLoginAttempts{attempts: map[string]*models.LoginAttempt{}}
+	audit := &fakeAuditRepo{}
+	return NewLoginGuard(attempts, NewAuditService(audit), testLockout), attempts, audit
+}
+
+func TestLoginGuardLocksAfterMaxFailures(t *testing.T) {
+	g, _, audit := newTestGuard()
+
+	for i := 1; i < testLockout.MaxFailures; i++ {
+		if err := g.Fail("dev@example.com", "10.0.0.1", nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Check("dev@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("locked after %d failures: %v", i, err)
+		}
+	}
+
+	if err := g.Fail("dev@example.com", "10.0.0.1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var lockout *LockoutError
+	err := g.Check("DEV@example.com ", "10.0.0.2")
+	if !errors.As(err, &lockout) || !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("Check err = %v, want LockoutError", err)
+	}
+	if d := time.Until(lockout.Until); d <= 0 || d > testLockout.BaseLockout {
+		t.Fatalf("locked for %s, want up to %s", d, testLockout.BaseLockout)
+	}
+	if n := audit.events(AuditAccountLocked); n != 1 {
+		t.Fatalf("account_locked events = %d, want 1", n)
+	}
+}
+
+func TestLoginGuardCountsParallelFailures(t *testing.T) {
+	g, attempts, _ := newTestGuard()
+
+	const parallel = 50
+
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = g.Fail("dev@example.com", "10.0.0.1", nil)
+		}()
+	}
+	wg.Wait()
+
+	got, _ := attempts.FindByKeys([]string{accountKey("dev@example.com")})
+	if len(got) != 1 || got[0].Failures != parallel {
+		t.Fatalf("account failures = %+v, want %d", got, parallel)
+	}
+	if got[0].LockedUntil == nil {
+		t.Fatal("account not locked after parallel failures")
+	}
+	// блокировка соответствует максимальному счётчику, а не последнему записавшему
+	want := g.lockoutFor(parallel - testLockout.MaxFailures)
+	if d := time.Until(*got[0].LockedUntil); d < want-time.Minute {
+		t.Fatalf("locked for %s, want about %s", d, want)
+	}
+}
+
+func TestLoginGuardSucceedResetsOnlyAccount(t *testing.T) {
+	g, attempts, _ := newTestGuard()
+
+	for i := 0; i < 3; i++ {
+		_ = g.Fail("dev@example.com", "10.0.0.1", nil)
+	}
+	if err := g.Succeed("dev@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := attempts.FindByKeys([]string{accountKey("dev@example.com"), ipKey("10.0.0.1")})
+	if len(got) != 1 || got[0].Key != ipKey("10.0.0.1") || got[0].Failures != 3 {
+		t.Fatalf("attempts after success = %+v, want only the IP counter", got)
+	}
+}
+
+func TestLoginGuardLockoutBackoff(t *testing.T) {
+	g, _, _ := newTestGuard()
+
+	tests := []struct {
+		extra int
+		want  time.Duration
+	}{
+		{0, time.Minute},
+		{1, 2 * time.Minute},
+		{3, 8 * time.Minute},
+		{6, time.Hour},
+		{100, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := g.lockoutFor(tt.extra); got != tt.want {
+			t.Errorf("lockoutFor(%d) = %s, want %s", tt.extra, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package services
+
+// Mail is a plain-text email
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails (SMTP in production, log or files in dev)
+type Mailer interface {
+	Send(m Mail) error
+}
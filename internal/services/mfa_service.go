@@ -0,0 +1,427 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"mws-ai/internal/dto"
+	"mws-ai/internal/models"
+	"mws-ai/internal/repository"
+	"mws-ai/pkg/envelope"
+	"mws-ai/pkg/jwt"
+	"mws-ai/pkg/logger"
+	"mws-ai/pkg/totp"
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor authentication is not set up")
+	ErrInvalidMFACode    = errors.New("invalid authentication code")
+	ErrInvalidMFAToken   = errors.New("invalid or expired mfa token")
+)
+
+const (
+	// MFAChallengeTTL is how long the second login step may take
+	MFAChallengeTTL = 5 * time.Minute
+
+	recoveryCodeCount = 10
+	// ±1 step tolerates 30s of clock drift on the phone
+	totpSkew = 1
+)
+
+// Audit events
+const (
+	AuditMFAEnabled       = "auth.mfa_enabled"
+	AuditMFADisabled      = "auth.mfa_disabled"
+	AuditRecoveryCodeUsed = "auth.recovery_code_used"
+)
+
+// MFAService manages TOTP enrollment, recovery codes and the second
+// login step
+type MFAService struct {
+	users         repository.UserRepository
+	recoveryCodes repository.RecoveryCodeRepository
+	auth          *AuthService
+	audit         *AuditService
+	secrets       *envelope.Keyring // nil: TOTP seeds stay in plaintext (dev)
+	issuer        string
+}
+
+func NewMFAService(
+	users repository.UserRepository,
+	recoveryCodes repository.RecoveryCodeRepository,
+	auth *AuthService,
+	audit *AuditService,
+	secrets *envelope.Keyring,
+	issuer string,
+) *MFAService {
+	return &MFAService{
+		users:         users,
+		recoveryCodes: recoveryCodes,
+		auth:          auth,
+		audit:         audit,
+		secrets:       secrets,
+		issuer:        issuer,
+	}
+}
+
+// =====================
+// LOGIN
+// =====================
+
+// Verify finishes a login that returned an MFA challenge. Wrong codes
+// count towards the same lockout as wrong passwords.
+func (s *MFAService) Verify(mfaToken, code, ip string) (*dto.AuthResponse, error) {
+	log := logger.Log.With().
+		Str("service", "mfa").
+		Str("method", "Verify").
+		Str("ip", ip).
+		Logger()
+
+	claims, err := s.auth.jwt.ParseAction(mfaToken, jwt.TokenTypeMFAChallenge)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+
+	userID, ok := jwt.UserID(claims)
+	if !ok {
+		return nil, ErrInvalidMFAToken
+	}
+
+	user, err := s.users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.TOTPEnabledAt == nil || jwt.Version(claims) != user.TokenVersion {
+		return nil, ErrInvalidMFAToken
+	}
+
+	log = log.With().Uint("user_id", user.ID).Logger()
+
+	if err := s.guardedCheck(user, ip, "Verify", func() (bool, error) {
+		return s.checkCode(user, code, ip)
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := s.auth.guard.Succeed(user.Email); err != nil {
+		return nil, err
+	}
+
+	log.Info().Msg("mfa login successful")
+
+	return s.auth.issueTokens(user, "", true)
+}
+
+// =====================
+// ENROLLMENT
+// =====================
+
+// Status reports whether 2FA is on and how many recovery codes are left
+func (s *MFAService) Status(userID uint) (*dto.MFAStatusResponse, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.MFAStatusResponse{Enabled: user.TOTPEnabledAt != nil}
+
+	if resp.Enabled {
+		left, err := s.recoveryCodes.CountUnused(userID)
+		if err != nil {
+			return nil, err
+		}
+		resp.RecoveryCodesLeft = left
+	}
+
+	return resp, nil
+}
+
+// Enroll generates a new TOTP secret. The factor stays inactive until
+// ConfirmEnrollment proves the authenticator app has it.
+func (s *MFAService) Enroll(userID uint) (*dto.TOTPEnrollResponse, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := s.sealSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	user.TOTPSecret = &sealed
+	user.TOTPLastStep = 0
+
+	if err := s.users.Update(user); err != nil {
+		return nil, err
+	}
+
+	return &dto.TOTPEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmEnrollment activates TOTP and returns the first recovery codes
+func (s *MFAService) ConfirmEnrollment(userID uint, code, ip string) ([]string, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == nil {
+		return nil, ErrMFANotEnrolled
+	}
+
+	if err := s.guardedCheck(user, ip, "ConfirmEnrollment", func() (bool, error) {
+		return s.checkTOTP(user, code)
+	}); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user.TOTPEnabledAt = &now
+
+	if err := s.users.Update(user); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.issueRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(&models.AuditLog{
+		Event:   AuditMFAEnabled,
+		UserID:  &user.ID,
+		IP:      ip,
+		Subject: user.Email,
+	})
+
+	return codes, nil
+}
+
+// Disable turns 2FA off; needs a current TOTP or recovery code
+func (s *MFAService) Disable(userID uint, code, ip string) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabledAt == nil {
+		return ErrMFANotEnrolled
+	}
+
+	if err := s.guardedCheck(user, ip, "Disable", func() (bool, error) {
+		return s.checkCode(user, code, ip)
+	}); err != nil {
+		return err
+	}
+
+	user.TOTPSecret = nil
+	user.TOTPEnabledAt = nil
+	user.TOTPLastStep = 0
+
+	if err := s.users.Update(user); err != nil {
+		return err
+	}
+	if err := s.recoveryCodes.DeleteForUser(userID); err != nil {
+		return err
+	}
+
+	s.audit.Record(&models.AuditLog{
+		Event:   AuditMFADisabled,
+		UserID:  &user.ID,
+		IP:      ip,
+		Subject: user.Email,
+	})
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes; needs a TOTP code
+func (s *MFAService) RegenerateRecoveryCodes(userID uint, code, ip string) ([]string, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt == nil {
+		return nil, ErrMFANotEnrolled
+	}
+
+	if err := s.guardedCheck(user, ip, "RegenerateRecoveryCodes", func() (bool, error) {
+		return s.checkTOTP(user, code)
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.issueRecoveryCodes(userID)
+}
+
+// =====================
+// CODES
+// =====================
+
+// guardedCheck runs a code check for a signed-in user behind the login
+// guard: a stolen session must not get unlimited guesses. A lockout here
+// also blocks password logins, as in Verify.
+func (s *MFAService) guardedCheck(user *models.User, ip, method string, check func() (bool, error)) error {
+	log := logger.Log.With().
+		Str("service", "mfa").
+		Str("method", method).
+		Uint("user_id", user.ID).
+		Str("ip", ip).
+		Logger()
+
+	if err := s.auth.guard.Check(user.Email, ip); err != nil {
+		log.Info().Err(err).Msg("mfa code check blocked")
+		return err
+	}
+
+	valid, err := check()
+	if err != nil {
+		return err
+	}
+	if !valid {
+		log.Info().Msg("invalid mfa code")
+
+		if err := s.auth.guard.Fail(user.Email, ip, &user.ID); err != nil {
+			return err
+		}
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// checkCode accepts a TOTP code or, failing that, a recovery code
+func (s *MFAService) checkCode(user *models.User, code, ip string) (bool, error) {
+	valid, err := s.checkTOTP(user, code)
+	if err != nil || valid {
+		return valid, err
+	}
+
+	used, err := s.recoveryCodes.Consume(user.ID, hashRecoveryCode(code), time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	if used {
+		s.audit.Record(&models.AuditLog{
+			Event:   AuditRecoveryCodeUsed,
+			UserID:  &user.ID,
+			IP:      ip,
+			Subject: user.Email,
+		})
+	}
+
+	return used, nil
+}
+
+// checkTOTP validates the code and burns its time step
+func (s *MFAService) checkTOTP(user *models.User, code string) (bool, error) {
+	if user.TOTPSecret == nil {
+		return false, nil
+	}
+
+	secret, err := s.openSecret(*user.TOTPSecret)
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now(), totpSkew)
+	if !ok {
+		return false, nil
+	}
+
+	fresh, err := s.users.AdvanceTOTPStep(user.ID, step)
+	if err != nil || !fresh {
+		return false, err
+	}
+
+	// держим модель в синхроне, чтобы последующий Update не откатил шаг
+	user.TOTPLastStep = step
+	return true, nil
+}
+
+func (s *MFAService) issueRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+		code := raw[:5] + "-" + raw[5:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	if err := s.recoveryCodes.ReplaceForUser(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// hashRecoveryCode normalizes case and separators before hashing.
+// Codes are random enough that a plain SHA-256 is sufficient.
+func hashRecoveryCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// sealSecret encrypts the TOTP seed: it is as good as the second factor
+func (s *MFAService) sealSecret(v string) (string, error) {
+	if s.secrets == nil {
+		return v, nil
+	}
+	return s.secrets.Seal(v)
+}
+
+func (s *MFAService) openSecret(v string) (string, error) {
+	if s.secrets == nil {
+		if envelope.IsSealed(v) {
+			return "", envelope.ErrUnknownKey
+		}
+		return v, nil
+	}
+	return s.secrets.Open(v)
+}
+
+func (s *MFAService) findUser(userID uint) (*models.User, error) {
+	user, err := s.users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
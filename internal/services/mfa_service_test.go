@@ -0,0 +1,133 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"mws-ai/internal/models"
+	"mws-ai/pkg/envelope"
+	"mws-ai/pkg/totp"
+)
+
+func newTestMFA(t *testing.T, enabled bool) (*MFAService, *fakeUserRepo, *fakeAuditRepo, string) {
+	t.Helper()
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &models.User{ID: 1, Email: "dev@example.com", TOTPSecret: &secret}
+	if enabled {
+		now := time.Now()
+		user.TOTPEnabledAt = &now
+	}
+
+	users := &fakeUserRepo{users: map[uint]*models.User{1: user}}
+	audit := &fakeAuditRepo{}
+	auditService := NewAuditService(audit)
+
+	guard := NewLoginGuard(&fakeLoginAttempts{attempts: map[string]*models.LoginAttempt{}}, auditService, testLockout)
+	auth := NewAuthService(users, nil, nil, nil, PasswordPolicy{}, guard, nil, EmailSettings{}, auditService)
+
+	keys, err := envelope.NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// сид пользователя лежит открытым текстом, как до включения шифрования
+	mfa := NewMFAService(users, &fakeRecoveryCodes{hashes: map[uint][]string{}}, auth, auditService, keys, "mws-ai")
+	return mfa, users, audit, secret
+}
+
+// TestMFACodeChecksAreGuarded: every endpoint that takes a code counts
+// wrong guesses and stops accepting codes, even correct ones, once locked
+func TestMFACodeChecksAreGuarded(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		call    func(s *MFAService, code string) error
+	}{
+		{"confirm enrollment", false, func(s *MFAService, code string) error {
+			_, err := s.ConfirmEnrollment(1, code, "10.0.0.1")
+			return err
+		}},
+		{"disable", true, func(s *MFAService, code string) error {
+			return s.Disable(1, code, "10.0.0.1")
+		}},
+		{"regenerate recovery codes", true, func(s *MFAService, code string) error {
+			_, err := s.RegenerateRecoveryCodes(1, code, "10.0.0.1")
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mfa, users, audit, secret := newTestMFA(t, tt.enabled)
+
+			for i := 0; i < testLockout.MaxFailures; i++ {
+				if err := tt.call(mfa, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+					t.Fatalf("wrong code %d: err = %v, want ErrInvalidMFACode", i+1, err)
+				}
+			}
+			if n := audit.events(AuditAccountLocked); n != 1 {
+				t.Fatalf("account_locked events = %d, want 1", n)
+			}
+
+			code, _ := totp.Code(secret, totp.Step(time.Now()))
+			var locked *LockoutError
+			if err := tt.call(mfa, code); !errors.As(err, &locked) {
+				t.Fatalf("correct code while locked: err = %v, want LockoutError", err)
+			}
+
+			// заблокированная попытка не сжигает шаг TOTP
+			if users.users[1].TOTPLastStep != 0 {
+				t.Fatal("locked check consumed the TOTP step")
+			}
+		})
+	}
+}
+
+func TestMFADisableWithValidCode(t *testing.T) {
+	mfa, users, audit, secret := newTestMFA(t, true)
+
+	code, _ := totp.Code(secret, totp.Step(time.Now()))
+	if err := mfa.Disable(1, code, "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if u := users.users[1]; u.TOTPEnabledAt != nil || u.TOTPSecret != nil {
+		t.Fatalf("2FA still on: %+v", u)
+	}
+	if n := audit.events(AuditMFADisabled); n != 1 {
+		t.Fatalf("mfa_disabled events = %d, want 1", n)
+	}
+
+	// после отключения управлять больше нечем
+	if err := mfa.Disable(1, code, "10.0.0.1"); !errors.Is(err, ErrMFANotEnrolled) {
+		t.Fatalf("second Disable err = %v", err)
+	}
+}
+
+func TestMFAEnrollSealsSecret(t *testing.T) {
+	mfa, users, _, _ := newTestMFA(t, false)
+	users.users[1].TOTPSecret = nil
+
+	enroll, err := mfa.Enroll(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := *users.users[1].TOTPSecret
+	if !envelope.IsSealed(stored) || strings.Contains(stored, enroll.Secret) {
+		t.Fatalf("stored TOTP secret %q is not sealed", stored)
+	}
+
+	code, _ := totp.Code(enroll.Secret, totp.Step(time.Now()))
+	if _, err := mfa.ConfirmEnrollment(1, code, "10.0.0.1"); err != nil {
+		t.Fatalf("ConfirmEnrollment with the sealed secret: %v", err)
+	}
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"mws-ai/internal/auth/rbac"
+	"mws-ai/internal/dto"
+	"mws-ai/internal/models"
+	"mws-ai/internal/repository"
+	"mws-ai/pkg/logger"
+)
+
+var (
+	ErrOIDCInvalidState  = errors.New("invalid or expired oidc state")
+	ErrOIDCExchange      = errors.New("oidc code exchange failed")
+	ErrOIDCMissingClaim  = errors.New("id_token lacks a required claim")
+	ErrOIDCEmailConflict = errors.New("email belongs to an account that is not linked to this provider")
+)
+
+// OIDCLoginTTL bounds the time between the redirect and the callback
+const OIDCLoginTTL = 10 * time.Minute
+
+// OIDCProvider talks to the identity provider
+type OIDCProvider interface {
+	// AuthCodeURL builds the authorization endpoint URL for a PKCE (S256) login
+	AuthCodeURL(state, nonce, codeChallenge string) (string, error)
+	// Exchange redeems the code and returns the verified id_token claims
+	Exchange(code, codeVerifier, nonce string) (map[string]interface{}, error)
+}
+
+// OIDCConfig describes the provider and how its claims map onto users
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // optional: public clients rely on PKCE alone
+	RedirectURL  string
+	Scopes       []string
+
+	EmailClaim  string
+	GroupsClaim string
+
+	// GroupRoles maps provider groups to a role in OrganizationID;
+	// the most privileged matching group wins
+	GroupRoles     map[string]rbac.Role
+	OrganizationID uint
+}
+
+type OIDCService struct {
+	cfg      OIDCConfig
+	provider OIDCProvider
+	states   repository.OIDCStateRepository
+	users    repository.UserRepository
+	orgs     *OrganizationService
+	auth     *AuthService
+}
+
+func NewOIDCService(
+	cfg OIDCConfig,
+	provider OIDCProvider,
+	states repository.OIDCStateRepository,
+	users repository.UserRepository,
+	orgs *OrganizationService,
+	auth *AuthService,
+) *OIDCService {
+	return &OIDCService{
+		cfg:      cfg,
+		provider: provider,
+		states:   states,
+		users:    users,
+		orgs:     orgs,
+		auth:     auth,
+	}
+}
+
+// Begin starts a login: stores state, nonce and PKCE verifier and returns
+// the provider URL to redirect to together with the state value
+func (s *OIDCService) Begin() (string, string, error) {
+	var secrets [3]string
+	for i := range secrets {
+		t, err := randomToken()
+		if err != nil {
+			return "", "", err
+		}
+		secrets[i] = t
+	}
+	state, nonce, verifier := secrets[0], secrets[1], secrets[2]
+
+	now := time.Now()
+
+	if err := s.states.Create(&models.OIDCLoginState{
+		State:     state,
+		Nonce:     nonce,
+		Verifier:  verifier,
+		ExpiresAt: now.Add(OIDCLoginTTL),
+	}); err != nil {
+		return "", "", err
+	}
+
+	// брошенные логины копятся — чистим заодно
+	if err := s.states.DeleteExpired(now); err != nil {
+		logger.Log.Warn().
+			Str("service", "oidc").
+			Str("method", "Begin").
+			Err(err).
+			Msg("failed to purge expired login states")
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+
+	url, err := s.provider.AuthCodeURL(
+		state,
+		nonce,
+		base64.RawURLEncoding.EncodeToString(challenge[:]),
+	)
+	if err != nil {
+		return "", "", err
+	}
+
+	return url, state, nil
+}
+
+// Callback finishes a login: redeems the code, provisions or links the
+// user, applies the group role mapping and issues our own tokens
+func (s *OIDCService) Callback(state, code string) (*dto.AuthResponse, error) {
+	log := logger.Log.With().
+		Str("service", "oidc").
+		Str("method", "Callback").
+		Logger()
+
+	login, err := s.states.Consume(state)
+	if err != nil {
+		return nil, err
+	}
+	if login == nil || time.Now().After(login.ExpiresAt) {
+		log.Info().Msg("unknown or expired login state")
+		return nil, ErrOIDCInvalidState
+	}
+
+	claims, err := s.provider.Exchange(code, login.Verifier, login.Nonce)
+	if err != nil {
+		log.Warn().Err(err).Msg("code exchange failed")
+		return nil, ErrOIDCExchange
+	}
+
+	subject, _ := claims["sub"].(string)
+	email, _ := claims[s.cfg.EmailClaim].(string)
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	if subject == "" || email == "" {
+		log.Warn().
+			Str("email_claim", s.cfg.EmailClaim).
+			Msg("id_token without sub or email")
+		return nil, ErrOIDCMissingClaim
+	}
+
+	user, err := s.provision(subject, email, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	log = log.With().Uint("user_id", user.ID).Logger()
+
+	if role, ok := s.mapGroups(claims); ok {
+		if err := s.orgs.SyncMemberRole(s.cfg.OrganizationID, user.ID, role); err != nil {
+			log.Error().
+				Uint("org_id", s.cfg.OrganizationID).
+				Err(err).
+				Msg("failed to apply group role mapping")
+
+			return nil, err
+		}
+	}
+
+	resp, err := s.auth.completeLogin(user, providerDidMFA(claims))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Msg("oidc login successful")
+
+	return resp, nil
+}
+
+// provision finds the user linked to the identity, links an existing
+// account with the same verified email, or creates a new one
+func (s *OIDCService) provision(
+	subject string,
+	email string,
+	claims map[string]interface{},
+) (*models.User, error) {
+
+	log := logger.Log.With().
+		Str("service", "oidc").
+		Str("method", "provision").
+		Str("email", email).
+		Logger()
+
+	issuer := s.cfg.Issuer
+
+	user, err := s.users.FindByOIDCIdentity(issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		return user, nil
+	}
+
+	user, err = s.users.FindByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	if user != nil {
+		// привязываем только по подтверждённому email и только свободный аккаунт
+		verified, _ := claims["email_verified"].(bool)
+		if !verified || user.OIDCSubject != nil {
+			log.Warn().
+				Uint("user_id", user.ID).
+				Bool("email_verified", verified).
+				Msg("refusing to link existing account")
+
+			return nil, ErrOIDCEmailConflict
+		}
+
+		user.OIDCIssuer = &issuer
+		user.OIDCSubject = &subject
+		if user.EmailVerifiedAt == nil {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+
+		if err := s.users.Update(user); err != nil {
+			return nil, err
+		}
+
+		log.Info().
+			Uint("user_id", user.ID).
+			Msg("existing account linked to oidc identity")
+
+		return user, nil
+	}
+
+	user = &models.User{
+		Email:       email,
+		OIDCIssuer:  &issuer,
+		OIDCSubject: &subject,
+	}
+
+	// провайдер отвечает за адрес, если сам его подтвердил
+	if verified, _ := claims["email_verified"].(bool); verified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	if _, err := s.orgs.CreateWithUser(user); err != nil {
+		log.Error().
+			Err(err).
+			Msg("failed to provision user")
+
+		return nil, err
+	}
+
+	log.Info().
+		Uint("user_id", user.ID).
+		Msg("user provisioned from oidc")
+
+	return user, nil
+}
+
+// mapGroups picks the most privileged role among the user's groups
+func (s *OIDCService) mapGroups(claims map[string]interface{}) (rbac.Role, bool) {
+	if s.cfg.OrganizationID == 0 || len(s.cfg.GroupRoles) == 0 {
+		return "", false
+	}
+
+	var groups []string
+	switch v := claims[s.cfg.GroupsClaim].(type) {
+	case []interface{}:
+		for _, g := range v {
+			if str, ok := g.(string); ok {
+				groups = append(groups, str)
+			}
+		}
+	case string:
+		groups = strings.Fields(v)
+	}
+
+	var role rbac.Role
+	for _, g := range groups {
+		if r, ok := s.cfg.GroupRoles[g]; ok {
+			role = rbac.Max(role, r)
+		}
+	}
+
+	return role, role != ""
+}
+
+// providerDidMFA reads the amr claim (RFC 8176)
+func providerDidMFA(claims map[string]interface{}) bool {
+	amr, _ := claims["amr"].([]interface{})
+	for _, m := range amr {
+		if m == "mfa" {
+			return true
+		}
+	}
+	return false
+}
+
+// randomToken returns 32 random bytes, base64url encoded (43 chars,
+// a valid PKCE verifier)
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
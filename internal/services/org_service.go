@@ -0,0 +1,360 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"mws-ai/internal/auth/rbac"
+	"mws-ai/internal/models"
+	"mws-ai/internal/repository"
+	"mws-ai/pkg/logger"
+)
+
+var (
+	ErrOrgNotFound     = errors.New("organization not found")
+	ErrNotMember       = errors.New("user is not a member of the organization")
+	ErrInvalidRole     = errors.New("invalid role")
+	ErrLastAdmin       = errors.New("organization must keep at least one admin")
+	ErrMemberExists    = errors.New("user is already a member")
+	ErrUserNotFound    = errors.New("user not found")
+	ErrEmptyOrgName    = errors.New("organization name is required")
+	ErrNoOrganizations = errors.New("user has no organizations")
+	ErrMFARequired     = errors.New("organization requires two-factor authentication")
+)
+
+type OrganizationService struct {
+	orgs  repository.OrganizationRepository
+	users repository.UserRepository
+}
+
+func NewOrganizationService(
+	orgs repository.OrganizationRepository,
+	users repository.UserRepository,
+) *OrganizationService {
+	return &OrganizationService{
+		orgs:  orgs,
+		users: users,
+	}
+}
+
+// Create makes a new organization with userID as its admin
+func (s *OrganizationService) Create(userID uint, name string) (*models.Organization, error) {
+	log := logger.Log.With().
+		Str("service", "organization").
+		Str("method", "Create").
+		Uint("user_id", userID).
+		Logger()
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrEmptyOrgName
+	}
+
+	org := &models.Organization{Name: name}
+	owner := &models.Membership{
+		UserID: userID,
+		Role:   string(rbac.RoleAdmin),
+	}
+
+	if err := s.orgs.Create(org, owner); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Uint("org_id", org.ID).
+		Msg("organization created")
+
+	return org, nil
+}
+
+// CreateWithUser creates a new user and their personal organization,
+// where they are admin, atomically
+func (s *OrganizationService) CreateWithUser(user *models.User) (*models.Organization, error) {
+	org := &models.Organization{Name: user.Email}
+	owner := &models.Membership{Role: string(rbac.RoleAdmin)}
+
+	if err := s.orgs.CreateWithOwner(user, org, owner); err != nil {
+		return nil, err
+	}
+
+	logger.Log.Info().
+		Str("service", "organization").
+		Str("method", "CreateWithUser").
+		Uint("user_id", user.ID).
+		Uint("org_id", org.ID).
+		Msg("personal organization created")
+
+	return org, nil
+}
+
+func (s *OrganizationService) ListForUser(userID uint) ([]repository.OrgWithRole, error) {
+	return s.orgs.ListByUser(userID)
+}
+
+// ResolveActor determines the organization and effective role for a request.
+// orgID == 0 selects the user's default (oldest) organization.
+func (s *OrganizationService) ResolveActor(userID, orgID uint) (rbac.Actor, error) {
+	var (
+		m   *models.Membership
+		err error
+	)
+
+	if orgID == 0 {
+		m, err = s.orgs.FindDefaultMembership(userID)
+	} else {
+		m, err = s.orgs.FindMembership(orgID, userID)
+	}
+	if err != nil {
+		return rbac.Actor{}, err
+	}
+
+	if m == nil {
+		if orgID == 0 {
+			return rbac.Actor{}, ErrNoOrganizations
+		}
+		return rbac.Actor{}, ErrNotMember
+	}
+
+	return rbac.Actor{
+		UserID: userID,
+		OrgID:  m.OrganizationID,
+		Role:   rbac.Role(m.Role),
+	}, nil
+}
+
+// =====================
+// SETTINGS
+// =====================
+
+// RequiresMFA reports whether the organization enforces 2FA
+func (s *OrganizationService) RequiresMFA(orgID uint) (bool, error) {
+	org, err := s.orgs.FindByID(orgID)
+	if err != nil {
+		return false, err
+	}
+	if org == nil {
+		return false, ErrOrgNotFound
+	}
+	return org.RequireMFA, nil
+}
+
+// SetRequireMFA turns 2FA enforcement on or off. Turning it on needs a
+// 2FA session, so the admin can't lock themselves out.
+func (s *OrganizationService) SetRequireMFA(
+	actor rbac.Actor,
+	require bool,
+	sessionMFA bool,
+) (*models.Organization, error) {
+
+	if require && !sessionMFA {
+		return nil, ErrMFARequired
+	}
+
+	if err := s.orgs.UpdateRequireMFA(actor.OrgID, require); err != nil {
+		return nil, err
+	}
+
+	logger.Log.Info().
+		Str("service", "organization").
+		Str("method", "SetRequireMFA").
+		Uint("org_id", actor.OrgID).
+		Uint("actor_id", actor.UserID).
+		Bool("require_mfa", require).
+		Msg("mfa requirement updated")
+
+	return s.orgs.FindByID(actor.OrgID)
+}
+
+// =====================
+// MEMBERS
+// =====================
+
+func (s *OrganizationService) ListMembers(actor rbac.Actor) ([]repository.MemberWithEmail, error) {
+	return s.orgs.ListMembers(actor.OrgID)
+}
+
+func (s *OrganizationService) AddMember(
+	actor rbac.Actor,
+	email string,
+	role rbac.Role,
+) (*models.Membership, error) {
+
+	log := logger.Log.With().
+		Str("service", "organization").
+		Str("method", "AddMember").
+		Uint("org_id", actor.OrgID).
+		Uint("actor_id", actor.UserID).
+		Logger()
+
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+
+	user, err := s.users.FindByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	existing, err := s.orgs.FindMembership(actor.OrgID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrMemberExists
+	}
+
+	m := &models.Membership{
+		OrganizationID: actor.OrgID,
+		UserID:         user.ID,
+		Role:           string(role),
+	}
+
+	if err := s.orgs.AddMember(m); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Uint("user_id", user.ID).
+		Str("role", string(role)).
+		Msg("member added")
+
+	return m, nil
+}
+
+func (s *OrganizationService) UpdateMemberRole(
+	actor rbac.Actor,
+	userID uint,
+	role rbac.Role,
+) error {
+
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+
+	m, err := s.orgs.FindMembership(actor.OrgID, userID)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return ErrNotMember
+	}
+
+	if rbac.Role(m.Role) == rbac.RoleAdmin && role != rbac.RoleAdmin {
+		if err := s.ensureAnotherAdmin(actor.OrgID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.orgs.UpdateMemberRole(actor.OrgID, userID, string(role)); err != nil {
+		return err
+	}
+
+	logger.Log.Info().
+		Str("service", "organization").
+		Str("method", "UpdateMemberRole").
+		Uint("org_id", actor.OrgID).
+		Uint("actor_id", actor.UserID).
+		Uint("user_id", userID).
+		Str("role", string(role)).
+		Msg("member role updated")
+
+	return nil
+}
+
+func (s *OrganizationService) RemoveMember(actor rbac.Actor, userID uint) error {
+	m, err := s.orgs.FindMembership(actor.OrgID, userID)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return ErrNotMember
+	}
+
+	if rbac.Role(m.Role) == rbac.RoleAdmin {
+		if err := s.ensureAnotherAdmin(actor.OrgID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.orgs.RemoveMember(actor.OrgID, userID); err != nil {
+		return err
+	}
+
+	logger.Log.Info().
+		Str("service", "organization").
+		Str("method", "RemoveMember").
+		Uint("org_id", actor.OrgID).
+		Uint("actor_id", actor.UserID).
+		Uint("user_id", userID).
+		Msg("member removed")
+
+	return nil
+}
+
+// SyncMemberRole makes userID a member of orgID with the given role,
+// adding the membership or updating its role as needed. Used to apply
+// roles coming from an external identity provider.
+func (s *OrganizationService) SyncMemberRole(orgID, userID uint, role rbac.Role) error {
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+
+	org, err := s.orgs.FindByID(orgID)
+	if err != nil {
+		return err
+	}
+	if org == nil {
+		return ErrOrgNotFound
+	}
+
+	m, err := s.orgs.FindMembership(orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	if m == nil {
+		return s.orgs.AddMember(&models.Membership{
+			OrganizationID: orgID,
+			UserID:         userID,
+			Role:           string(role),
+		})
+	}
+
+	if rbac.Role(m.Role) == role {
+		return nil
+	}
+
+	if rbac.Role(m.Role) == rbac.RoleAdmin {
+		if err := s.ensureAnotherAdmin(orgID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.orgs.UpdateMemberRole(orgID, userID, string(role)); err != nil {
+		return err
+	}
+
+	logger.Log.Info().
+		Str("service", "organization").
+		Str("method", "SyncMemberRole").
+		Uint("org_id", orgID).
+		Uint("user_id", userID).
+		Str("role", string(role)).
+		Msg("member role synced")
+
+	return nil
+}
+
+func (s *OrganizationService) ensureAnotherAdmin(orgID uint) error {
+	admins, err := s.orgs.CountAdmins(orgID)
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
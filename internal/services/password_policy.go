@@ -0,0 +1,73 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+// bcrypt ignores (newer versions reject) anything past 72 bytes
+const maxPasswordBytes = 72
+
+// PasswordPolicy describes password strength requirements
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// Validate returns ErrWeakPassword wrapped with every unmet rule
+func (p PasswordPolicy) Validate(password, email string) error {
+	var problems []string
+
+	if len([]rune(password)) < p.MinLength {
+		problems = append(problems, fmt.Sprintf("at least %d characters", p.MinLength))
+	}
+	if len(password) > maxPasswordBytes {
+		problems = append(problems, fmt.Sprintf("at most %d bytes", maxPasswordBytes))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+
+	if p.RequireUpper && !upper {
+		problems = append(problems, "an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		problems = append(problems, "a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		problems = append(problems, "a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		problems = append(problems, "a symbol")
+	}
+
+	// пароль не должен повторять логин
+	local, _, _ := strings.Cut(strings.ToLower(email), "@")
+	if len(local) >= 3 && strings.Contains(strings.ToLower(password), local) {
+		problems = append(problems, "not contain the email name")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: must have %s", ErrWeakPassword, strings.Join(problems, ", "))
+	}
+
+	return nil
+}
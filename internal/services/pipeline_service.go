@@ -4,8 +4,14 @@ import (
 	"errors"
 	"mws-ai/internal/models"
 	"mws-ai/pkg/logger"
+	"mws-ai/pkg/secretformat"
 )
 
+// Suppressor returns the matching suppression rule id per finding id
+type Suppressor interface {
+	Suppress(orgID uint, findings []*models.Finding) (map[uint]uint, error)
+}
+
 // Interfaces clients
 type HeuristicClient interface {
 	AnalyzeBatch(findings []*models.Finding) (map[uint]*HeuristicFacts, error)
@@ -40,32 +46,36 @@ type LLMResult struct {
 
 // PipelineExecutor
 type PipelineExecutor interface {
-	Process(findings []*models.Finding) error
+	Process(orgID uint, findings []*models.Finding) error
 }
 
 // Realisation
 type pipelineExecutor struct {
-	heuristic HeuristicClient
-	ml        MLClient
-	llm       LLMClient
+	suppressor Suppressor
+	heuristic  HeuristicClient
+	ml         MLClient
+	llm        LLMClient
 }
 
 func NewPipelineExecutor(
+	suppressor Suppressor,
 	heuristic HeuristicClient,
 	ml MLClient,
 	llm LLMClient,
 ) PipelineExecutor {
 	return &pipelineExecutor{
-		heuristic: heuristic,
-		ml:        ml,
-		llm:       llm,
+		suppressor: suppressor,
+		heuristic:  heuristic,
+		ml:         ml,
+		llm:        llm,
 	}
 }
 
 // MAIN PIPELINE ENTRYPOINT
-func (p *pipelineExecutor) Process(findings []*models.Finding) error {
+func (p *pipelineExecutor) Process(orgID uint, findings []*models.Finding) error {
 	log := logger.Log.With().
 		Str("service", "pipeline").
+		Uint("org_id", orgID).
 		Logger()
 
 	log.Info().Msg("pipeline started")
@@ -74,15 +84,56 @@ func (p *pipelineExecutor) Process(findings []*models.Finding) error {
 		return nil
 	}
 
+	// 0. SUPPRESSION — известные безопасные значения дальше не идут
+	suppressed, err := p.suppressor.Suppress(orgID, findings)
+	if err != nil {
+		return err
+	}
+
+	toHeuristic := make([]*models.Finding, 0, len(findings))
+	ignored := 0
+	for _, f := range findings {
+		if ruleID, ok := suppressed[f.ID]; ok {
+			final := "FP"
+			f.FinalVerdict = &final
+			f.DecisionSource = "suppression"
+			f.SuppressionRuleID = &ruleID
+			continue
+		}
+
+		// разработчик сам пометил значение комментарием в коде
+		if comment, ok := inlineIgnore(f); ok {
+			final := "FP"
+			f.FinalVerdict = &final
+			f.DecisionSource = "inline_ignore"
+			f.SuppressionComment = &comment
+			ignored++
+			continue
+		}
+
+		toHeuristic = append(toHeuristic, f)
+	}
+
+	if len(suppressed) > 0 || ignored > 0 {
+		log.Info().
+			Int("suppressed", len(suppressed)).
+			Int("inline_ignored", ignored).
+			Msg("findings suppressed")
+	}
+	if len(toHeuristic) == 0 {
+		log.Info().Msg("pipeline finished")
+		return nil
+	}
+
 	// 1. HEURISTIC (NEW)
-	heuristicResults, err := p.heuristic.AnalyzeBatch(findings)
+	heuristicResults, err := p.heuristic.AnalyzeBatch(toHeuristic)
 	if err != nil {
 		return err
 	}
 
-	toML := make([]*models.Finding, 0)
+	toValidate := make([]*models.Finding, 0)
 
-	for _, f := range findings {
+	for _, f := range toHeuristic {
 		h, ok := heuristicResults[f.ID]
 		if !ok {
 			continue
@@ -110,7 +161,35 @@ func (p *pipelineExecutor) Process(findings []*models.Finding) error {
 		}
 
 		// идём дальше в ML
-		toML = append(toML, f)
+		toValidate = append(toValidate, f)
+	}
+
+	// 1.5. FORMAT VALIDATOR — структуру известных провайдеров можно проверить точно
+	toML := make([]*models.Finding, 0, len(toValidate))
+
+	for _, f := range toValidate {
+		res := secretformat.Check(f.Value)
+		if res.Kind == "" {
+			toML = append(toML, f)
+			continue
+		}
+
+		reason := res.Kind + ": " + res.Reason
+		f.FormatValidation = &reason
+
+		// решаем сами только по контрольной сумме или невозможной структуре
+		if res.Verdict == secretformat.Unknown {
+			toML = append(toML, f)
+			continue
+		}
+
+		final := "TP"
+		if res.Verdict == secretformat.Invalid {
+			final = "FP"
+		}
+
+		f.FinalVerdict = &final
+		f.DecisionSource = "format_validator"
 	}
 
 	// 2. ML
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"time"
+
+	"mws-ai/internal/repository"
+	"mws-ai/pkg/blob"
+	"mws-ai/pkg/logger"
+)
+
+// purgeBatch bounds how many reports one pass loads at a time
+const purgeBatch = 100
+
+// ReportRetention deletes raw reports from blob storage once they are
+// older than maxAge. Analyses and findings are kept; only the uploaded
+// file goes away.
+type ReportRetention struct {
+	analyses repository.AnalysisRepository
+	blobs    blob.Store
+	maxAge   time.Duration
+}
+
+func NewReportRetention(
+	analyses repository.AnalysisRepository,
+	blobs blob.Store,
+	maxAge time.Duration,
+) *ReportRetention {
+	return &ReportRetention{
+		analyses: analyses,
+		blobs:    blobs,
+		maxAge:   maxAge,
+	}
+}
+
+// Purge removes every report uploaded before now - maxAge and returns
+// how many were deleted
+func (r *ReportRetention) Purge(now time.Time) (int, error) {
+	log := logger.Log.With().
+		Str("service", "retention").
+		Str("method", "Purge").
+		Logger()
+
+	cutoff := now.Add(-r.maxAge)
+	purged, failed := 0, 0
+
+	// курсор по id: отчёты, которые не удалось удалить, остаются в выборке
+	var after uint
+	for {
+		expired, err := r.analyses.ListExpiredReports(cutoff, after, purgeBatch)
+		if err != nil {
+			return purged, err
+		}
+
+		for _, a := range expired {
+			after = a.ID
+
+			if err := r.blobs.Delete(a.StorageKey); err != nil {
+				// не помечаем — следующий проход попробует снова,
+				// а остальные отчёты удаляем уже сейчас
+				log.Error().
+					Uint("analysis_id", a.ID).
+					Str("storage_key", a.StorageKey).
+					Err(err).
+					Msg("failed to delete raw report, skipping")
+
+				failed++
+				continue
+			}
+
+			if err := r.analyses.MarkReportPurged(a.ID, now); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+
+		if len(expired) < purgeBatch {
+			break
+		}
+	}
+
+	if purged > 0 || failed > 0 {
+		log.Info().
+			Int("purged", purged).
+			Int("failed", failed).
+			Time("cutoff", cutoff).
+			Msg("raw reports purged")
+	}
+
+	return purged, nil
+}
+
+// Start runs Purge every interval until stop is closed. A zero maxAge
+// keeps reports forever.
+func (r *ReportRetention) Start(interval time.Duration, stop <-chan struct{}) {
+	if r.maxAge <= 0 || interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			// первый проход сразу после старта
+			if _, err := r.Purge(time.Now()); err != nil {
+				logger.Log.Error().
+					Str("service", "retention").
+					Err(err).
+					Msg("report retention pass failed")
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"mws-ai/internal/models"
+)
+
+// fakeBlobs fails Delete for the keys in broken and every Put with putErr
+type fakeBlobs struct {
+	deleted []string
+	broken  map[string]bool
+	putErr  error
+}
+
+func (b *fakeBlobs) Put(string, io.Reader, int64) error { return b.putErr }
+
+func (b *fakeBlobs) Open(string) (io.ReadCloser, error) { return nil, errors.New("not implemented") }
+
+func (b *fakeBlobs) Delete(key string) error {
+	if b.broken[key] {
+		return errors.New("access denied")
+	}
+	b.deleted = append(b.deleted, key)
+	return nil
+}
+
+func TestRetentionPurgeSkipsFailedDeletes(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-40 * 24 * time.Hour)
+
+	analyses := &fakeAnalysisRepo{analyses: map[uint]*models.Analysis{}}
+	broken := map[string]bool{}
+
+	// больше одной пачки, и сломанные ключи в каждой — курсор не должен зациклиться
+	const total = purgeBatch*2 + 10
+	for id := uint(1); id <= total; id++ {
+		key := fmt.Sprintf("reports/%d.sarif", id)
+		analyses.analyses[id] = &models.Analysis{ID: id, StorageKey: key, UploadedAt: old}
+		if id%50 == 1 {
+			broken[key] = true
+		}
+	}
+	analyses.analyses[total+1] = &models.Analysis{ID: total + 1, StorageKey: "reports/new.sarif", UploadedAt: now}
+
+	blobs := &fakeBlobs{broken: broken}
+	r := NewReportRetention(analyses, blobs, 30*24*time.Hour)
+
+	purged, err := r.Purge(now)
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+
+	if want := total - len(broken); purged != want {
+		t.Fatalf("purged = %d, want %d", purged, want)
+	}
+	for id, a := range analyses.analyses {
+		switch {
+		case broken[fmt.Sprintf("reports/%d.sarif", id)]:
+			if a.StorageKey == "" || a.ReportPurgedAt != nil {
+				t.Errorf("analysis %d marked purged though its delete failed", id)
+			}
+		case id == total+1:
+			if a.StorageKey == "" {
+				t.Error("fresh report purged")
+			}
+		default:
+			if a.StorageKey != "" || a.ReportPurgedAt == nil {
+				t.Errorf("analysis %d not purged", id)
+			}
+		}
+	}
+
+	// следующий проход повторяет только сломанные
+	blobs.broken = nil
+	purged, err = r.Purge(now)
+	if err != nil || purged != len(broken) {
+		t.Fatalf("second Purge = %d, %v, want %d", purged, err, len(broken))
+	}
+}
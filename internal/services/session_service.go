@@ -0,0 +1,141 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+
+	"mws-ai/internal/models"
+	"mws-ai/internal/repository"
+	"mws-ai/pkg/jwt"
+	"mws-ai/pkg/logger"
+)
+
+var (
+	ErrTokenRevoked  = errors.New("token revoked")
+	ErrTokenOutdated = errors.New("token issued before logout")
+)
+
+// SessionService verifies access tokens against server-side state and
+// ends sessions: single tokens via a jti deny-list, all of a user's
+// sessions via the token version embedded in claims.
+type SessionService struct {
+	jwt           *jwt.JWTManager
+	users         repository.UserRepository
+	revoked       repository.RevokedTokenRepository
+	refreshTokens repository.RefreshTokenRepository
+}
+
+func NewSessionService(
+	jwt *jwt.JWTManager,
+	users repository.UserRepository,
+	revoked repository.RevokedTokenRepository,
+	refreshTokens repository.RefreshTokenRepository,
+) *SessionService {
+	return &SessionService{
+		jwt:           jwt,
+		users:         users,
+		revoked:       revoked,
+		refreshTokens: refreshTokens,
+	}
+}
+
+// Authenticate parses an access token and checks it was not revoked.
+// Returns the token claims and user id.
+func (s *SessionService) Authenticate(tokenStr string) (gojwt.MapClaims, uint, error) {
+	claims, err := s.jwt.ParseAccess(tokenStr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	userID, ok := jwt.UserID(claims)
+	if !ok {
+		return nil, 0, jwt.ErrInvalidToken
+	}
+
+	if jti, _ := claims["jti"].(string); jti != "" {
+		revoked, err := s.revoked.IsRevoked(jti)
+		if err != nil {
+			return nil, 0, err
+		}
+		if revoked {
+			return nil, 0, ErrTokenRevoked
+		}
+	}
+
+	user, err := s.users.FindByID(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if user == nil {
+		return nil, 0, jwt.ErrInvalidToken
+	}
+	if jwt.Version(claims) != user.TokenVersion {
+		return nil, 0, ErrTokenOutdated
+	}
+
+	return claims, userID, nil
+}
+
+// Logout revokes the presented access token and, if given, the refresh
+// token family it belongs to
+func (s *SessionService) Logout(claims gojwt.MapClaims, refreshToken string) error {
+	log := logger.Log.With().
+		Str("service", "session").
+		Str("method", "Logout").
+		Logger()
+
+	userID, _ := jwt.UserID(claims)
+	now := time.Now()
+
+	if jti, _ := claims["jti"].(string); jti != "" {
+		if err := s.revoked.Create(&models.RevokedToken{
+			JTI:       jti,
+			UserID:    userID,
+			ExpiresAt: jwt.ExpiresAt(claims),
+		}); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken != "" {
+		refreshClaims, err := s.jwt.ParseRefresh(refreshToken)
+		if err == nil {
+			if rid, _ := jwt.UserID(refreshClaims); rid == userID {
+				fam, _ := refreshClaims["fam"].(string)
+				if err := s.refreshTokens.RevokeFamily(fam, now); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// заодно чистим просроченные записи, чтобы deny-list не рос
+	_ = s.revoked.DeleteExpired(now)
+
+	log.Info().
+		Uint("user_id", userID).
+		Msg("session logged out")
+
+	return nil
+}
+
+// LogoutAll invalidates every access and refresh token of the user
+func (s *SessionService) LogoutAll(userID uint) error {
+	if err := s.users.IncrementTokenVersion(userID); err != nil {
+		return err
+	}
+
+	if err := s.refreshTokens.RevokeAllForUser(userID, time.Now()); err != nil {
+		return err
+	}
+
+	logger.Log.Info().
+		Str("service", "session").
+		Str("method", "LogoutAll").
+		Uint("user_id", userID).
+		Msg("all sessions logged out")
+
+	return nil
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"mws-ai/internal/auth/rbac"
+	"mws-ai/internal/models"
+	"mws-ai/internal/repository"
+	"mws-ai/pkg/logger"
+)
+
+var (
+	ErrSuppressionNotFound = errors.New("suppression rule not found")
+	ErrInvalidSuppression  = errors.New("invalid suppression rule")
+)
+
+// Audit events
+const (
+	AuditSuppressionCreated = "suppression.created"
+	AuditSuppressionUpdated = "suppression.updated"
+	AuditSuppressionDeleted = "suppression.deleted"
+)
+
+const (
+	maxSuppressionPattern = 512
+	maxSuppressionReason  = 500
+)
+
+var fingerprintPattern = regexp.MustCompile(`^[0-9a-f]{8,64}$`)
+
+type SuppressionService struct {
+	repo  repository.SuppressionRepository
+	audit *AuditService
+}
+
+func NewSuppressionService(
+	repo repository.SuppressionRepository,
+	audit *AuditService,
+) *SuppressionService {
+	return &SuppressionService{repo: repo, audit: audit}
+}
+
+// SuppressionInput describes a rule to create or replace
+type SuppressionInput struct {
+	PathGlob    string
+	RuleID      string
+	ValueRegex  string
+	Fingerprint string
+	Reason      string
+	ExpiresAt   *time.Time
+}
+
+// =====================
+// CRUD
+// =====================
+
+func (s *SuppressionService) List(actor rbac.Actor) ([]models.SuppressionRule, error) {
+	return s.repo.ListByOrganization(actor.OrgID)
+}
+
+func (s *SuppressionService) Create(
+	actor rbac.Actor,
+	in SuppressionInput,
+	ip string,
+) (*models.SuppressionRule, error) {
+
+	rule := &models.SuppressionRule{
+		OrganizationID: actor.OrgID,
+		CreatedByID:    actor.UserID,
+	}
+	if err := applyInput(rule, in); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(rule); err != nil {
+		return nil, err
+	}
+
+	s.record(actor, AuditSuppressionCreated, rule, ip)
+	return rule, nil
+}
+
+func (s *SuppressionService) Update(
+	actor rbac.Actor,
+	id uint,
+	in SuppressionInput,
+	ip string,
+) (*models.SuppressionRule, error) {
+
+	rule, err := s.findOwned(actor, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyInput(rule, in); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(rule); err != nil {
+		return nil, err
+	}
+
+	s.record(actor, AuditSuppressionUpdated, rule, ip)
+	return rule, nil
+}
+
+func (s *SuppressionService) Delete(actor rbac.Actor, id uint, ip string) error {
+	rule, err := s.findOwned(actor, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(rule.ID); err != nil {
+		return err
+	}
+
+	s.record(actor, AuditSuppressionDeleted, rule, ip)
+	return nil
+}
+
+func (s *SuppressionService) findOwned(actor rbac.Actor, id uint) (*models.SuppressionRule, error) {
+	rule, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil || rule.OrganizationID != actor.OrgID {
+		return nil, ErrSuppressionNotFound
+	}
+	return rule, nil
+}
+
+// record audits changes: a suppression silently hides real secrets if
+// it is too broad, so who added it and why must be traceable
+func (s *SuppressionService) record(actor rbac.Actor, event string, rule *models.SuppressionRule, ip string) {
+	s.audit.Record(&models.AuditLog{
+		Event:          event,
+		UserID:         &actor.UserID,
+		OrganizationID: &actor.OrgID,
+		IP:             ip,
+		Subject:        rule.Reason,
+		Details: map[string]interface{}{
+			"rule_id":         rule.ID,
+			"path_glob":       rule.PathGlob,
+			"scanner_rule_id": rule.RuleID,
+			"value_regex":     rule.ValueRegex,
+			"fingerprint":     rule.Fingerprint,
+			"expires_at":      rule.ExpiresAt,
+			"api_key_id":      actor.APIKeyID,
+		},
+	})
+}
+
+// applyInput validates the input and copies it onto the rule
+func applyInput(rule *models.SuppressionRule, in SuppressionInput) error {
+	in.PathGlob = strings.TrimSpace(in.PathGlob)
+	in.RuleID = strings.TrimSpace(in.RuleID)
+	in.Fingerprint = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(in.Fingerprint), "sha256:"))
+	in.Reason = strings.TrimSpace(in.Reason)
+
+	switch {
+	case in.PathGlob == "" && in.RuleID == "" && in.ValueRegex == "" && in.Fingerprint == "":
+		return invalidSuppression("at least one of path_glob, rule_id, value_regex, fingerprint is required")
+	case in.Reason == "":
+		return invalidSuppression("reason is required")
+	case len(in.Reason) > maxSuppressionReason:
+		return invalidSuppression("reason is too long")
+	case len(in.PathGlob) > maxSuppressionPattern || len(in.RuleID) > maxSuppressionPattern ||
+		len(in.ValueRegex) > maxSuppressionPattern:
+		return invalidSuppression("patterns must not exceed 512 characters")
+	case in.Fingerprint != "" && !fingerprintPattern.MatchString(in.Fingerprint):
+		return invalidSuppression("fingerprint must be 8 to 64 hex characters of the value's sha256")
+	case in.ExpiresAt != nil && !in.ExpiresAt.After(time.Now()):
+		return invalidSuppression("expires_at must be in the future")
+	}
+
+	if in.ValueRegex != "" {
+		if _, err := regexp.Compile(in.ValueRegex); err != nil {
+			return invalidSuppression("value_regex: " + err.Error())
+		}
+	}
+
+	rule.PathGlob = in.PathGlob
+	rule.RuleID = in.RuleID
+	rule.ValueRegex = in.ValueRegex
+	rule.Fingerprint = in.Fingerprint
+	rule.Reason = in.Reason
+	rule.ExpiresAt = in.ExpiresAt
+
+	return nil
+}
+
+func invalidSuppression(msg string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidSuppression, msg)
+}
+
+// =====================
+// MATCHING
+// =====================
+
+// Suppress matches findings against the organization's active rules and
+// returns the id of the first matching rule per finding id
+func (s *SuppressionService) Suppress(orgID uint, findings []*models.Finding) (map[uint]uint, error) {
+	rules, err := s.repo.ListActive(orgID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[uint]uint{}
+	if len(rules) == 0 {
+		return out, nil
+	}
+
+	matchers := make([]suppressionMatcher, 0, len(rules))
+	for i := range rules {
+		m, err := compileRule(&rules[i])
+		if err != nil {
+			// правила проверяются при сохранении; сюда попадёт только испорченная запись
+			logger.Log.Warn().
+				Str("service", "suppression").
+				Uint("rule_id", rules[i].ID).
+				Err(err).
+				Msg("skipping broken suppression rule")
+			continue
+		}
+		matchers = append(matchers, m)
+	}
+
+	for _, f := range findings {
+		for _, m := range matchers {
+			if m.match(f) {
+				out[f.ID] = m.id
+				break
+			}
+		}
+	}
+
+	return out, nil
+}
+
+type suppressionMatcher struct {
+	id          uint
+	path        *regexp.Regexp
+	ruleID      string
+	value       *regexp.Regexp
+	fingerprint string
+}
+
+func compileRule(rule *models.SuppressionRule) (suppressionMatcher, error) {
+	m := suppressionMatcher{
+		id:          rule.ID,
+		ruleID:      rule.RuleID,
+		fingerprint: rule.Fingerprint,
+	}
+
+	if rule.PathGlob != "" {
+		re, err := regexp.Compile(globToRegexp(rule.PathGlob))
+		if err != nil {
+			return m, err
+		}
+		m.path = re
+	}
+	if rule.ValueRegex != "" {
+		re, err := regexp.Compile(rule.ValueRegex)
+		if err != nil {
+			return m, err
+		}
+		m.value = re
+	}
+
+	return m, nil
+}
+
+func (m suppressionMatcher) match(f *models.Finding) bool {
+	if m.path != nil && !m.path.MatchString(normalizePath(f.FilePath)) {
+		return false
+	}
+	if m.ruleID != "" && !strings.EqualFold(m.ruleID, f.RuleID) {
+		return false
+	}
+	if m.value != nil && !m.value.MatchString(f.Value) {
+		return false
+	}
+	if m.fingerprint != "" {
+		sum := sha256.Sum256([]byte(f.Value))
+		if !strings.HasPrefix(hex.EncodeToString(sum[:]), m.fingerprint) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizePath(p string) string {
+	p = strings.TrimPrefix(p, "file://")
+	p = strings.ReplaceAll(p, "\\", "/")
+	return strings.TrimPrefix(p, "./")
+}
+
+// globToRegexp supports *, ? and ** ("any number of directories").
+// The glob is anchored at a directory boundary, not at the root: SARIF
+// paths are often absolute on the CI runner, so "src/**" has to match
+// "/home/runner/work/repo/src/main.go".
+func globToRegexp(glob string) string {
+	glob = strings.TrimPrefix(normalizePath(glob), "/")
+
+	var b strings.Builder
+	b.WriteString("(?:^|/)")
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteString("$")
+	return b.String()
+}
@@ -0,0 +1,328 @@
+// Package source pulls the lines around a finding out of a source archive
+// uploaded together with the report.
+package source
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrUnsupportedFormat = errors.New("source archive must be zip or tar.gz")
+
+// maxLineLen trims minified and generated lines
+const maxLineLen = 400
+
+// Limits bound the work spent on one archive
+type Limits struct {
+	// MaxFileSize skips source files larger than this
+	MaxFileSize int64
+	// MaxUnpacked caps the total decompressed bytes read from a tar.gz
+	MaxUnpacked int64
+}
+
+// Want is a location a snippet is needed for
+type Want struct {
+	Path    string
+	Line    int
+	LineEnd int
+}
+
+// Snippet is a window of source lines; StartLine is the number of its
+// first line. A zero Snippet means the file was not found.
+type Snippet struct {
+	Text      string
+	StartLine int
+}
+
+// IsArchive tells whether head starts a supported archive
+func IsArchive(head []byte) bool {
+	return isZip(head) || isGzip(head)
+}
+
+// Extract returns a snippet of radius lines around every wanted location,
+// index-aligned with wants
+func Extract(archivePath string, wants []Want, radius int, limits Limits) ([]Snippet, error) {
+	head, err := readHead(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var a archive
+	switch {
+	case isZip(head):
+		a = &zipArchive{path: archivePath}
+	case isGzip(head):
+		a = &tarArchive{path: archivePath, limits: limits}
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	// первый проход: только имена, чтобы выбрать лучший файл для каждого пути
+	names, err := a.names()
+	if err != nil {
+		return nil, err
+	}
+
+	chosen := map[string]bool{}
+	entries := make([]string, len(wants))
+	for i, w := range wants {
+		entries[i] = bestMatch(clean(w.Path), names)
+		if entries[i] != "" {
+			chosen[entries[i]] = true
+		}
+	}
+
+	// второй проход: читаем только выбранные файлы
+	files, err := a.read(chosen, limits.MaxFileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Snippet, len(wants))
+	for i, w := range wants {
+		data, ok := files[entries[i]]
+		if !ok {
+			continue
+		}
+		out[i] = window(data, w.Line, w.LineEnd, radius)
+	}
+
+	return out, nil
+}
+
+// window cuts lines [line-radius, max(line, lineEnd)+radius]
+func window(data []byte, line, lineEnd, radius int) Snippet {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+	last := max(line, lineEnd)
+	if line < 1 || line > len(lines) {
+		return Snippet{}
+	}
+
+	from := max(1, line-radius)
+	to := min(len(lines), last+radius)
+
+	picked := make([]string, 0, to-from+1)
+	for _, l := range lines[from-1 : to] {
+		if utf8.RuneCountInString(l) > maxLineLen {
+			l = string([]rune(l)[:maxLineLen]) + "…"
+		}
+		picked = append(picked, l)
+	}
+
+	return Snippet{Text: strings.Join(picked, "\n"), StartLine: from}
+}
+
+// =====================
+// PATH MATCHING
+// =====================
+
+// clean brings SARIF URIs and archive names to one form
+func clean(p string) string {
+	p = strings.TrimPrefix(p, "file://")
+	p = strings.ReplaceAll(p, "\\", "/")
+	p = path.Clean("/" + p)
+	return strings.TrimPrefix(p, "/")
+}
+
+// bestMatch picks the archive entry sharing the longest path suffix with
+// want. Archives usually have a top-level directory ("repo-main/") and
+// SARIF paths are often absolute on the CI runner, so exact matches are
+// rare; a bare file name only matches when nothing longer does and it
+// is unambiguous.
+func bestMatch(want string, names []string) string {
+	wantParts := strings.Split(want, "/")
+
+	best, bestScore, ties := "", 0, 0
+	for _, name := range names {
+		score := commonSuffix(wantParts, strings.Split(name, "/"))
+		switch {
+		case score > bestScore:
+			best, bestScore, ties = name, score, 0
+		case score == bestScore && score > 0:
+			ties++
+		}
+	}
+
+	if bestScore == 1 && ties > 0 {
+		return ""
+	}
+	return best
+}
+
+func commonSuffix(a, b []string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return n
+}
+
+// =====================
+// ARCHIVES
+// =====================
+
+type archive interface {
+	names() ([]string, error)
+	read(chosen map[string]bool, maxFileSize int64) (map[string][]byte, error)
+}
+
+type zipArchive struct {
+	path string
+}
+
+func (z *zipArchive) names() ([]string, error) {
+	zr, err := zip.OpenReader(z.path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	names := make([]string, 0, len(zr.File))
+	for _, f := range zr.File {
+		if !f.FileInfo().IsDir() {
+			names = append(names, clean(f.Name))
+		}
+	}
+	return names, nil
+}
+
+func (z *zipArchive) read(chosen map[string]bool, maxFileSize int64) (map[string][]byte, error) {
+	zr, err := zip.OpenReader(z.path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		name := clean(f.Name)
+		if !chosen[name] || f.UncompressedSize64 > uint64(maxFileSize) {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, maxFileSize+1))
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		// размер в заголовке мог соврать
+		if int64(len(data)) > maxFileSize {
+			continue
+		}
+		files[name] = data
+	}
+	return files, nil
+}
+
+type tarArchive struct {
+	path   string
+	limits Limits
+}
+
+func (t *tarArchive) names() ([]string, error) {
+	names := make([]string, 0)
+	err := t.walk(func(h *tar.Header, _ io.Reader) error {
+		names = append(names, clean(h.Name))
+		return nil
+	})
+	return names, err
+}
+
+func (t *tarArchive) read(chosen map[string]bool, maxFileSize int64) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	err := t.walk(func(h *tar.Header, r io.Reader) error {
+		name := clean(h.Name)
+		if !chosen[name] || h.Size > maxFileSize {
+			return nil
+		}
+
+		data, err := io.ReadAll(io.LimitReader(r, maxFileSize))
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		files[name] = data
+		return nil
+	})
+	return files, err
+}
+
+// walk visits regular files; the whole decompressed stream, including
+// skipped entries, counts against MaxUnpacked
+func (t *tarArchive) walk(visit func(h *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(io.LimitReader(gz, t.limits.MaxUnpacked))
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			// обрезано лимитом — работаем с тем, что успели прочитать
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return err
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := visit(h, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// =====================
+// HELPERS
+// =====================
+
+func readHead(p string) ([]byte, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 4)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+func isZip(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("PK\x03\x04"))
+}
+
+func isGzip(head []byte) bool {
+	return bytes.HasPrefix(head, []byte{0x1f, 0x8b})
+}
@@ -0,0 +1,35 @@
+// Package blob stores opaque files (raw reports) under slash-separated
+// keys, on the local disk or in an S3-compatible bucket.
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Store is implemented by LocalStore and S3Store
+type Store interface {
+	// Put writes size bytes from r under key, replacing any existing blob
+	Put(key string, r io.Reader, size int64) error
+	// Open returns the blob contents or ErrNotFound
+	Open(key string) (io.ReadCloser, error)
+	// Delete removes the blob; a missing blob is not an error
+	Delete(key string) error
+}
+
+// validateKey rejects keys that could escape the store root
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package blob
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"mws-ai/pkg/envelope"
+)
+
+// SealedStore encrypts blob contents with the envelope keyring before
+// they reach the underlying store. Blobs written before encryption was
+// turned on are returned as they are.
+type SealedStore struct {
+	store Store
+	keys  *envelope.Keyring
+}
+
+func NewSealedStore(store Store, keys *envelope.Keyring) *SealedStore {
+	return &SealedStore{store: store, keys: keys}
+}
+
+func (s *SealedStore) Put(key string, r io.Reader, size int64) error {
+	data, err := io.ReadAll(io.LimitReader(r, size+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return fmt.Errorf("blob %s: read %d bytes, expected %d", key, len(data), size)
+	}
+
+	sealed, err := s.keys.Seal(string(data))
+	if err != nil {
+		return fmt.Errorf("seal blob %s: %w", key, err)
+	}
+
+	return s.store.Put(key, strings.NewReader(sealed), int64(len(sealed)))
+}
+
+func (s *SealedStore) Open(key string) (io.ReadCloser, error) {
+	data, err := s.read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := s.keys.Open(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("open blob %s: %w", key, err)
+	}
+
+	return io.NopCloser(strings.NewReader(plaintext)), nil
+}
+
+func (s *SealedStore) Delete(key string) error {
+	return s.store.Delete(key)
+}
+
+// NeedsRewrap reports whether the blob is plaintext or sealed with a
+// key other than the primary one
+func (s *SealedStore) NeedsRewrap(key string) (bool, error) {
+	data, err := s.read(key)
+	if err != nil {
+		return false, err
+	}
+	return !s.current(data), nil
+}
+
+// Rewrap moves the blob to the primary key; plaintext blobs are sealed.
+// It reports whether the blob was rewritten.
+func (s *SealedStore) Rewrap(key string) (bool, error) {
+	data, err := s.read(key)
+	if err != nil {
+		return false, err
+	}
+
+	if s.current(data) {
+		return false, nil
+	}
+
+	sealed, err := s.keys.Rewrap(string(data))
+	if err != nil {
+		return false, fmt.Errorf("rewrap blob %s: %w", key, err)
+	}
+
+	if err := s.store.Put(key, strings.NewReader(sealed), int64(len(sealed))); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *SealedStore) current(data []byte) bool {
+	v := string(data)
+	return envelope.IsSealed(v) && envelope.KeyID(v) == s.keys.Primary()
+}
+
+func (s *SealedStore) read(key string) ([]byte, error) {
+	rc, err := s.store.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
@@ -0,0 +1,244 @@
+// Package envelope implements envelope encryption: every value gets its
+// own random data key (DEK), which is wrapped with a long-lived key
+// encryption key (KEK). Rotating the KEK only rewraps the small DEK.
+//
+// Sealed values are self-describing strings:
+//
+//	enc:v1:<kek id>:<base64 wrapped DEK>:<base64 ciphertext>
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const prefix = "enc:v1:"
+
+var (
+	ErrUnknownKey = errors.New("value is sealed with an unknown key")
+	ErrCorrupt    = errors.New("sealed value is corrupt")
+)
+
+// Keyring holds the KEKs. New values are sealed with the primary key;
+// the others are kept to open older values until they are rewrapped.
+type Keyring struct {
+	keys    map[string][]byte
+	primary string
+}
+
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("primary key %q is not in the keyring", primary)
+	}
+	for id, k := range keys {
+		if id == "" || strings.ContainsAny(id, ":,") {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+		if len(k) != 32 {
+			return nil, fmt.Errorf("key %q must be 32 bytes (AES-256), got %d", id, len(k))
+		}
+	}
+
+	return &Keyring{keys: keys, primary: primary}, nil
+}
+
+// ParseKeys reads "id1:base64key,id2:base64key"
+func ParseKeys(s string) (map[string][]byte, error) {
+	keys := map[string][]byte{}
+
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("key %q: expected id:base64", pair)
+		}
+
+		k, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		keys[id] = k
+	}
+
+	return keys, nil
+}
+
+// Primary returns the id new values are sealed with
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// IsSealed tells sealed values from legacy plaintext
+func IsSealed(v string) bool {
+	return strings.HasPrefix(v, prefix)
+}
+
+// KeyID returns the id of the KEK that wraps the value
+func KeyID(v string) string {
+	id, _, _, err := split(v)
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+// Seal encrypts plaintext under a fresh DEK wrapped with the primary key
+func (k *Keyring) Seal(plaintext string) (string, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+
+	payload, err := encrypt(dek, []byte(plaintext), []byte(prefix))
+	if err != nil {
+		return "", err
+	}
+
+	wrapped, err := k.wrap(k.primary, dek)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + k.primary + ":" + wrapped + ":" + base64.RawStdEncoding.EncodeToString(payload), nil
+}
+
+// Open decrypts a sealed value. Legacy plaintext is returned unchanged.
+func (k *Keyring) Open(v string) (string, error) {
+	if !IsSealed(v) {
+		return v, nil
+	}
+
+	id, wrapped, payload, err := split(v)
+	if err != nil {
+		return "", err
+	}
+
+	dek, err := k.unwrap(id, wrapped)
+	if err != nil {
+		return "", err
+	}
+
+	raw, err := base64.RawStdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", ErrCorrupt
+	}
+
+	plaintext, err := decrypt(dek, raw, []byte(prefix))
+	if err != nil {
+		return "", ErrCorrupt
+	}
+
+	return string(plaintext), nil
+}
+
+// Rewrap moves a sealed value to the primary key without touching the
+// ciphertext; plaintext values are sealed
+func (k *Keyring) Rewrap(v string) (string, error) {
+	if !IsSealed(v) {
+		return k.Seal(v)
+	}
+
+	id, wrapped, payload, err := split(v)
+	if err != nil {
+		return "", err
+	}
+	if id == k.primary {
+		return v, nil
+	}
+
+	dek, err := k.unwrap(id, wrapped)
+	if err != nil {
+		return "", err
+	}
+
+	rewrapped, err := k.wrap(k.primary, dek)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + k.primary + ":" + rewrapped + ":" + payload, nil
+}
+
+// =====================
+// INTERNALS
+// =====================
+
+// wrap binds the DEK to the key id, so a value can't be relabelled
+func (k *Keyring) wrap(id string, dek []byte) (string, error) {
+	sealed, err := encrypt(k.keys[id], dek, []byte(prefix+id))
+	if err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func (k *Keyring) unwrap(id, wrapped string) ([]byte, error) {
+	kek, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+
+	raw, err := base64.RawStdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+
+	dek, err := decrypt(kek, raw, []byte(prefix+id))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return dek, nil
+}
+
+func split(v string) (id, wrapped, payload string, err error) {
+	parts := strings.Split(strings.TrimPrefix(v, prefix), ":")
+	if len(parts) != 3 {
+		return "", "", "", ErrCorrupt
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// encrypt returns nonce || AES-GCM ciphertext
+func encrypt(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func decrypt(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrCorrupt
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
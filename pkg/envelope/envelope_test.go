@@ -0,0 +1,165 @@
+package envelope
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKeyring(t *testing.T, primary string) *Keyring {
+	t.Helper()
+
+	k, err := NewKeyring(primary, map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 32),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestSealOpen(t *testing.T) {
+	k := testKeyring(t, "k1")
+
+	for _, plaintext := range []string{"", "ghp_abc", "пароль с пробелами", strings.Repeat("x", 10_000)} {
+		sealed, err := k.Seal(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsSealed(sealed) || KeyID(sealed) != "k1" {
+			t.Fatalf("sealed = %q, want enc:v1:k1:...", sealed)
+		}
+		if plaintext != "" && strings.Contains(sealed, plaintext) {
+			t.Fatal("plaintext leaked into the sealed value")
+		}
+
+		got, err := k.Open(sealed)
+		if err != nil || got != plaintext {
+			t.Fatalf("Open = %q, %v, want %q", got, err, plaintext)
+		}
+	}
+
+	// каждый Seal — свой DEK и nonce
+	a, _ := k.Seal("same")
+	b, _ := k.Seal("same")
+	if a == b {
+		t.Fatal("two seals of the same value are equal")
+	}
+}
+
+func TestOpenLegacyPlaintext(t *testing.T) {
+	k := testKeyring(t, "k1")
+
+	if got, err := k.Open("AKIA1234"); err != nil || got != "AKIA1234" {
+		t.Fatalf("Open(plaintext) = %q, %v", got, err)
+	}
+}
+
+func TestOpenRejects(t *testing.T) {
+	k := testKeyring(t, "k1")
+	sealed, _ := k.Seal("secret")
+	parts := strings.Split(strings.TrimPrefix(sealed, prefix), ":")
+
+	flip := func(s string) string {
+		raw, _ := base64.RawStdEncoding.DecodeString(s)
+		raw[len(raw)-1] ^= 1
+		return base64.RawStdEncoding.EncodeToString(raw)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  error
+	}{
+		{"unknown key", prefix + "k9:" + parts[1] + ":" + parts[2], ErrUnknownKey},
+		// DEK привязан к id ключа: переименование не проходит
+		{"relabelled key", prefix + "k2:" + parts[1] + ":" + parts[2], ErrCorrupt},
+		{"tampered DEK", prefix + "k1:" + flip(parts[1]) + ":" + parts[2], ErrCorrupt},
+		{"tampered payload", prefix + "k1:" + parts[1] + ":" + flip(parts[2]), ErrCorrupt},
+		{"bad base64", prefix + "k1:" + parts[1] + ":!!!", ErrCorrupt},
+		{"missing part", prefix + "k1:" + parts[1], ErrCorrupt},
+		{"short payload", prefix + "k1:" + parts[1] + ":AAAA", ErrCorrupt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := k.Open(tt.value); !errors.Is(err, tt.want) {
+				t.Fatalf("Open err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	old := testKeyring(t, "k1")
+	sealed, _ := old.Seal("secret")
+
+	rotated := testKeyring(t, "k2")
+	rewrapped, err := rotated.Rewrap(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KeyID(rewrapped) != "k2" {
+		t.Fatalf("KeyID after Rewrap = %q, want k2", KeyID(rewrapped))
+	}
+	// шифротекст значения не меняется, только обёртка DEK
+	if sealed[strings.LastIndex(sealed, ":"):] != rewrapped[strings.LastIndex(rewrapped, ":"):] {
+		t.Fatal("Rewrap re-encrypted the payload")
+	}
+	if got, err := rotated.Open(rewrapped); err != nil || got != "secret" {
+		t.Fatalf("Open after Rewrap = %q, %v", got, err)
+	}
+
+	if again, _ := rotated.Rewrap(rewrapped); again != rewrapped {
+		t.Fatal("Rewrap changed a value already on the primary key")
+	}
+
+	plain, err := rotated.Rewrap("legacy")
+	if err != nil || KeyID(plain) != "k2" {
+		t.Fatalf("Rewrap(plaintext) = %q, %v, want sealed with k2", plain, err)
+	}
+}
+
+func TestNewKeyring(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+
+	tests := []struct {
+		name    string
+		primary string
+		keys    map[string][]byte
+		wantErr bool
+	}{
+		{"valid", "k1", map[string][]byte{"k1": key}, false},
+		{"primary missing", "k2", map[string][]byte{"k1": key}, true},
+		{"short key", "k1", map[string][]byte{"k1": key[:16]}, true},
+		{"colon in id", "k:1", map[string][]byte{"k:1": key}, true},
+		{"empty id", "", map[string][]byte{"": key}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyring(tt.primary, tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewKeyring err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+
+	keys, err := ParseKeys(" k1:" + key + ", k2:" + key + ",")
+	if err != nil || len(keys) != 2 || len(keys["k2"]) != 32 {
+		t.Fatalf("ParseKeys = %v, %v", keys, err)
+	}
+
+	for _, in := range []string{"k1", "k1:not base64!"} {
+		if _, err := ParseKeys(in); err == nil {
+			t.Errorf("ParseKeys(%q) accepted", in)
+		}
+	}
+}
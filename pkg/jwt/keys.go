@@ -0,0 +1,367 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+
+	rsaKeyBits = 2048
+	hmacKeyID  = "hs256"
+)
+
+var (
+	ErrUnsupportedAlg = errors.New("unsupported signing algorithm")
+	ErrNoKeys         = errors.New("no signing keys")
+)
+
+type signingKey struct {
+	kid     string
+	created time.Time
+
+	private crypto.PrivateKey // RS256 / EdDSA
+	public  crypto.PublicKey
+	secret  []byte // HS256
+}
+
+// KeyRing holds the signing key and the keys still accepted for
+// verification. Asymmetric keys are stored as PKCS#8 PEM files named
+// <kid>.pem in dir, so several instances can share them.
+type KeyRing struct {
+	mu sync.RWMutex
+
+	alg       string
+	method    jwt.SigningMethod
+	dir       string
+	retention time.Duration
+
+	keys []*signingKey // oldest first; last one signs
+}
+
+// NewHMACKeyRing is the legacy single shared secret setup
+func NewHMACKeyRing(secret string) *KeyRing {
+	return &KeyRing{
+		alg:    AlgHS256,
+		method: jwt.SigningMethodHS256,
+		keys: []*signingKey{{
+			kid:    hmacKeyID,
+			secret: []byte(secret),
+		}},
+	}
+}
+
+// LoadKeyRing loads asymmetric keys from dir, generating the first one
+// if the directory is empty. Retired keys stay valid for verification
+// for retention after their successor was created.
+func LoadKeyRing(alg, dir string, retention time.Duration) (*KeyRing, error) {
+	r := &KeyRing{
+		alg:       alg,
+		dir:       dir,
+		retention: retention,
+	}
+
+	switch alg {
+	case AlgRS256:
+		r.method = jwt.SigningMethodRS256
+	case AlgEdDSA:
+		r.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlg, alg)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create key dir: %w", err)
+	}
+
+	// пустой каталог — первый запуск: создаём ключ
+	err := r.Reload()
+	if errors.Is(err, ErrNoKeys) {
+		err = r.Rotate()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *KeyRing) Alg() string {
+	return r.alg
+}
+
+// Reload re-reads key files from disk (picks up rotations made by other
+// instances). If the directory holds no usable keys, the ring keeps the
+// keys it has and ErrNoKeys is returned.
+func (r *KeyRing) Reload() error {
+	if r.dir == "" {
+		return nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(r.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	keys := make([]*signingKey, 0, len(paths))
+	for _, p := range paths {
+		k, err := r.readKey(p)
+		if err != nil {
+			return fmt.Errorf("load key %s: %w", filepath.Base(p), err)
+		}
+		if k != nil {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("%w in %s", ErrNoKeys, r.dir)
+	}
+
+	// kid начинается с секунды создания, двух ключей в одну секунду
+	// по имени не упорядочить
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].created.Equal(keys[j].created) {
+			return keys[i].created.Before(keys[j].created)
+		}
+		return keys[i].kid < keys[j].kid
+	})
+
+	r.mu.Lock()
+	r.keys = r.prune(keys, time.Now())
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Rotate generates a new signing key. Previous keys stay available for
+// verification until they age out of the retention window.
+func (r *KeyRing) Rotate() error {
+	if r.dir == "" {
+		return fmt.Errorf("%w: %s keys can't be rotated", ErrUnsupportedAlg, r.alg)
+	}
+
+	now := time.Now().UTC()
+
+	var (
+		private crypto.Signer
+		err     error
+	)
+	switch r.alg {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return fmt.Errorf("generate key: %w", err)
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	kid := now.Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(r.dir, kid+".pem"), data, 0600); err != nil {
+		return fmt.Errorf("write key: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys = append(r.keys, &signingKey{
+		kid:     kid,
+		created: now,
+		private: private,
+		public:  private.Public(),
+	})
+
+	kept := r.prune(r.keys, now)
+	for _, old := range r.keys {
+		if !containsKID(kept, old.kid) {
+			_ = os.Remove(filepath.Join(r.dir, old.kid+".pem"))
+		}
+	}
+	r.keys = kept
+
+	return nil
+}
+
+// NeedsRotation reports whether the signing key is older than maxAge
+func (r *KeyRing) NeedsRotation(maxAge time.Duration) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.dir == "" {
+		return false
+	}
+	if len(r.keys) == 0 {
+		return true
+	}
+	return time.Since(r.keys[len(r.keys)-1].created) >= maxAge
+}
+
+// StartRotation checks the signing key every minute and rotates it once
+// it's older than interval. Stops when stop is closed.
+func (r *KeyRing) StartRotation(interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	if r.dir == "" || interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := r.Reload(); err != nil {
+					onError(err)
+					continue
+				}
+				if r.NeedsRotation(interval) {
+					if err := r.Rotate(); err != nil {
+						onError(err)
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (r *KeyRing) current() *signingKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.keys) == 0 {
+		return nil
+	}
+	return r.keys[len(r.keys)-1]
+}
+
+func (r *KeyRing) lookup(kid string) *signingKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// токены без kid выпускались только с общим секретом
+	if kid == "" && r.alg == AlgHS256 && len(r.keys) > 0 {
+		return r.keys[len(r.keys)-1]
+	}
+
+	for _, k := range r.keys {
+		if k.kid == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+func (r *KeyRing) signingMaterial(k *signingKey) interface{} {
+	if k.secret != nil {
+		return k.secret
+	}
+	return k.private
+}
+
+func (r *KeyRing) verificationMaterial(k *signingKey) interface{} {
+	if k.secret != nil {
+		return k.secret
+	}
+	return k.public
+}
+
+// prune drops keys whose successor was created more than retention ago.
+// The newest key is always kept.
+func (r *KeyRing) prune(keys []*signingKey, now time.Time) []*signingKey {
+	if r.retention <= 0 || len(keys) < 2 {
+		return keys
+	}
+
+	out := make([]*signingKey, 0, len(keys))
+	for i, k := range keys {
+		if i < len(keys)-1 && now.Sub(keys[i+1].created) > r.retention {
+			continue
+		}
+		out = append(out, k)
+	}
+	return out
+}
+
+func containsKID(keys []*signingKey, kid string) bool {
+	for _, k := range keys {
+		if k.kid == kid {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *KeyRing) readKey(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var signer crypto.Signer
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if r.alg != AlgRS256 {
+			return nil, nil // ключ другого алгоритма (после смены JWT_ALG)
+		}
+		signer = k
+	case ed25519.PrivateKey:
+		if r.alg != AlgEdDSA {
+			return nil, nil
+		}
+		signer = k
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedAlg, parsed)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signingKey{
+		kid:     strings.TrimSuffix(filepath.Base(path), ".pem"),
+		created: info.ModTime(),
+		private: signer,
+		public:  signer.Public(),
+	}, nil
+}
@@ -0,0 +1,178 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newRing(t *testing.T, alg string) (*KeyRing, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	r, err := LoadKeyRing(alg, dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, dir
+}
+
+// age moves a key file's creation time into the past
+func age(t *testing.T, dir, kid string, d time.Duration) {
+	t.Helper()
+
+	at := time.Now().Add(-d)
+	if err := os.Chtimes(filepath.Join(dir, kid+".pem"), at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadKeyRingGeneratesFirstKey(t *testing.T) {
+	r, dir := newRing(t, AlgEdDSA)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if len(files) != 1 || len(r.keys) != 1 {
+		t.Fatalf("files %v, keys %d, want one generated key", files, len(r.keys))
+	}
+
+	// второй экземпляр подхватывает тот же ключ
+	other, err := LoadKeyRing(AlgEdDSA, dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.current().kid != r.current().kid {
+		t.Fatalf("second instance signs with %s, want %s", other.current().kid, r.current().kid)
+	}
+
+	if _, err := LoadKeyRing("PS512", t.TempDir(), time.Hour); !errors.Is(err, ErrUnsupportedAlg) {
+		t.Fatalf("LoadKeyRing(PS512) err = %v", err)
+	}
+}
+
+func TestRotationAndPrune(t *testing.T) {
+	r, dir := newRing(t, AlgRS256)
+	m := NewJWTManagerWithKeys(r, time.Hour, time.Hour)
+
+	first := r.current().kid
+	age(t, dir, first, 3*time.Hour)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	old, err := m.GenerateAccessToken(1, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	second := r.current().kid
+	if second == first {
+		t.Fatal("Rotate kept the signing key")
+	}
+
+	// старый ключ ещё проверяет выпущенные им токены
+	if _, err := m.ParseAccess(old); err != nil {
+		t.Fatalf("token of the retired key rejected within retention: %v", err)
+	}
+	fresh, _ := m.GenerateAccessToken(1, 0, false)
+	if kid := headerKID(t, fresh); kid != second {
+		t.Fatalf("new token signed with %s, want %s", kid, second)
+	}
+
+	// преемник старше retention — старый ключ выпадает
+	age(t, dir, second, 2*time.Hour)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ParseAccess(old); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token of a pruned key err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := m.ParseAccess(fresh); err != nil {
+		t.Fatalf("token of the signing key rejected: %v", err)
+	}
+	if keys := r.JWKS().Keys; len(keys) != 1 || keys[0].Kid != second {
+		t.Fatalf("JWKS after prune = %+v, want only %s", keys, second)
+	}
+	if !r.NeedsRotation(time.Hour) || r.NeedsRotation(3*time.Hour) {
+		t.Fatal("NeedsRotation does not follow the signing key age")
+	}
+}
+
+func TestReloadKeepsKeysWhenDirIsEmpty(t *testing.T) {
+	r, dir := newRing(t, AlgEdDSA)
+	m := NewJWTManagerWithKeys(r, time.Hour, time.Hour)
+	kid := r.current().kid
+
+	if err := os.Remove(filepath.Join(dir, kid+".pem")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Reload(); !errors.Is(err, ErrNoKeys) {
+		t.Fatalf("Reload err = %v, want ErrNoKeys", err)
+	}
+	if r.current() == nil || r.current().kid != kid {
+		t.Fatal("Reload dropped the keys it had")
+	}
+	if _, err := m.GenerateAccessToken(1, 0, false); err != nil {
+		t.Fatalf("signing after a failed reload: %v", err)
+	}
+}
+
+func TestReloadIgnoresOtherAlgorithms(t *testing.T) {
+	dir := t.TempDir()
+	rsaRing, err := LoadKeyRing(AlgRS256, dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// после смены JWT_ALG старый RSA-ключ лежит рядом и не мешает
+	edRing, err := LoadKeyRing(AlgEdDSA, dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edRing.keys) != 1 || edRing.current().kid == rsaRing.current().kid {
+		t.Fatalf("EdDSA ring keys = %d, want its own single key", len(edRing.keys))
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaRing, _ := newRing(t, AlgRS256)
+	edRing, _ := newRing(t, AlgEdDSA)
+
+	set := rsaRing.JWKS()
+	if len(set.Keys) != 1 {
+		t.Fatalf("RSA JWKS = %+v", set)
+	}
+	k := set.Keys[0]
+	pub := rsaRing.current().public.(*rsa.PublicKey)
+	if k.Kty != "RSA" || k.Alg != AlgRS256 || k.Use != "sig" || k.Kid != rsaRing.current().kid {
+		t.Fatalf("RSA JWK = %+v", k)
+	}
+	n, _ := base64.RawURLEncoding.DecodeString(k.N)
+	e, _ := base64.RawURLEncoding.DecodeString(k.E)
+	if new(big.Int).SetBytes(n).Cmp(pub.N) != 0 || int(new(big.Int).SetBytes(e).Int64()) != pub.E {
+		t.Fatal("RSA JWK does not encode the public key")
+	}
+	if k.Crv != "" || k.X != "" {
+		t.Fatalf("RSA JWK carries OKP fields: %+v", k)
+	}
+
+	k = edRing.JWKS().Keys[0]
+	x, _ := base64.RawURLEncoding.DecodeString(k.X)
+	if k.Kty != "OKP" || k.Crv != "Ed25519" || k.Alg != AlgEdDSA || !edRing.current().public.(ed25519.PublicKey).Equal(ed25519.PublicKey(x)) {
+		t.Fatalf("Ed25519 JWK = %+v", k)
+	}
+
+	// общий секрет не публикуется
+	if keys := NewHMACKeyRing("secret").JWKS().Keys; len(keys) != 0 {
+		t.Fatalf("HMAC JWKS = %+v, want empty", keys)
+	}
+}
@@ -5,51 +5,248 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+
+	// single-purpose tokens sent by email
+	TokenTypeEmailVerify   = "email_verify"
+	TokenTypePasswordReset = "password_reset"
+
+	// second login step after the password when 2FA is on
+	TokenTypeMFAChallenge = "mfa_challenge"
+)
+
+var (
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrWrongTokenType = errors.New("wrong token type")
 )
 
 type JWTManager struct {
-	secret     string
+	keys       *KeyRing
 	accessTTL  time.Duration
 	refreshTTL time.Duration
 }
 
+// NewJWTManager signs with a single shared HS256 secret
 func NewJWTManager(secret string, accessTTL, refreshTTL time.Duration) *JWTManager {
+	return NewJWTManagerWithKeys(NewHMACKeyRing(secret), accessTTL, refreshTTL)
+}
+
+// NewJWTManagerWithKeys signs with the current key of the ring and
+// verifies against every key still in it
+func NewJWTManagerWithKeys(keys *KeyRing, accessTTL, refreshTTL time.Duration) *JWTManager {
 	return &JWTManager{
-		secret:     secret,
+		keys:       keys,
 		accessTTL:  accessTTL,
 		refreshTTL: refreshTTL,
 	}
 }
 
-func (m *JWTManager) GenerateAccessToken(userID uint) (string, error) {
+func (m *JWTManager) Keys() *KeyRing {
+	return m.keys
+}
+
+// RefreshToken is a signed refresh token with the identifiers needed
+// to persist it
+type RefreshToken struct {
+	Token     string
+	JTI       string
+	FamilyID  string
+	ExpiresAt time.Time
+}
+
+// GenerateAccessToken issues an access token. version is the user's
+// token version at issue time; bumping it invalidates all earlier tokens.
+// mfa records that the session passed a second factor.
+func (m *JWTManager) GenerateAccessToken(userID uint, version int, mfa bool) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userID,
 		"exp":     time.Now().Add(m.accessTTL).Unix(),
+		"type":    TokenTypeAccess,
+		"jti":     uuid.New().String(),
+		"ver":     version,
+		"mfa":     mfa,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(m.secret))
+	return m.sign(claims)
 }
 
-func (m *JWTManager) GenerateRefreshToken(userID uint) (string, error) {
+// GenerateRefreshToken issues a refresh token in the given family.
+// An empty familyID starts a new family (new login).
+func (m *JWTManager) GenerateRefreshToken(userID uint, version int, familyID string, mfa bool) (*RefreshToken, error) {
+	if familyID == "" {
+		familyID = uuid.New().String()
+	}
+
+	jti := uuid.New().String()
+	expiresAt := time.Now().Add(m.refreshTTL)
+
 	claims := jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(m.refreshTTL).Unix(),
-		"type":    "refresh",
+		"exp":     expiresAt.Unix(),
+		"type":    TokenTypeRefresh,
+		"jti":     jti,
+		"fam":     familyID,
+		"ver":     version,
+		"mfa":     mfa,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(m.secret))
+	signed, err := m.sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RefreshToken{
+		Token:     signed,
+		JTI:       jti,
+		FamilyID:  familyID,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// GenerateActionToken issues a short-lived token for one action (email
+// verification, password reset). Its type keeps it from ever passing as
+// an access or refresh token.
+func (m *JWTManager) GenerateActionToken(
+	userID uint,
+	version int,
+	tokenType string,
+	ttl time.Duration,
+) (string, error) {
+
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"exp":     time.Now().Add(ttl).Unix(),
+		"type":    tokenType,
+		"jti":     uuid.New().String(),
+		"ver":     version,
+	}
+
+	return m.sign(claims)
 }
 
+func (m *JWTManager) sign(claims jwt.MapClaims) (string, error) {
+	key := m.keys.current()
+	if key == nil {
+		return "", ErrNoKeys
+	}
+
+	token := jwt.NewWithClaims(m.keys.method, claims)
+	token.Header["kid"] = key.kid
+
+	return token.SignedString(m.keys.signingMaterial(key))
+}
+
+// Parse verifies the signature with the key named by the kid header.
+// Only the ring's algorithm is accepted, so a token can't downgrade to
+// HS256 with the public key as secret or to "none".
 func (m *JWTManager) Parse(tokenStr string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-		return []byte(m.secret), nil
-	})
+	token, err := jwt.Parse(
+		tokenStr,
+		func(t *jwt.Token) (interface{}, error) {
+			if t.Method.Alg() != m.keys.alg {
+				return nil, ErrUnsupportedAlg
+			}
+
+			kid, _ := t.Header["kid"].(string)
+			key := m.keys.lookup(kid)
+			if key == nil {
+				return nil, ErrInvalidToken
+			}
+
+			return m.keys.verificationMaterial(key), nil
+		},
+		jwt.WithValidMethods([]string{m.keys.alg}),
+	)
 
 	if err != nil || !token.Valid {
-		return nil, errors.New("invalid token")
+		return nil, ErrInvalidToken
 	}
 
 	return token.Claims.(jwt.MapClaims), nil
 }
+
+// ParseAccess accepts only access tokens. Tokens issued before the
+// "type" claim existed are treated as access tokens.
+func (m *JWTManager) ParseAccess(tokenStr string) (jwt.MapClaims, error) {
+	claims, err := m.Parse(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if t, _ := claims["type"].(string); t != "" && t != TokenTypeAccess {
+		return nil, ErrWrongTokenType
+	}
+
+	return claims, nil
+}
+
+// ParseRefresh accepts only refresh tokens that carry jti and family
+func (m *JWTManager) ParseRefresh(tokenStr string) (jwt.MapClaims, error) {
+	claims, err := m.Parse(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if t, _ := claims["type"].(string); t != TokenTypeRefresh {
+		return nil, ErrWrongTokenType
+	}
+
+	if jti, _ := claims["jti"].(string); jti == "" {
+		return nil, ErrInvalidToken
+	}
+	if fam, _ := claims["fam"].(string); fam == "" {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// ParseAction accepts only action tokens of the given type
+func (m *JWTManager) ParseAction(tokenStr, tokenType string) (jwt.MapClaims, error) {
+	claims, err := m.Parse(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if t, _ := claims["type"].(string); t != tokenType {
+		return nil, ErrWrongTokenType
+	}
+
+	return claims, nil
+}
+
+// UserID extracts the user_id claim
+func UserID(claims jwt.MapClaims) (uint, bool) {
+	v, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, false
+	}
+	return uint(v), true
+}
+
+// Version extracts the token version claim (0 for tokens issued before
+// versions existed)
+func Version(claims jwt.MapClaims) int {
+	v, _ := claims["ver"].(float64)
+	return int(v)
+}
+
+// MFA reports whether the session passed a second factor
+func MFA(claims jwt.MapClaims) bool {
+	v, _ := claims["mfa"].(bool)
+	return v
+}
+
+// ExpiresAt extracts the exp claim
+func ExpiresAt(claims jwt.MapClaims) time.Time {
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return time.Time{}
+	}
+	return exp.Time
+}
@@ -0,0 +1,145 @@
+package jwt
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func headerKID(t *testing.T, token string) string {
+	t.Helper()
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestParseRejectsForgedTokens(t *testing.T) {
+	r, _ := newRing(t, AlgRS256)
+	m := NewJWTManagerWithKeys(r, time.Hour, time.Hour)
+	kid := r.current().kid
+
+	claims := jwt.MapClaims{"user_id": 1, "type": TokenTypeAccess, "exp": time.Now().Add(time.Hour).Unix()}
+
+	// HS256 с публичным ключом в роли секрета — классическая подмена алгоритма
+	der, err := x509.MarshalPKIXPublicKey(r.current().public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	hs.Header["kid"] = kid
+	confused, _ := hs.SignedString(pubPEM)
+
+	none := jwt.NewWithClaims(jwt.SigningMethodNone, claims)
+	none.Header["kid"] = kid
+	unsigned, _ := none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	// подпись чужим ключом RS256 под именем нашего kid и под чужим kid
+	other, _ := newRing(t, AlgRS256)
+	foreign, _ := NewJWTManagerWithKeys(other, time.Hour, time.Hour).GenerateAccessToken(1, 0, false)
+	relabelled := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	relabelled.Header["kid"] = kid
+	stolenKID, _ := relabelled.SignedString(other.current().private)
+
+	valid, _ := m.GenerateAccessToken(1, 0, false)
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"HS256 against an RS256 ring", confused},
+		{"alg none", unsigned},
+		{"unknown kid", foreign},
+		{"foreign key under our kid", stolenKID},
+		{"swapped payload", parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"user_id":2,"type":"access"}`)) + "." + parts[2]},
+		{"garbage", "not.a.token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.Parse(tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("Parse err = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+
+	if _, err := m.Parse(valid); err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+}
+
+func TestParseRejectsExpired(t *testing.T) {
+	m := NewJWTManager("secret", -time.Minute, time.Hour)
+
+	token, err := m.GenerateAccessToken(1, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Parse(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expired token err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestTokenTypes(t *testing.T) {
+	m := NewJWTManager("secret", time.Hour, time.Hour)
+
+	access, _ := m.GenerateAccessToken(7, 3, true)
+	refresh, _ := m.GenerateRefreshToken(7, 3, "", false)
+	reset, _ := m.GenerateActionToken(7, 3, TokenTypePasswordReset, time.Hour)
+
+	claims, err := m.ParseAccess(access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := UserID(claims); !ok || id != 7 || Version(claims) != 3 || !MFA(claims) {
+		t.Fatalf("access claims = %v", claims)
+	}
+	if ExpiresAt(claims).Before(time.Now()) {
+		t.Fatal("access token already expired")
+	}
+
+	if _, err := m.ParseRefresh(refresh.Token); err != nil || refresh.FamilyID == "" || refresh.JTI == "" {
+		t.Fatalf("ParseRefresh = %v, token %+v", err, refresh)
+	}
+	if _, err := m.ParseAction(reset, TokenTypePasswordReset); err != nil {
+		t.Fatal(err)
+	}
+
+	wrong := []struct {
+		name  string
+		parse func() error
+	}{
+		{"refresh as access", func() error { _, err := m.ParseAccess(refresh.Token); return err }},
+		{"reset as access", func() error { _, err := m.ParseAccess(reset); return err }},
+		{"access as refresh", func() error { _, err := m.ParseRefresh(access); return err }},
+		{"reset as email verification", func() error { _, err := m.ParseAction(reset, TokenTypeEmailVerify); return err }},
+	}
+	for _, w := range wrong {
+		if err := w.parse(); !errors.Is(err, ErrWrongTokenType) {
+			t.Errorf("%s: err = %v, want ErrWrongTokenType", w.name, err)
+		}
+	}
+}
+
+func TestLegacyHMACTokenWithoutKID(t *testing.T) {
+	m := NewJWTManager("secret", time.Hour, time.Hour)
+
+	legacy, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 1, "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("secret"))
+
+	if _, err := m.ParseAccess(legacy); err != nil {
+		t.Fatalf("token without kid from the shared secret rejected: %v", err)
+	}
+}
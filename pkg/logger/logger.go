@@ -28,23 +28,27 @@ func Init() {
 		zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	}
 
+	// секреты не должны попадать в логи ни в каком окружении;
+	// чистим JSON-событие до форматирования
+	out := scrubWriter{out: os.Stdout}
+
 	// DEV
 	if env == "dev" {
 		writer := zerolog.ConsoleWriter{
 			Out:        os.Stdout,
 			TimeFormat: time.RFC822,
 		}
-		Log = zerolog.New(writer).With().Timestamp().Logger()
+		Log = zerolog.New(scrubWriter{out: writer}).With().Timestamp().Logger()
 		return
 	}
 
 	// TEST
 	if env == "test" {
 		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
-		Log = zerolog.New(os.Stdout).With().Timestamp().Logger()
+		Log = zerolog.New(out).With().Timestamp().Logger()
 		return
 	}
 
 	// PROD — JSON
-	Log = zerolog.New(os.Stdout).With().Timestamp().Logger()
+	Log = zerolog.New(out).With().Timestamp().Logger()
 }
@@ -0,0 +1,21 @@
+package logger
+
+import (
+	"io"
+
+	"mws-ai/pkg/mask"
+)
+
+// scrubWriter masks credentials in every log line before it leaves the
+// process. zerolog hands over one complete event per Write.
+type scrubWriter struct {
+	out io.Writer
+}
+
+func (w scrubWriter) Write(p []byte) (int, error) {
+	if _, err := w.out.Write([]byte(mask.Scrub(string(p)))); err != nil {
+		return 0, err
+	}
+	// zerolog сверяет длину с исходным событием
+	return len(p), nil
+}
@@ -0,0 +1,216 @@
+package mask
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Placeholder stands in for the secret in redacted context
+const Placeholder = "<SECRET>"
+
+// Description is what an external model may learn about a secret
+// without seeing it
+type Description struct {
+	Length  int     `json:"length"`
+	Charset string  `json:"charset"`
+	Entropy float64 `json:"entropy"`
+	Prefix  string  `json:"prefix"`
+	Context string  `json:"context"`
+}
+
+// knownPrefixes are vendor markers that identify a credential type and
+// are the same for every key, so revealing them leaks nothing
+var knownPrefixes = []string{
+	"-----BEGIN",
+	"github_pat_",
+	"ghp_", "gho_", "ghu_", "ghs_", "ghr_",
+	"glpat-",
+	"xoxa-", "xoxb-", "xoxp-", "xoxr-", "xoxs-",
+	"sk_live_", "sk_test_", "rk_live_", "pk_live_",
+	"mws_sk_",
+	"AKIA", "ASIA",
+	"AIza",
+	"sk-",
+	"eyJ",
+}
+
+// tokenPattern splits a snippet into candidate secret tokens
+var tokenPattern = regexp.MustCompile(`[A-Za-z0-9+/_.~-]{8,}={0,2}`)
+
+// Describe finds the secret inside value (a bare secret or a whole
+// source line) and describes it. Context is the surrounding source (or
+// the value itself when there is none) with the secret, raw or already
+// masked by RedactContext, replaced by Placeholder.
+func Describe(value, context string) Description {
+	secret := FindSecret(value)
+
+	if context == "" {
+		context = value
+	}
+	if secret != "" {
+		context = strings.ReplaceAll(context, secret, Placeholder)
+		context = strings.ReplaceAll(context, Mask(secret), Placeholder)
+	}
+
+	// в строке может быть и второй секрет
+	context = tokenPattern.ReplaceAllStringFunc(context, func(tok string) string {
+		if len(tok) >= 16 && Entropy(tok) >= 3.5 {
+			return Placeholder
+		}
+		return tok
+	})
+
+	return Description{
+		Length:  utf8.RuneCountInString(secret),
+		Charset: Charset(secret),
+		Entropy: math.Round(Entropy(secret)*100) / 100,
+		Prefix:  Prefix(secret),
+		Context: context,
+	}
+}
+
+// RedactContext masks the finding's secret in its source context and
+// scrubs any other credential that happens to be nearby
+func RedactContext(context, value string) string {
+	if context == "" {
+		return ""
+	}
+	return Scrub(Redact(context, FindSecret(value)))
+}
+
+// FindSecret treats a value without separators as the secret itself,
+// otherwise picks the token carrying the most information
+func FindSecret(value string) string {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return ""
+	}
+
+	// PEM-блок целиком — один секрет
+	if strings.HasPrefix(trimmed, "-----BEGIN") {
+		return trimmed
+	}
+
+	if !strings.ContainsAny(trimmed, " \t\"'`=:,;()[]{}<>") {
+		return trimmed
+	}
+
+	best, bestScore := "", 0.0
+	for _, tok := range tokenPattern.FindAllString(trimmed, -1) {
+		score := Entropy(tok) * float64(len(tok))
+		if score > bestScore {
+			best, bestScore = tok, score
+		}
+	}
+
+	return best
+}
+
+// Entropy is the Shannon entropy of s in bits per character
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+
+	counts := map[rune]int{}
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+
+	h := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+
+	return h
+}
+
+// Charset names the narrowest alphabet s fits into
+func Charset(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	var lower, upper, digit, hexOnly, b64, b64url, other bool
+	hexOnly = true
+
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+			if r > 'f' {
+				hexOnly = false
+			}
+		case r >= 'A' && r <= 'Z':
+			upper = true
+			if r > 'F' {
+				hexOnly = false
+			}
+		case r >= '0' && r <= '9':
+			digit = true
+		case r == '+' || r == '/' || r == '=':
+			b64 = true
+			hexOnly = false
+		case r == '-' || r == '_':
+			b64url = true
+			hexOnly = false
+		default:
+			other = true
+			hexOnly = false
+		}
+	}
+
+	switch {
+	case other:
+		return "mixed"
+	case digit && !lower && !upper && !b64 && !b64url:
+		return "numeric"
+	case hexOnly && !(lower && upper):
+		return "hex"
+	case b64 && !b64url:
+		return "base64"
+	case b64url && !b64:
+		return "base64url"
+	case !b64 && !b64url:
+		return "alphanumeric"
+	}
+
+	return "mixed"
+}
+
+// Prefix returns a known vendor prefix, or the character classes of the
+// first four characters ("Aa9_") when the prefix is unknown
+func Prefix(s string) string {
+	for _, p := range knownPrefixes {
+		if strings.HasPrefix(s, p) {
+			return p
+		}
+	}
+
+	var b strings.Builder
+	for i, r := range []rune(s) {
+		if i == 4 {
+			break
+		}
+		switch {
+		case unicode.IsUpper(r):
+			b.WriteByte('A')
+		case unicode.IsLower(r):
+			b.WriteByte('a')
+		case unicode.IsDigit(r):
+			b.WriteByte('9')
+		case r < utf8.RuneSelf && unicode.IsPunct(r):
+			b.WriteRune(r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,101 @@
+// Package mask hides secrets in API responses and log output while keeping
+// enough to tell two values apart.
+package mask
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mask renders a secret as its first few characters, its length and a
+// short fingerprint: "AKIA…[20 chars, sha256:3f2a9c1e]". Short values
+// show no prefix at all.
+func Mask(v string) string {
+	if v == "" {
+		return ""
+	}
+
+	n := utf8.RuneCountInString(v)
+
+	// не больше четверти значения и не больше 4 символов
+	show := min(4, n/4)
+	if n < 12 {
+		show = 0
+	}
+
+	return fmt.Sprintf("%s…[%d chars, sha256:%s]", string([]rune(v)[:show]), n, Fingerprint(v))
+}
+
+// Fingerprint is a truncated SHA-256, enough to correlate equal values
+// without making short secrets cheap to brute-force
+func Fingerprint(v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return hex.EncodeToString(sum[:4])
+}
+
+// Redact replaces every occurrence of the given secrets in text
+func Redact(text string, secrets ...string) string {
+	for _, s := range secrets {
+		// слишком короткие значения совпадут с чем угодно
+		if len(s) < 6 {
+			continue
+		}
+		text = strings.ReplaceAll(text, s, Mask(s))
+	}
+	return text
+}
+
+// =====================
+// LOG SCRUBBING
+// =====================
+
+// secretPatterns match well-known credential formats
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{22,}\b`),
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),
+	regexp.MustCompile(`\bmws_sk_[A-Za-z0-9-]{8,}`),
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`),
+	regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`),
+}
+
+// bearerPattern keeps the scheme and masks the credential
+var bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)([A-Za-z0-9._~+/-]{8,}=*)`)
+
+// fieldPattern masks JSON fields whose name says they hold a secret
+var fieldPattern = regexp.MustCompile(`(?i)("[a-z_]*(?:password|secret|token)"\s*:\s*")((?:[^"\\]|\\.)+)(")`)
+
+// Scrub masks anything in text that looks like a credential. It is meant
+// for log lines, so replacements never contain quotes and JSON stays valid.
+func Scrub(text string) string {
+	for _, re := range secretPatterns {
+		text = re.ReplaceAllStringFunc(text, logMask)
+	}
+
+	text = bearerPattern.ReplaceAllStringFunc(text, func(m string) string {
+		parts := bearerPattern.FindStringSubmatch(m)
+		return parts[1] + logMask(parts[2])
+	})
+
+	text = fieldPattern.ReplaceAllStringFunc(text, func(m string) string {
+		parts := fieldPattern.FindStringSubmatch(m)
+		// уже замаскированное не трогаем
+		if strings.Contains(parts[2], "…[") {
+			return m
+		}
+		return parts[1] + logMask(parts[2]) + parts[3]
+	})
+
+	return text
+}
+
+// logMask drops escape characters from the visible prefix, so a prefix
+// cut in the middle of a JSON escape can't break the line
+func logMask(v string) string {
+	return strings.NewReplacer(`\`, "", `"`, "").Replace(Mask(v))
+}
@@ -0,0 +1,130 @@
+// Package ratelimit implements token-bucket rate limiting over a
+// pluggable bucket store (in-process memory or a shared database).
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a bucket of Burst tokens refilled evenly over Per
+// ("300/1m": 300 requests per minute, at most 300 at once)
+type Limit struct {
+	Burst int
+	Per   time.Duration
+}
+
+// ParseLimit reads "N/duration", e.g. "10/1m" or "1000/1h"
+func ParseLimit(s string) (Limit, error) {
+	n, per, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q: want N/duration", s)
+	}
+
+	burst, err := strconv.Atoi(n)
+	if err != nil || burst <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid count", s)
+	}
+
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid duration", s)
+	}
+
+	return Limit{Burst: burst, Per: d}, nil
+}
+
+// rate in tokens per second
+func (l Limit) rate() float64 {
+	return float64(l.Burst) / l.Per.Seconds()
+}
+
+// Bucket is the persisted state of one key
+type Bucket struct {
+	Tokens     float64
+	RefilledAt time.Time
+}
+
+// Result of taking a token
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetIn is the time until the bucket is full again
+	ResetIn time.Duration
+	// RetryIn is the time until the next token (zero when allowed)
+	RetryIn time.Duration
+}
+
+// NewBucket returns a full bucket
+func NewBucket(l Limit, now time.Time) Bucket {
+	return Bucket{Tokens: float64(l.Burst), RefilledAt: now}
+}
+
+// Take refills the bucket up to now and consumes one token if available
+func (b *Bucket) Take(l Limit, now time.Time) Result {
+	elapsed := now.Sub(b.RefilledAt).Seconds()
+	if elapsed > 0 {
+		b.Tokens = math.Min(float64(l.Burst), b.Tokens+elapsed*l.rate())
+		b.RefilledAt = now
+	}
+
+	res := Result{Limit: l.Burst}
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryIn = seconds((1 - b.Tokens) / l.rate())
+	}
+
+	res.Remaining = int(math.Floor(b.Tokens))
+	res.ResetIn = seconds((float64(l.Burst) - b.Tokens) / l.rate())
+
+	return res
+}
+
+// Full reports whether the bucket would be full at now (safe to forget)
+func (b *Bucket) Full(l Limit, now time.Time) bool {
+	return b.Tokens+now.Sub(b.RefilledAt).Seconds()*l.rate() >= float64(l.Burst)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Store keeps buckets. Take must be atomic per key.
+type Store interface {
+	Take(key string, l Limit, now time.Time) (Result, error)
+}
+
+// Limiter applies named budgets ("upload", "read", ...) to keys
+type Limiter struct {
+	store  Store
+	limits map[string]Limit
+}
+
+func NewLimiter(store Store, limits map[string]Limit) *Limiter {
+	return &Limiter{store: store, limits: limits}
+}
+
+// Take consumes a token of budget for key. Unknown budgets are unlimited
+// and reported with ok == false.
+func (l *Limiter) Take(budget, key string) (Result, bool, error) {
+	limit, ok := l.limits[budget]
+	if !ok {
+		return Result{Allowed: true}, false, nil
+	}
+
+	res, err := l.store.Take(budget+":"+key, limit, time.Now())
+	return res, true, err
+}
+
+// Policy renders the RateLimit-Policy header value for a budget
+func (l *Limiter) Policy(budget string) string {
+	limit := l.limits[budget]
+	return fmt.Sprintf("%d;w=%d", limit.Burst, int(limit.Per/time.Second))
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{"10/1m", Limit{Burst: 10, Per: time.Minute}, false},
+		{" 1000/1h ", Limit{Burst: 1000, Per: time.Hour}, false},
+		{"5/30s", Limit{Burst: 5, Per: 30 * time.Second}, false},
+		{"10", Limit{}, true},
+		{"0/1m", Limit{}, true},
+		{"-1/1m", Limit{}, true},
+		{"ten/1m", Limit{}, true},
+		{"10/forever", Limit{}, true},
+		{"10/0s", Limit{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLimit(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLimit(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseLimit(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBucketTake(t *testing.T) {
+	l := Limit{Burst: 3, Per: 3 * time.Second} // 1 token/s
+	now := time.Unix(1_700_000_000, 0)
+	b := NewBucket(l, now)
+
+	for i := 2; i >= 0; i-- {
+		res := b.Take(l, now)
+		if !res.Allowed || res.Remaining != i {
+			t.Fatalf("take: %+v, want allowed with %d remaining", res, i)
+		}
+	}
+
+	res := b.Take(l, now)
+	if res.Allowed {
+		t.Fatal("empty bucket allowed a request")
+	}
+	if res.RetryIn != time.Second {
+		t.Fatalf("RetryIn = %s, want 1s", res.RetryIn)
+	}
+	if res.ResetIn != 3*time.Second {
+		t.Fatalf("ResetIn = %s, want 3s", res.ResetIn)
+	}
+
+	// через секунду — ровно один токен
+	if res := b.Take(l, now.Add(time.Second)); !res.Allowed {
+		t.Fatalf("refilled token not allowed: %+v", res)
+	}
+	if res := b.Take(l, now.Add(time.Second)); res.Allowed {
+		t.Fatal("allowed more than the refill rate")
+	}
+
+	// долгий простой не копит больше Burst
+	later := now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		b.Take(l, later)
+	}
+	if res := b.Take(l, later); res.Allowed {
+		t.Fatal("bucket grew past its burst")
+	}
+}
+
+func TestLimiterBudgets(t *testing.T) {
+	lim := NewLimiter(NewMemoryStore(), map[string]Limit{
+		"upload": {Burst: 1, Per: time.Hour},
+	})
+
+	if res, limited, _ := lim.Take("upload", "key:1"); !limited || !res.Allowed {
+		t.Fatalf("first upload: limited=%v %+v", limited, res)
+	}
+	if res, _, _ := lim.Take("upload", "key:1"); res.Allowed {
+		t.Fatal("second upload allowed")
+	}
+	// ключи и бюджеты считаются отдельно
+	if res, _, _ := lim.Take("upload", "key:2"); !res.Allowed {
+		t.Fatal("another key shares the bucket")
+	}
+	if res, limited, _ := lim.Take("read", "key:1"); limited || !res.Allowed {
+		t.Fatalf("unknown budget: limited=%v %+v, want unlimited", limited, res)
+	}
+
+	if got := lim.Policy("upload"); got != "1;w=3600" {
+		t.Fatalf("Policy = %q", got)
+	}
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	s := NewMemoryStore()
+	l := Limit{Burst: 2, Per: time.Second}
+	now := time.Unix(1_700_000_000, 0)
+
+	_, _ = s.Take("a", l, now)
+	_, _ = s.Take("b", l, now)
+
+	_, _ = s.Take("c", l, now.Add(2*sweepInterval))
+	if len(s.buckets) != 1 {
+		t.Fatalf("buckets after sweep = %d, want 1", len(s.buckets))
+	}
+}
@@ -0,0 +1,119 @@
+package secretformat
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+	"time"
+)
+
+// =====================
+// JWT
+// =====================
+
+func checkJWT(s string) (Verdict, string) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return Invalid, "jwt must have header, payload and signature"
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if !decodeSegment(parts[0], &header) {
+		return Invalid, "jwt header is not base64url JSON"
+	}
+
+	var payload map[string]interface{}
+	if !decodeSegment(parts[1], &payload) {
+		return Invalid, "jwt payload is not a base64url JSON object"
+	}
+
+	switch {
+	case header.Alg == "":
+		return Invalid, "jwt header has no alg"
+	case strings.EqualFold(header.Alg, "none"):
+		// неподписанный токен ничего не удостоверяет
+		return Invalid, "jwt is unsigned (alg none)"
+	case parts[2] == "":
+		return Invalid, "jwt has no signature"
+	}
+
+	if _, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[2], "=")); err != nil {
+		return Invalid, "jwt signature is not base64url"
+	}
+
+	if documentationValues[s] {
+		return Unknown, "jwt.io sample token"
+	}
+	if exp, ok := payload["exp"].(float64); ok {
+		if at := time.Unix(int64(exp), 0); at.Before(time.Now()) {
+			return Unknown, "jwt expired at " + at.UTC().Format(time.RFC3339)
+		}
+	}
+
+	// подпись без ключа не проверить
+	return Unknown, "jwt structure is valid (" + header.Alg + "), the signature can't be checked"
+}
+
+func decodeSegment(seg string, out interface{}) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(seg, "="))
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(raw, out) == nil
+}
+
+// =====================
+// PEM
+// =====================
+
+var opensshMagic = []byte("openssh-key-v1\x00")
+
+// checkPEM parses the key material. Scanners often report only the BEGIN
+// line, so a block without a body is inconclusive rather than invalid.
+func checkPEM(s string) (Verdict, string) {
+	// сниппет из кода: переводы строк бывают экранированы
+	s = strings.ReplaceAll(s, `\n`, "\n")
+
+	if !strings.Contains(s, "-----END ") {
+		return Unknown, "only the key header is available"
+	}
+
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return Invalid, "key body is not valid base64 PEM"
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		if _, err := x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return Invalid, "RSA key does not parse: " + err.Error()
+		}
+	case "EC PRIVATE KEY":
+		if _, err := x509.ParseECPrivateKey(block.Bytes); err != nil {
+			return Invalid, "EC key does not parse: " + err.Error()
+		}
+	case "PRIVATE KEY":
+		if _, err := x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+			return Invalid, "PKCS#8 key does not parse: " + err.Error()
+		}
+	case "OPENSSH PRIVATE KEY":
+		if !bytes.HasPrefix(block.Bytes, opensshMagic) {
+			return Invalid, "OpenSSH key has no openssh-key-v1 header"
+		}
+	default:
+		// ENCRYPTED, DSA и прочие: достаточно корректной DER-структуры
+		var seq asn1.RawValue
+		if _, err := asn1.Unmarshal(block.Bytes, &seq); err != nil {
+			return Invalid, block.Type + " is not DER: " + err.Error()
+		}
+	}
+
+	// тестовые ключи в репозиториях тоже разбираются
+	return Unknown, block.Type + " parses"
+}
@@ -0,0 +1,115 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is "12345678901234567890", the SHA-1 key from RFC 6238 appendix B
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+	// RFC даёт 8 цифр, у нас последние 6
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+
+	// секрет из приложения бывает в нижнем регистре и с пробелами
+	if got, _ := Code(" "+strings.ToLower(rfcSecret)+" ", 1); got != "287082" {
+		t.Errorf("Code with a lowercase secret = %s", got)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code accepted an invalid secret")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+	code := func(s int64) string {
+		c, _ := Code(rfcSecret, s)
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     int
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", code(step), 1, step, true},
+		{"previous step within skew", code(step - 1), 1, step - 1, true},
+		{"next step within skew", " " + code(step+1) + " ", 1, step + 1, true},
+		{"outside skew", code(step - 2), 1, 0, false},
+		{"no skew", code(step - 1), 0, 0, false},
+		{"wrong code", "000000", 1, 0, false},
+		{"too short", code(step)[:5], 1, 0, false},
+		{"too long", code(step) + "0", 1, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Validate(rfcSecret, tt.code, now, tt.skew)
+			if ok != tt.wantOK || got != tt.wantStep {
+				t.Fatalf("Validate = %d, %v, want %d, %v", got, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GenerateSecret()
+
+	if len(a) != 32 || a == b {
+		t.Fatalf("secrets %q, %q: want two distinct 32-char values", a, b)
+	}
+	if _, err := Code(a, 1); err != nil {
+		t.Fatalf("generated secret is not valid base32: %v", err)
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	raw := ProvisioningURI("MWS AI", "dev@example.com", rfcSecret)
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/MWS AI:dev@example.com" {
+		t.Fatalf("URI = %s", raw)
+	}
+
+	q := u.Query()
+	for k, want := range map[string]string{
+		"secret": rfcSecret, "issuer": "MWS AI", "algorithm": "SHA1", "digits": "6", "period": "30",
+	} {
+		if q.Get(k) != want {
+			t.Errorf("%s = %q, want %q", k, q.Get(k), want)
+		}
+	}
+}